@@ -0,0 +1,237 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/cron"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+)
+
+// connect loads the configuration of the named bot and creates the API client
+// for the commands calling the Bot API once. Their calls don't go through the
+// leader lock, they don't compete with the running bot.
+func connect(name string) (config.Config, *tgbotapi.BotAPI, error) {
+	cfg, err := config.FromEnv(name)
+	if err != nil {
+		return cfg, nil, err
+	}
+
+	api, err := tgbotapi.NewBotAPI(cfg.Token)
+	if err != nil {
+		return cfg, nil, err
+	}
+	api.Debug = cfg.Debug
+	return cfg, api, nil
+}
+
+// webhookCommand sets, removes or shows the webhook.
+func webhookCommand(args []string) error {
+	fs := flag.NewFlagSet("webhook", flag.ExitOnError)
+	name := fs.String("bot", "", "name of the bot, if BOTS lists several")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bot webhook [-bot NAME] set URL | delete | info")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var action string
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+	}
+	if !(action == "set" && fs.NArg() == 2 || (action == "delete" || action == "info") && fs.NArg() == 1) {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	_, api, err := connect(*name)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "set":
+		if _, err := api.SetWebhook(tgbotapi.NewWebhook(fs.Arg(1))); err != nil {
+			return err
+		}
+		fmt.Printf("Webhook set to %s\n", fs.Arg(1))
+	case "delete":
+		if _, err := api.RemoveWebhook(); err != nil {
+			return err
+		}
+		fmt.Println("Webhook removed")
+	case "info":
+		info, err := api.GetWebhookInfo()
+		if err != nil {
+			return err
+		}
+		if !info.IsSet() {
+			fmt.Println("No webhook is set, the bot may poll for updates")
+			return nil
+		}
+		fmt.Printf("URL:              %s\n", info.URL)
+		fmt.Printf("Pending updates:  %d\n", info.PendingUpdateCount)
+		fmt.Printf("Own certificate:  %t\n", info.HasCustomCertificate)
+		if info.LastErrorDate != 0 {
+			at := time.Unix(int64(info.LastErrorDate), 0).Format(time.RFC3339)
+			fmt.Printf("Last error:       %s at %s\n", info.LastErrorMessage, at)
+		}
+	}
+	return nil
+}
+
+// whoami shows the bot the token belongs to.
+func whoami(args []string) error {
+	fs := flag.NewFlagSet("whoami", flag.ExitOnError)
+	name := fs.String("bot", "", "name of the bot, if BOTS lists several")
+	fs.Parse(args)
+
+	_, api, err := connect(*name)
+	if err != nil {
+		return err
+	}
+
+	// The client calls getMe when it is created.
+	me := api.Self
+	fmt.Printf("ID:        %d\n", me.ID)
+	fmt.Printf("Username:  @%s\n", me.UserName)
+	fmt.Printf("Name:      %s\n", strings.TrimSpace(me.FirstName+" "+me.LastName))
+	return nil
+}
+
+// send sends a text message, split like the messages of the bot.
+func send(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ExitOnError)
+	name := fs.String("bot", "", "name of the bot, if BOTS lists several")
+	chatID := fs.Int64("chat", 0, "ID of the chat to send to")
+	text := fs.String("text", "", "text of the message")
+	parseMode := fs.String("parse-mode", "", "formatting of the text: HTML or Markdown")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bot send [-bot NAME] -chat ID -text TEXT [-parse-mode MODE]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *chatID == 0 || *text == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, api, err := connect(*name)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(*chatID, *text)
+	msg.ParseMode = *parseMode
+	sent, err := bot.NewSplitSender(api, cfg.DocumentThreshold).Send(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Sent message %d\n", sent.MessageID)
+	return nil
+}
+
+// configCommand checks the configuration of every bot.
+func configCommand(args []string) error {
+	if len(args) != 1 || args[0] != "validate" {
+		fmt.Fprintln(os.Stderr, "Usage: bot config validate")
+		os.Exit(2)
+	}
+
+	cfgs, err := config.All()
+	if err != nil {
+		return err
+	}
+	for _, cfg := range cfgs {
+		if err := validate(cfg); err != nil {
+			return err
+		}
+	}
+
+	shared := cfgs[0]
+	store := shared.StoragePath
+	if store == "" {
+		store = "in memory"
+	}
+	fmt.Println("The configuration is valid")
+	fmt.Printf("Storage:    %s\n", store)
+	fmt.Printf("HTTP:       %s\n", orNone(shared.HTTPAddr))
+	for _, cfg := range cfgs {
+		mode := "polling"
+		if cfg.WebhookURL != "" {
+			mode = "webhook at " + cfg.WebhookURL
+		}
+
+		fmt.Println()
+		if cfg.Name != "" {
+			fmt.Printf("Bot %s\n", cfg.Name)
+		}
+		fmt.Printf("Token:      %s\n", maskToken(cfg.Token))
+		fmt.Printf("Updates:    %s\n", mode)
+		fmt.Printf("Language:   %s\n", cfg.DefaultLanguage)
+		fmt.Printf("Admins:     %d\n", len(cfg.AdminIDs))
+		fmt.Printf("Modules:    %s\n", modulesSummary(cfg))
+	}
+	return nil
+}
+
+// validate loads what the configuration of the bot refers to: the message
+// catalogs, the modules, the moderation policy and the report schedule.
+func validate(cfg config.Config) error {
+	prefix := ""
+	if cfg.Name != "" {
+		prefix = "bot " + cfg.Name + ": "
+	}
+
+	if _, err := i18n.Load(cfg.LocalesDir, cfg.DefaultLanguage); err != nil {
+		return fmt.Errorf("%serror while loading message catalogs: %s", prefix, err)
+	}
+	if _, err := newHost(nil, cfg); err != nil {
+		return fmt.Errorf("%serror while choosing the modules: %s", prefix, err)
+	}
+	if _, err := moderation.ParsePolicy(cfg.WarnActions); err != nil {
+		return fmt.Errorf("%serror while parsing WARN_ACTIONS: %s", prefix, err)
+	}
+	if cfg.StatsReport != "" {
+		if _, err := cron.Parse(cfg.StatsReport); err != nil {
+			return fmt.Errorf("%serror while parsing STATS_REPORT: %s", prefix, err)
+		}
+	}
+	return nil
+}
+
+// modulesSummary lists the modules enabled for the bot.
+func modulesSummary(cfg config.Config) string {
+	host, err := newHost(nil, cfg)
+	if err != nil {
+		return err.Error()
+	}
+	var names []string
+	for _, m := range host.Modules() {
+		names = append(names, m.Name())
+	}
+	return strings.Join(names, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "none"
+	}
+	return s
+}
+
+// maskToken hides the secret part of a bot token, keeping the bot ID.
+func maskToken(token string) string {
+	if i := strings.Index(token, ":"); i >= 0 {
+		return token[:i+1] + strings.Repeat("*", len(token)-i-1)
+	}
+	return strings.Repeat("*", len(token))
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/cron"
+	"github.com/nskondratev/go-telegram-bot-example/internal/health"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/leader"
+	"github.com/nskondratev/go-telegram-bot-example/internal/metrics"
+	"github.com/nskondratev/go-telegram-bot-example/internal/module"
+	"github.com/nskondratev/go-telegram-bot-example/internal/poller"
+	"github.com/nskondratev/go-telegram-bot-example/internal/queue"
+	"github.com/nskondratev/go-telegram-bot-example/internal/record"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+	"github.com/nskondratev/go-telegram-bot-example/internal/trace"
+)
+
+var (
+	updateBacklog = metrics.NewGaugeFuncVec("telegram_bot_update_backlog",
+		"Updates received but not handled yet.", "bot")
+	outboundQueue = metrics.NewGaugeFuncVec("telegram_bot_outbound_queue",
+		"Outgoing requests waiting for the flood limits.", "bot")
+	queuePending = metrics.NewGaugeFuncVec("telegram_bot_queue_pending",
+		"Updates in the queue not handled yet.", "bot")
+)
+
+// instance is one of the bots run by the process, with its own API client,
+// handlers, storage namespace and update source.
+type instance struct {
+	cfg      config.Config
+	tracer   *trace.Tracer
+	monitor  *health.Monitor
+	recorder *record.Recorder
+	debug    *bot.DebugTransport
+	api      *tgbotapi.BotAPI
+
+	bot      *bot.Bot
+	throttle *bot.Throttle
+	modules  *module.Host
+	queue    queue.Queue
+	updates  tgbotapi.UpdatesChannel
+}
+
+// newInstance creates the API client of the bot. The elector, if not nil,
+// fences its calls.
+func newInstance(cfg config.Config, tracer *trace.Tracer, elector *leader.Elector) (*instance, error) {
+	inst := &instance{cfg: cfg, tracer: tracer}
+
+	// API calls are counted, timed and traced, and the readiness probe
+	// watches the update polls.
+	inst.monitor = health.NewMonitor(cfg.ReadyTimeout, cfg.WebhookURL != "")
+	// The recording sees the requests as they are sent and the raw responses.
+	var next http.RoundTripper = http.DefaultTransport
+	if cfg.RecordFile != "" {
+		var err error
+		if inst.recorder, err = record.Create(cfg.RecordFile); err != nil {
+			return nil, inst.errorf("error while opening the recording: %s", err)
+		}
+		next = inst.recorder.Transport(next)
+	}
+	// Requests are logged as they go out, after the leader lock let them through.
+	inst.debug = &bot.DebugTransport{Next: next}
+	inst.debug.SetOn(cfg.Debug)
+	next = inst.debug
+	var transport http.RoundTripper = &bot.Transport{Next: next, Tracer: tracer, Bot: cfg.Name}
+
+	// With a leader lock only the leader may call the API, the other
+	// instances stand by until it goes away.
+	if elector != nil {
+		inst.monitor.Set("leader", health.Check{Detail: "standby"})
+		transport = elector.Fence(transport)
+	}
+
+	client := &http.Client{Transport: inst.monitor.Transport(transport)}
+	api, err := tgbotapi.NewBotAPIWithClient(cfg.Token, client)
+	if err != nil {
+		return nil, inst.errorf("%s", err)
+	}
+	inst.api = api
+	inst.monitor.API = api
+
+	return inst, nil
+}
+
+// start loads the state of the bot from its namespace of the shared store,
+// registers the handlers and jobs, and starts receiving updates.
+func (inst *instance) start(ctx context.Context, shared storage.Store, jobs *cron.Scheduler, mux *http.ServeMux, fail func(error)) error {
+	cfg := inst.cfg
+
+	// Message catalogs are validated while loading, so a missing translation
+	// stops the bot right at the start instead of showing a raw key to users.
+	bundle, err := i18n.Load(cfg.LocalesDir, cfg.DefaultLanguage)
+	if err != nil {
+		return inst.errorf("error while loading message catalogs: %s", err)
+	}
+
+	store := namespace(shared, cfg.Name)
+	inst.monitor.Store = store
+
+	// The stored data is brought up to date before anything reads it.
+	applied, err := storage.Migrate(store, "", migrations)
+	for _, m := range applied {
+		inst.logf("Applied storage migration %d: %s", m.Version, m.Name)
+	}
+	if err != nil {
+		return inst.errorf("error while migrating storage: %s", err)
+	}
+
+	if inst.queue, err = openQueue(cfg); err != nil {
+		return inst.errorf("error while opening the update queue: %s", err)
+	}
+
+	// Requests are throttled to the Telegram flood limits before they are split,
+	// so every part of a long message waits for its own slot.
+	b := bot.New(inst.api, store, bundle)
+	b.Name = cfg.Name
+	b.Tracer = inst.tracer
+	// Bot owners can switch the request logging at runtime with /debug.
+	b.Debug = inst.debug
+	inst.throttle = bot.NewThrottle(inst.api)
+	inst.throttle.Bot = cfg.Name
+	b.Sender = bot.NewSplitSender(inst.throttle, cfg.DocumentThreshold)
+	inst.bot = b
+
+	if inst.modules, err = newHost(b, cfg); err != nil {
+		return inst.errorf("error while choosing the modules: %s", err)
+	}
+	inst.modules.Logf = inst.logf
+	if err := inst.modules.Migrate(store); err != nil {
+		return inst.errorf("error while migrating storage: %s", err)
+	}
+	if err := register(inst.modules, b, cfg, jobs); err != nil {
+		return inst.errorf("error while initializing the modules: %s", err)
+	}
+
+	if cfg.WebhookURL != "" {
+		if inst.updates, err = listenWebhook(inst.api, mux, cfg.WebhookURL, inst.monitor.Received, inst.recorder); err != nil {
+			return inst.errorf("error while setting the webhook: %s", err)
+		}
+		return nil
+	}
+
+	poll := poller.New(inst.api)
+	poll.DeleteWebhook = cfg.DeleteWebhook
+	poll.OnState = func(state poller.State, err error) {
+		c := health.Check{OK: state == poller.Polling, Detail: string(state)}
+		if err != nil {
+			c.Detail += ": " + err.Error()
+		}
+		inst.monitor.Set("poller", c)
+	}
+
+	// Closing the channel when the poller gives up stops the bot too. The
+	// poller isn't waited for on shutdown, since a long poll in progress can't
+	// be interrupted; its updates are received again on the next start.
+	ch := make(chan tgbotapi.Update, inst.api.Buffer)
+	inst.updates = ch
+	go func() {
+		if err := poll.Run(ctx, ch); err != nil {
+			fail(inst.errorf("%s", err))
+		}
+		close(ch)
+	}()
+
+	return nil
+}
+
+// run starts the background work of the bot under wg and handles the updates
+// until the context is done or the updates stop.
+func (inst *instance) run(ctx context.Context, wg *sync.WaitGroup, fail func(error)) {
+	// The modules work in the background, like sending the due reminders.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		inst.modules.Run(ctx)
+	}()
+
+	updateBacklog.Set(func() float64 {
+		return float64(len(inst.updates))
+	}, inst.cfg.Name)
+	outboundQueue.Set(func() float64 {
+		return float64(inst.throttle.Waiting())
+	}, inst.cfg.Name)
+
+	// Now we're ready to start going through the updates we're given.
+	// The bot routes every update to the matching handler. On shutdown the
+	// webhook stays set, so Telegram keeps the updates until the next start.
+	q := inst.queue
+	if q == nil {
+		inst.bot.Run(ctx, inst.updates)
+		return
+	}
+
+	queuePending.Set(func() float64 {
+		return float64(q.Pending())
+	}, inst.cfg.Name)
+
+	// Every worker handles the updates of its chats in order.
+	for i := 0; i < q.Partitions(); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			queue.Work(ctx, q, i, inst.bot.HandleUpdate)
+		}(i)
+	}
+	if err := queue.Ingest(ctx, q, inst.updates); err != nil {
+		fail(inst.errorf("%s", err))
+	}
+}
+
+// close shuts the modules down and releases the queue and the recording of
+// the bot once it stopped.
+func (inst *instance) close() {
+	if inst.modules != nil {
+		inst.modules.Shutdown()
+	}
+	if inst.queue != nil {
+		if err := inst.queue.Close(); err != nil {
+			inst.logf("Failed to close the update queue: %s", err)
+		}
+	}
+	if inst.recorder != nil {
+		if err := inst.recorder.Close(); err != nil {
+			inst.logf("Failed to close the recording: %s", err)
+		}
+	}
+}
+
+// errorf returns an error naming the bot, if the process runs several.
+func (inst *instance) errorf(format string, args ...interface{}) error {
+	if inst.cfg.Name != "" {
+		format = "bot " + inst.cfg.Name + ": " + format
+	}
+	return fmt.Errorf(format, args...)
+}
+
+// logf logs a message starting with the name of the bot, if it has one.
+func (inst *instance) logf(format string, args ...interface{}) {
+	if inst.cfg.Name != "" {
+		format = "[" + inst.cfg.Name + "] " + format
+	}
+	log.Printf(format, args...)
+}
+
+// namespace returns the namespace of the named bot in the shared store. A
+// single unnamed bot uses the store as it is.
+func namespace(shared storage.Store, name string) storage.Store {
+	if name == "" {
+		return shared
+	}
+	return storage.NewNamespace(shared, name)
+}
+
+// listenWebhook sets the webhook and serves it on the path of the URL, so
+// the webhooks of several bots share the HTTP server. Use a hard to guess
+// path, since anyone knowing it can post fake updates. The recorder, if not
+// nil, records the posted updates.
+func listenWebhook(api *tgbotapi.BotAPI, mux *http.ServeMux, webhookURL string, received func(), recorder *record.Recorder) (tgbotapi.UpdatesChannel, error) {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return nil, err
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	if _, err := api.SetWebhook(tgbotapi.NewWebhook(webhookURL)); err != nil {
+		return nil, err
+	}
+
+	updates := make(chan tgbotapi.Update, api.Buffer)
+	h := bot.WebhookHandler(updates, received)
+	if recorder != nil {
+		h = recorder.Handler(h)
+	}
+	mux.Handle(path, h)
+	return updates, nil
+}
+
+// openQueue opens the update queue, nil if updates are handled as received.
+func openQueue(cfg config.Config) (queue.Queue, error) {
+	switch cfg.UpdateQueue {
+	case "memory":
+		return queue.NewMemory(cfg.QueueWorkers), nil
+	case "file":
+		return queue.OpenFile(cfg.QueueDir, cfg.QueueWorkers)
+	}
+	return nil, nil
+}
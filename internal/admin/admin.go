@@ -0,0 +1,140 @@
+// Package admin implements commands available to the bot owners only.
+package admin
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/audit"
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// bannedBucket stores the users whose updates are ignored.
+const bannedBucket = "banned_users"
+
+// StatsFunc returns lines added to the /stats output.
+type StatsFunc func(c *bot.Context) ([]string, error)
+
+// WhoisFunc returns lines added to the /whois output for the user or chat ID.
+type WhoisFunc func(c *bot.Context, id int64) []string
+
+// Admin implements the admin commands.
+type Admin struct {
+	bot   *bot.Bot
+	ids   map[int]bool
+	audit audit.Log
+
+	stats []StatsFunc
+	whois []WhoisFunc
+}
+
+// New creates the admin command suite for the bot owners with the given user IDs.
+func New(b *bot.Bot, ids []int) *Admin {
+	a := &Admin{
+		bot:   b,
+		ids:   make(map[int]bool, len(ids)),
+		audit: audit.Log{Store: b.Store},
+	}
+	for _, id := range ids {
+		a.ids[id] = true
+	}
+	return a
+}
+
+// Register adds the admin commands and the middleware ignoring banned users to the router.
+func (a *Admin) Register(r *bot.Router) {
+	r.Use(a.dropBanned)
+
+	r.Command("stats", a.Only(a.statsCommand))
+	r.Command("ban_user", a.Only(a.banUser))
+	r.Command("unban_user", a.Only(a.unbanUser))
+	r.Command("reload", a.Only(a.reload))
+	r.Command("debug", a.Only(a.debug))
+	r.Command("whois", a.Only(a.whoisCommand))
+	r.Command("health", a.Only(a.health))
+}
+
+// AddStats extends the /stats output of the bot.
+func (a *Admin) AddStats(f StatsFunc) {
+	a.stats = append(a.stats, f)
+}
+
+// AddWhois extends the /whois output of the bot.
+func (a *Admin) AddWhois(f WhoisFunc) {
+	a.whois = append(a.whois, f)
+}
+
+// IsAdmin reports whether the user is a bot owner.
+func (a *Admin) IsAdmin(userID int) bool {
+	return a.ids[userID]
+}
+
+// Only is middleware restricting a command to the bot owners.
+// Every invocation is recorded in the audit log, including denied ones.
+func (a *Admin) Only(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		m := c.Update.Message
+		if m == nil || m.From == nil {
+			return nil
+		}
+
+		entry := audit.Entry{
+			UserID: m.From.ID,
+			ChatID: m.Chat.ID,
+			Action: m.Command(),
+			Args:   m.CommandArguments(),
+		}
+
+		if !a.IsAdmin(m.From.ID) {
+			entry.Result = audit.ResultDenied
+			a.record(entry)
+			return c.Reply(c.T("admin.forbidden"))
+		}
+
+		err := next(c)
+
+		entry.Result = audit.ResultOK
+		if err != nil {
+			entry.Result = err.Error()
+		}
+		a.record(entry)
+
+		return err
+	}
+}
+
+func (a *Admin) record(e audit.Entry) {
+	if err := a.audit.Record(e); err != nil {
+		log.Printf("Failed to record audit entry %+v: %s", e, err)
+	}
+}
+
+// dropBanned stops the handling of updates from banned users.
+func (a *Admin) dropBanned(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		if u := c.From(); u != nil && !a.IsAdmin(u.ID) && a.banned(u.ID) {
+			return nil
+		}
+		return next(c)
+	}
+}
+
+func (a *Admin) banned(userID int) bool {
+	var banned bool
+	err := a.bot.Store.Get(bannedBucket, strconv.Itoa(userID), &banned)
+	if err != nil && err != storage.ErrNotFound {
+		log.Printf("Failed to check ban of user %d: %s", userID, err)
+	}
+	return banned
+}
+
+func chatTitle(ch *tgbotapi.Chat) string {
+	if ch.Title != "" {
+		return ch.Title
+	}
+	return strings.TrimSpace(ch.FirstName + " " + ch.LastName)
+}
@@ -0,0 +1,201 @@
+package admin
+
+import (
+	"context"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+func (a *Admin) statsCommand(c *bot.Context) error {
+	lines, err := a.statsLines(c)
+	if err != nil {
+		return err
+	}
+	return c.Reply(strings.Join(lines, "\n"))
+}
+
+// Report sends the /stats output to every bot owner in a private message. It
+// is run periodically as a cron job.
+func (a *Admin) Report(ctx context.Context) error {
+	for id := range a.ids {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// The report is written as a reply to the owner in the private chat.
+		c := &bot.Context{Bot: a.bot, Update: tgbotapi.Update{Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: id},
+			Chat: &tgbotapi.Chat{ID: int64(id), Type: "private"},
+		}}}
+		lines, err := a.statsLines(c)
+		if err != nil {
+			return err
+		}
+
+		text := c.T("admin.report") + "\n\n" + strings.Join(lines, "\n")
+		if _, err := c.Send(tgbotapi.NewMessage(int64(id), text)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Admin) statsLines(c *bot.Context) ([]string, error) {
+	st := a.bot.Stats()
+
+	banned, err := a.bot.Store.Keys(bannedBucket)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := a.audit.Count()
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{
+		c.T("admin.stats.uptime", "uptime", time.Since(st.Started).Round(time.Second)),
+		c.TN("admin.stats.updates", int(st.Updates), "errors", st.Errors),
+		c.TN("admin.stats.banned", len(banned)),
+		c.TN("admin.stats.audit", entries),
+	}
+	for _, f := range a.stats {
+		more, err := f(c)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, more...)
+	}
+
+	return lines, nil
+}
+
+func (a *Admin) banUser(c *bot.Context) error {
+	id, ok := userArg(c)
+	if !ok {
+		return c.Reply(c.T("admin.ban.usage"))
+	}
+	if a.IsAdmin(id) {
+		return c.Reply(c.T("admin.ban.admin"))
+	}
+
+	if err := a.bot.Store.Put(bannedBucket, strconv.Itoa(id), true); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("admin.ban.done", "id", id))
+}
+
+func (a *Admin) unbanUser(c *bot.Context) error {
+	id, ok := userArg(c)
+	if !ok {
+		return c.Reply(c.T("admin.unban.usage"))
+	}
+
+	if err := a.bot.Store.Delete(bannedBucket, strconv.Itoa(id)); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("admin.unban.done", "id", id))
+}
+
+func (a *Admin) reload(c *bot.Context) error {
+	if err := a.bot.I18n.Reload(); err != nil {
+		return c.Reply(c.T("admin.reload.failed", "error", err))
+	}
+	c.SetLang("")
+
+	return c.Reply(c.T("admin.reload.done", "languages", strings.Join(a.bot.I18n.Languages(), ", ")))
+}
+
+func (a *Admin) debug(c *bot.Context) error {
+	switch strings.ToLower(strings.TrimSpace(c.Update.Message.CommandArguments())) {
+	case "on":
+		a.bot.Debug.SetOn(true)
+	case "off":
+		a.bot.Debug.SetOn(false)
+	default:
+		return c.Reply(c.T("admin.debug.usage"))
+	}
+
+	if a.bot.Debug.On() {
+		return c.Reply(c.T("admin.debug.on"))
+	}
+	return c.Reply(c.T("admin.debug.off"))
+}
+
+func (a *Admin) whoisCommand(c *bot.Context) error {
+	id, err := strconv.ParseInt(strings.TrimSpace(c.Update.Message.CommandArguments()), 10, 64)
+	if err != nil {
+		return c.Reply(c.T("admin.whois.usage"))
+	}
+
+	info, err := a.bot.API.GetChat(tgbotapi.ChatConfig{ChatID: id})
+	if err != nil {
+		return c.Reply(c.T("admin.whois.unknown", "id", id, "error", err))
+	}
+
+	lines := []string{c.T("admin.whois.chat", "id", info.ID, "type", info.Type, "title", chatTitle(&info))}
+	if info.UserName != "" {
+		lines = append(lines, "@"+info.UserName)
+	}
+
+	for _, f := range a.whois {
+		lines = append(lines, f(c, id)...)
+	}
+	if info.IsPrivate() {
+		if lang, ok := a.bot.UserLanguage(int(id)); ok {
+			lines = append(lines, c.T("admin.whois.language", "language", lang))
+		}
+		if a.banned(int(id)) {
+			lines = append(lines, c.T("admin.whois.banned"))
+		}
+	}
+
+	return c.Reply(strings.Join(lines, "\n"))
+}
+
+func (a *Admin) health(c *bot.Context) error {
+	start := time.Now()
+	_, apiErr := a.bot.API.GetMe()
+	apiLatency := time.Since(start)
+
+	storageErr := storage.Check(a.bot.Store)
+
+	lines := []string{
+		c.T("admin.health.uptime", "uptime", time.Since(a.bot.Stats().Started).Round(time.Second)),
+		c.T("admin.health.goroutines", "goroutines", runtime.NumGoroutine()),
+	}
+	if apiErr != nil {
+		lines = append(lines, c.T("admin.health.api_failed", "error", apiErr))
+	} else {
+		lines = append(lines, c.T("admin.health.api_ok", "latency", apiLatency.Round(time.Millisecond)))
+	}
+	if storageErr != nil {
+		lines = append(lines, c.T("admin.health.storage_failed", "error", storageErr))
+	} else {
+		lines = append(lines, c.T("admin.health.storage_ok"))
+	}
+
+	return c.Reply(strings.Join(lines, "\n"))
+}
+
+// userArg parses the user ID argument of a command. Replying to a message
+// of the user works as well.
+func userArg(c *bot.Context) (int, bool) {
+	m := c.Update.Message
+	if arg := strings.TrimSpace(m.CommandArguments()); arg != "" {
+		id, err := strconv.Atoi(arg)
+		return id, err == nil
+	}
+	if m.ReplyToMessage != nil && m.ReplyToMessage.From != nil {
+		return m.ReplyToMessage.From.ID, true
+	}
+	return 0, false
+}
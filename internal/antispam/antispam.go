@@ -0,0 +1,286 @@
+// Package antispam detects and punishes spam and floods in groups.
+package antispam
+
+import (
+	"container/list"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+)
+
+// historyTTL is the time after the last message a history is forgotten.
+const historyTTL = 10 * time.Minute
+
+// Filter checks group messages against the spam rules configured for the chat.
+type Filter struct {
+	bot *bot.Bot
+	mod *moderation.Moderator
+
+	mu sync.Mutex
+	// history maps the senders to their elements of recent, which holds the
+	// histories ordered by the last message, so stale ones are at the front.
+	history map[string]*list.Element
+	recent  *list.List
+	regexps map[string]*regexp.Regexp
+}
+
+// history is the recent activity of a user in a chat.
+type history struct {
+	key     string
+	times   []time.Time
+	last    string
+	repeats int
+}
+
+// New creates a spam filter punishing senders through the moderator.
+func New(b *bot.Bot, mod *moderation.Moderator) *Filter {
+	return &Filter{
+		bot:     b,
+		mod:     mod,
+		history: make(map[string]*list.Element),
+		recent:  list.New(),
+		regexps: make(map[string]*regexp.Regexp),
+	}
+}
+
+// Register adds the spam checking middleware and the /antispam command to the router.
+func (f *Filter) Register(r *bot.Router) {
+	r.Use(f.check)
+	r.Command("antispam", f.mod.OnlyAdmins(f.command))
+}
+
+// check stops the handling of spam messages after acting against the sender.
+// Messages of group admins are never treated as spam.
+func (f *Filter) check(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		m := c.Update.Message
+		if m == nil || m.From == nil || !m.Chat.IsGroup() && !m.Chat.IsSuperGroup() {
+			return next(c)
+		}
+
+		chat, err := c.ChatSettings()
+		if err != nil {
+			return err
+		}
+		cfg := chat.Antispam
+		if !cfg.Enabled {
+			return next(c)
+		}
+
+		rule := f.detect(m, cfg)
+		if rule == "" {
+			return next(c)
+		}
+
+		admin, err := f.mod.IsAdmin(m.Chat.ID, m.From.ID)
+		if err != nil {
+			log.Printf("Failed to check admins of chat %d: %s", m.Chat.ID, err)
+			return next(c)
+		}
+		if admin {
+			return next(c)
+		}
+
+		return f.punish(c, rule, cfg)
+	}
+}
+
+// detect returns the first rule with an action the message breaks, or "" if
+// the message is fine.
+func (f *Filter) detect(m *tgbotapi.Message, cfg settings.Antispam) string {
+	flood, repeat := f.track(m, cfg)
+
+	text := m.Text
+	if text == "" {
+		text = m.Caption
+	}
+
+	for _, rule := range Rules {
+		if Action(cfg, rule) == ActionOff {
+			continue
+		}
+
+		var broken bool
+		switch rule {
+		case RuleForward:
+			broken = m.ForwardFromChat != nil && m.ForwardFromChat.IsChannel()
+		case RuleInvite:
+			broken = hasLink(m, isInvite)
+		case RuleLink:
+			broken = hasLink(m, func(*url.URL) bool { return true })
+		case RuleBlocklist:
+			broken = f.blocked(text, cfg.Blocklist)
+		case RuleRepeat:
+			broken = repeat
+		case RuleFlood:
+			broken = flood
+		}
+		if broken {
+			return rule
+		}
+	}
+
+	return ""
+}
+
+// track records the message and reports whether the sender is flooding and
+// repeating the same message.
+func (f *Filter) track(m *tgbotapi.Message, cfg settings.Antispam) (flood, repeat bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	for e := f.recent.Front(); e != nil; e = f.recent.Front() {
+		h := e.Value.(*history)
+		if now.Sub(h.times[len(h.times)-1]) <= historyTTL {
+			break
+		}
+		f.recent.Remove(e)
+		delete(f.history, h.key)
+	}
+
+	key := strconv.FormatInt(m.Chat.ID, 10) + "/" + strconv.Itoa(m.From.ID)
+	var h *history
+	if e, ok := f.history[key]; ok {
+		f.recent.MoveToBack(e)
+		h = e.Value.(*history)
+	} else {
+		h = &history{key: key}
+		f.history[key] = f.recent.PushBack(h)
+	}
+
+	// Only the messages within the window are kept.
+	times := h.times[:0]
+	for _, t := range h.times {
+		if now.Sub(t) <= cfg.FloodWindow {
+			times = append(times, t)
+		}
+	}
+	h.times = append(times, now)
+
+	text := m.Text + m.Caption
+	if text != "" && text == h.last {
+		h.repeats++
+	} else {
+		h.last, h.repeats = text, 1
+	}
+
+	return cfg.FloodMessages > 0 && len(h.times) > cfg.FloodMessages,
+		cfg.Repeats > 1 && h.repeats >= cfg.Repeats
+}
+
+// blocked reports whether the text matches a pattern of the blocklist.
+func (f *Filter) blocked(text string, patterns []string) bool {
+	if text == "" {
+		return false
+	}
+
+	for _, p := range patterns {
+		re, err := f.regexp(p)
+		if err != nil {
+			log.Printf("Invalid blocklist pattern %q: %s", p, err)
+			continue
+		}
+		if re.MatchString(text) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// regexp compiles the blocklist pattern, caching the result.
+func (f *Filter) regexp(pattern string) (*regexp.Regexp, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if re, ok := f.regexps[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	f.regexps[pattern] = re
+
+	return re, nil
+}
+
+// punish takes the action configured for the rule and deletes the message.
+func (f *Filter) punish(c *bot.Context, rule string, cfg settings.Antispam) error {
+	m := c.Update.Message
+	reason := c.T("antispam.rule." + rule)
+
+	var err error
+	switch Action(cfg, rule) {
+	case ActionWarn:
+		err = f.mod.Warn(c, m.From, reason)
+	case ActionMute:
+		err = f.mod.Mute(c, m.From, cfg.MuteFor)
+	case ActionBan:
+		err = f.mod.Ban(c, m.From, 0)
+	}
+
+	if _, delErr := f.bot.API.DeleteMessage(tgbotapi.NewDeleteMessage(m.Chat.ID, m.MessageID)); delErr != nil && err == nil {
+		err = delErr
+	}
+
+	return err
+}
+
+// compile compiles a blocklist pattern, which matches case-insensitively.
+func compile(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile("(?i)" + pattern)
+}
+
+// hasLink reports whether the message contains a link matching the filter.
+func hasLink(m *tgbotapi.Message, match func(*url.URL) bool) bool {
+	if m.Entities == nil {
+		return false
+	}
+
+	for _, e := range *m.Entities {
+		switch e.Type {
+		case "url":
+			// The URL of plain links is the text of the entity itself.
+			e.URL = bot.EntityText(m.Text, e)
+			if !strings.Contains(e.URL, "://") {
+				e.URL = "http://" + e.URL
+			}
+		case "text_link":
+		default:
+			continue
+		}
+
+		u, err := e.ParseURL()
+		if err == nil && match(u) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isInvite reports whether the URL invites to a Telegram chat.
+func isInvite(u *url.URL) bool {
+	if u.Scheme == "tg" {
+		return u.Host == "join"
+	}
+
+	switch strings.ToLower(strings.TrimPrefix(u.Hostname(), "www.")) {
+	case "t.me", "telegram.me", "telegram.dog":
+		return strings.HasPrefix(u.Path, "/joinchat/") || strings.HasPrefix(u.Path, "/+")
+	}
+	return false
+}
@@ -0,0 +1,188 @@
+package antispam
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+)
+
+func message(userID int, text string, entities ...tgbotapi.MessageEntity) *tgbotapi.Message {
+	m := &tgbotapi.Message{
+		From: &tgbotapi.User{ID: userID},
+		Chat: &tgbotapi.Chat{ID: -100, Type: "supergroup"},
+		Text: text,
+	}
+	if entities != nil {
+		m.Entities = &entities
+	}
+	return m
+}
+
+func TestIsInvite(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "https://t.me/joinchat/AAAAAEHbAQ", want: true},
+		{url: "https://t.me/+AbCdEf", want: true},
+		{url: "http://www.T.me/+AbCdEf", want: true},
+		{url: "https://telegram.me/joinchat/x", want: true},
+		{url: "https://telegram.dog/+x", want: true},
+		{url: "tg://join?invite=AbCdEf", want: true},
+		{url: "tg://resolve?domain=channel"},
+		{url: "https://t.me/channel"},
+		{url: "https://t.me/channel/joinchat/x"},
+		{url: "https://example.com/joinchat/x"},
+		{url: "https://t.me.example.com/+x"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := isInvite(u); got != tt.want {
+			t.Errorf("isInvite(%s) = %v, want %v", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestHasLink(t *testing.T) {
+	tests := []struct {
+		name string
+		m    *tgbotapi.Message
+		want bool
+	}{
+		{name: "no entities", m: message(1, "t.me/+x")},
+		{name: "not a link", m: message(1, "hi @all", tgbotapi.MessageEntity{Type: "mention", Offset: 3, Length: 4})},
+		{name: "plain link", m: message(1, "join t.me/+x now", tgbotapi.MessageEntity{Type: "url", Offset: 5, Length: 7}), want: true},
+		{name: "plain link with scheme", m: message(1, "https://t.me/joinchat/x", tgbotapi.MessageEntity{Type: "url", Length: 23}), want: true},
+		{name: "other plain link", m: message(1, "see t.me/channel", tgbotapi.MessageEntity{Type: "url", Offset: 4, Length: 12})},
+		{name: "text link", m: message(1, "join", tgbotapi.MessageEntity{Type: "text_link", Length: 4, URL: "https://t.me/+x"}), want: true},
+		{name: "after an emoji", m: message(1, "👋 t.me/+x", tgbotapi.MessageEntity{Type: "url", Offset: 3, Length: 7}), want: true},
+	}
+	for _, tt := range tests {
+		if got := hasLink(tt.m, isInvite); got != tt.want {
+			t.Errorf("%s: hasLink() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrack(t *testing.T) {
+	f := New(nil, nil)
+	cfg := settings.Antispam{FloodMessages: 3, FloodWindow: time.Minute, Repeats: 2}
+
+	tests := []struct {
+		m             *tgbotapi.Message
+		flood, repeat bool
+	}{
+		{m: message(1, "a")},
+		{m: message(1, "b")},
+		{m: message(2, "b")},
+		{m: message(1, "b"), repeat: true},
+		{m: message(1, "c"), flood: true},
+		{m: message(2, "")},
+		{m: message(2, "")},
+	}
+	for i, tt := range tests {
+		flood, repeat := f.track(tt.m, cfg)
+		if flood != tt.flood || repeat != tt.repeat {
+			t.Errorf("message %d: track() = %v, %v; want %v, %v", i, flood, repeat, tt.flood, tt.repeat)
+		}
+	}
+
+	// Messages out of the window don't count.
+	h := f.history["-100/1"].Value.(*history)
+	for i := range h.times {
+		h.times[i] = h.times[i].Add(-2 * time.Minute)
+	}
+	if flood, _ := f.track(message(1, "d"), cfg); flood || len(h.times) != 1 {
+		t.Errorf("track() after the window = %v with %d messages", flood, len(h.times))
+	}
+}
+
+func TestTrackForgets(t *testing.T) {
+	f := New(nil, nil)
+	cfg := settings.Default().Antispam
+	for id := 1; id <= 3; id++ {
+		f.track(message(id, "hi"), cfg)
+	}
+	// The first two senders go quiet, and the first one writes again later.
+	for _, k := range []string{"-100/1", "-100/2"} {
+		h := f.history[k].Value.(*history)
+		h.times[0] = h.times[0].Add(-2 * historyTTL)
+	}
+	f.track(message(1, "hi"), cfg)
+
+	if _, ok := f.history["-100/2"]; ok || len(f.history) != 2 || f.recent.Len() != 2 {
+		t.Errorf("tracking %d senders, %d in order; want the stale one forgotten", len(f.history), f.recent.Len())
+	}
+	if h := f.recent.Back().Value.(*history); h.key != "-100/1" || h.repeats != 1 {
+		t.Errorf("latest history = %+v, want a new one of the first sender", h)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	channel := message(1, "news")
+	channel.ForwardFromChat = &tgbotapi.Chat{ID: -1001, Type: "channel"}
+	group := message(1, "news")
+	group.ForwardFromChat = &tgbotapi.Chat{ID: -1002, Type: "supergroup"}
+	photo := message(1, "")
+	photo.Caption = "Cheap CASINO here"
+
+	tests := []struct {
+		name    string
+		m       *tgbotapi.Message
+		actions map[string]string
+		want    string
+	}{
+		{name: "fine", m: message(1, "hello")},
+		{name: "channel forward off", m: channel},
+		{name: "channel forward", m: channel, actions: map[string]string{RuleForward: ActionDelete}, want: RuleForward},
+		{name: "group forward", m: group, actions: map[string]string{RuleForward: ActionDelete}},
+		{name: "invite", m: message(1, "t.me/+x", tgbotapi.MessageEntity{Type: "url", Length: 7}), want: RuleInvite},
+		{name: "link off", m: message(1, "example.com", tgbotapi.MessageEntity{Type: "url", Length: 11})},
+		{name: "link", m: message(1, "example.com", tgbotapi.MessageEntity{Type: "url", Length: 11}), actions: map[string]string{RuleLink: ActionWarn}, want: RuleLink},
+		{name: "invite before link", m: message(1, "t.me/+x", tgbotapi.MessageEntity{Type: "url", Length: 7}), actions: map[string]string{RuleLink: ActionWarn}, want: RuleInvite},
+		{name: "invite off", m: message(1, "t.me/+x", tgbotapi.MessageEntity{Type: "url", Length: 7}), actions: map[string]string{RuleInvite: ActionOff}},
+		{name: "blocklist", m: message(1, "best Casino ever"), want: RuleBlocklist},
+		{name: "blocklist caption", m: photo, want: RuleBlocklist},
+		{name: "blocklist off", m: photo, actions: map[string]string{RuleBlocklist: ActionOff}},
+	}
+	for _, tt := range tests {
+		// Every message is from a new sender, so the history doesn't matter.
+		f := New(nil, nil)
+		cfg := settings.Default().Antispam
+		cfg.Actions = tt.actions
+		cfg.Blocklist = []string{"[", "casino"}
+		if got := f.detect(tt.m, cfg); got != tt.want {
+			t.Errorf("%s: detect() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Repeats and floods are detected once the sender has a history.
+	f := New(nil, nil)
+	cfg := settings.Default().Antispam
+	var got []string
+	for _, text := range []string{"a", "a", "a", "b", "c", "d"} {
+		got = append(got, f.detect(message(1, text), cfg))
+	}
+	if want := []string{"", "", RuleRepeat, "", "", RuleFlood}; !equal(got, want) {
+		t.Errorf("detect() = %q, want %q", got, want)
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
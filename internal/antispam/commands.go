@@ -0,0 +1,165 @@
+package antispam
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+)
+
+// command handles /antispam. Without arguments it shows the configuration of
+// the chat, otherwise it changes one setting:
+//
+//	/antispam on|off
+//	/antispam <rule> <action>
+//	/antispam flood <messages> <window>
+//	/antispam repeat <messages>
+//	/antispam mute <duration>
+//	/antispam block <pattern>
+//	/antispam unblock <pattern>
+func (f *Filter) command(c *bot.Context) error {
+	m := c.Update.Message
+
+	args := strings.Fields(m.CommandArguments())
+	if len(args) == 0 {
+		chat, err := f.bot.Settings.Get(m.Chat.ID)
+		if err != nil {
+			return err
+		}
+		return c.Reply(status(c, chat.Antispam))
+	}
+
+	var cfg settings.Antispam
+	var problem string
+	err := f.bot.Settings.Update(m.Chat.ID, m.From.ID, "antispam "+strings.Join(args, " "), func(chat *settings.Chat) error {
+		if problem = change(c, &chat.Antispam, strings.ToLower(args[0]), args[1:]); problem != "" {
+			return errRejected
+		}
+		cfg = chat.Antispam
+		return nil
+	})
+	switch {
+	case err == errRejected:
+		return c.Reply(problem)
+	case err != nil:
+		return err
+	}
+
+	return c.Reply(status(c, cfg))
+}
+
+// errRejected keeps invalid changes from being stored.
+var errRejected = errors.New("antispam: change rejected")
+
+// change applies the /antispam subcommand name with its arguments to cfg.
+// It returns the reply explaining the problem if the change is invalid.
+func change(c *bot.Context, cfg *settings.Antispam, name string, args []string) string {
+	usage := c.T("antispam.usage", "rules", strings.Join(Rules, ", "), "actions", strings.Join(Actions, ", "))
+
+	switch {
+	case name == "on" && len(args) == 0:
+		cfg.Enabled = true
+	case name == "off" && len(args) == 0:
+		cfg.Enabled = false
+	case contains(Rules, name) && len(args) == 1 && contains(Actions, strings.ToLower(args[0])):
+		if cfg.Actions == nil {
+			cfg.Actions = make(map[string]string)
+		}
+		cfg.Actions[name] = strings.ToLower(args[0])
+	case name == "flood" && len(args) == 2:
+		if !parseFlood(cfg, args[0], args[1]) {
+			return usage
+		}
+	case name == "repeat" && len(args) == 1:
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 2 {
+			return usage
+		}
+		cfg.Repeats = n
+	case name == "mute" && len(args) == 1:
+		d, err := moderation.ParseDuration(args[0])
+		if err != nil {
+			return usage
+		}
+		cfg.MuteFor = d
+	case name == "block" && len(args) > 0:
+		pattern := strings.Join(args, " ")
+		if _, err := compile(pattern); err != nil {
+			return c.T("antispam.invalid_pattern", "error", err)
+		}
+		if !contains(cfg.Blocklist, pattern) {
+			cfg.Blocklist = append(cfg.Blocklist, pattern)
+		}
+	case name == "unblock" && len(args) > 0:
+		pattern := strings.Join(args, " ")
+		if !contains(cfg.Blocklist, pattern) {
+			return c.T("antispam.unknown_pattern", "pattern", pattern)
+		}
+		cfg.Blocklist = remove(cfg.Blocklist, pattern)
+	default:
+		return usage
+	}
+
+	return ""
+}
+
+// status describes the configuration.
+func status(c *bot.Context, cfg settings.Antispam) string {
+	lines := []string{c.T("antispam.status.off")}
+	if cfg.Enabled {
+		lines[0] = c.T("antispam.status.on")
+	}
+
+	for _, rule := range Rules {
+		lines = append(lines, c.T("antispam.status.rule",
+			"rule", rule, "name", c.T("antispam.rule."+rule), "action", Action(cfg, rule)))
+	}
+
+	lines = append(lines,
+		c.T("antispam.status.flood", "messages", cfg.FloodMessages, "window", cfg.FloodWindow),
+		c.T("antispam.status.repeat", "messages", cfg.Repeats),
+		c.T("antispam.status.mute", "duration", cfg.MuteFor),
+	)
+	if len(cfg.Blocklist) > 0 {
+		lines = append(lines, c.T("antispam.status.blocklist", "patterns", strings.Join(cfg.Blocklist, "\n")))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func parseFlood(cfg *settings.Antispam, messages, window string) bool {
+	n, err := strconv.Atoi(messages)
+	if err != nil || n < 1 {
+		return false
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return false
+	}
+
+	cfg.FloodMessages, cfg.FloodWindow = n, d
+	return true
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func remove(list []string, s string) []string {
+	out := list[:0]
+	for _, v := range list {
+		if v != s {
+			out = append(out, v)
+		}
+	}
+	return out
+}
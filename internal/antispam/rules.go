@@ -0,0 +1,47 @@
+package antispam
+
+import "github.com/nskondratev/go-telegram-bot-example/internal/settings"
+
+// Rules detecting spam, in the order they are checked. The configuration of
+// the rules is part of the chat settings, see settings.Antispam.
+const (
+	RuleForward   = "forward"
+	RuleInvite    = "invite"
+	RuleLink      = "link"
+	RuleBlocklist = "blocklist"
+	RuleRepeat    = "repeat"
+	RuleFlood     = "flood"
+)
+
+// Rules lists all rules in the order they are checked.
+var Rules = []string{RuleForward, RuleInvite, RuleLink, RuleBlocklist, RuleRepeat, RuleFlood}
+
+// Actions taken against the sender of spam. Every action but off deletes the message.
+const (
+	ActionOff    = "off"
+	ActionDelete = "delete"
+	ActionWarn   = "warn"
+	ActionMute   = "mute"
+	ActionBan    = "ban"
+)
+
+// Actions lists all actions.
+var Actions = []string{ActionOff, ActionDelete, ActionWarn, ActionMute, ActionBan}
+
+// defaultActions are taken when the chat admins have not chosen another one.
+var defaultActions = map[string]string{
+	RuleForward:   ActionOff,
+	RuleInvite:    ActionDelete,
+	RuleLink:      ActionOff,
+	RuleBlocklist: ActionDelete,
+	RuleRepeat:    ActionDelete,
+	RuleFlood:     ActionMute,
+}
+
+// Action returns the action taken in the chat when the rule matches.
+func Action(cfg settings.Antispam, rule string) string {
+	if action, ok := cfg.Actions[rule]; ok {
+		return action
+	}
+	return defaultActions[rule]
+}
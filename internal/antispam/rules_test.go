@@ -0,0 +1,39 @@
+package antispam
+
+import (
+	"testing"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+)
+
+func TestDefaultActions(t *testing.T) {
+	for _, rule := range Rules {
+		if !contains(Actions, defaultActions[rule]) {
+			t.Errorf("rule %q has no valid default action: %q", rule, defaultActions[rule])
+		}
+	}
+	if len(defaultActions) != len(Rules) {
+		t.Errorf("%d default actions for %d rules", len(defaultActions), len(Rules))
+	}
+}
+
+func TestAction(t *testing.T) {
+	tests := []struct {
+		actions map[string]string
+		rule    string
+		want    string
+	}{
+		{actions: nil, rule: RuleFlood, want: ActionMute},
+		{actions: nil, rule: RuleLink, want: ActionOff},
+		{actions: map[string]string{RuleLink: ActionBan}, rule: RuleLink, want: ActionBan},
+		{actions: map[string]string{RuleLink: ActionBan}, rule: RuleInvite, want: ActionDelete},
+		{actions: map[string]string{RuleFlood: ActionOff}, rule: RuleFlood, want: ActionOff},
+	}
+	for _, tt := range tests {
+		cfg := settings.Default().Antispam
+		cfg.Actions = tt.actions
+		if got := Action(cfg, tt.rule); got != tt.want {
+			t.Errorf("Action(%v, %q) = %q, want %q", tt.actions, tt.rule, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+// Package audit keeps a log of privileged actions in the storage.
+package audit
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const bucket = "audit"
+
+// seq tells apart the keys of entries recorded at the same time.
+var seq uint32
+
+// Entry is a single audited action.
+type Entry struct {
+	Time   time.Time `json:"time"`
+	UserID int       `json:"user_id"`
+	ChatID int64     `json:"chat_id,omitempty"`
+	Action string    `json:"action"`
+	Args   string    `json:"args,omitempty"`
+	// Result is "ok", "denied" or the error message.
+	Result string `json:"result"`
+}
+
+// Results of audited actions besides error messages.
+const (
+	ResultOK     = "ok"
+	ResultDenied = "denied"
+)
+
+// Log appends entries to the audit bucket of the store.
+type Log struct {
+	Store storage.Store
+}
+
+// Record stores the entry. A zero entry time is set to the current time.
+func (l Log) Record(e Entry) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	// Keys are zero padded timestamps followed by a sequence number, so they
+	// sort chronologically and entries recorded at once don't overwrite each other.
+	key := fmt.Sprintf("%020d-%010d", e.Time.UnixNano(), atomic.AddUint32(&seq, 1))
+	return l.Store.Put(bucket, key, e)
+}
+
+// Count returns the number of entries.
+func (l Log) Count() (int, error) {
+	keys, err := l.Store.Keys(bucket)
+	return len(keys), err
+}
+
+// Last returns up to n latest entries, newest first.
+func (l Log) Last(n int) ([]Entry, error) {
+	keys, err := l.Store.Keys(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, n)
+	for i := len(keys) - 1; i >= 0 && len(entries) < n; i-- {
+		var e Entry
+		if err := l.Store.Get(bucket, keys[i], &e); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// Prune deletes the entries recorded before the time and returns their number.
+func (l Log) Prune(before time.Time) (int, error) {
+	keys, err := l.Store.Keys(bucket)
+	if err != nil {
+		return 0, err
+	}
+
+	last := fmt.Sprintf("%020d", before.UnixNano())
+	n := 0
+	for _, k := range keys {
+		if k >= last {
+			break
+		}
+		if err := l.Store.Delete(bucket, k); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
@@ -0,0 +1,67 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+func TestRecordSameTime(t *testing.T) {
+	l := Log{Store: storage.NewMemory()}
+	at := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, action := range []string{"ban", "unban", "debug"} {
+		if err := l.Record(Entry{Time: at, Action: action}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := l.Count(); err != nil || n != 3 {
+		t.Fatalf("Count() = %d, %v; want 3", n, err)
+	}
+	last, err := l.Last(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range last {
+		got = append(got, e.Action)
+	}
+	if want := []string{"debug", "unban", "ban"}; len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("Last(3) = %v, want %v", got, want)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	l := Log{Store: storage.NewMemory()}
+	at := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 4; i++ {
+		if err := l.Record(Entry{Time: at.Add(time.Duration(i) * time.Hour), Action: "ban"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		before time.Time
+		pruned int
+		left   int
+	}{
+		{before: at, pruned: 0, left: 4},
+		{before: at.Add(90 * time.Minute), pruned: 2, left: 2},
+		{before: at.Add(90 * time.Minute), pruned: 0, left: 2},
+		{before: at.Add(3 * time.Hour), pruned: 1, left: 1},
+		{before: at.Add(4 * time.Hour), pruned: 1, left: 0},
+	}
+	for _, tt := range tests {
+		n, err := l.Prune(tt.before)
+		if err != nil {
+			t.Fatal(err)
+		}
+		left, _ := l.Count()
+		if n != tt.pruned || left != tt.left {
+			t.Errorf("Prune(%s) = %d leaving %d, want %d leaving %d", tt.before, n, left, tt.pruned, tt.left)
+		}
+	}
+}
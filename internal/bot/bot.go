@@ -0,0 +1,173 @@
+// Package bot dispatches Telegram updates to handlers.
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+	"github.com/nskondratev/go-telegram-bot-example/internal/trace"
+)
+
+// languageBucket stores the language chosen by a user with the /language command.
+const languageBucket = "user_language"
+
+// Bot ties the Telegram API client together with the state and the handlers.
+type Bot struct {
+	// Name tells the bots of a process apart in metrics, spans and logs. It
+	// is empty when the process runs a single bot.
+	Name     string
+	API      *tgbotapi.BotAPI
+	Sender   Sender
+	Store    storage.Store
+	Settings *settings.Store
+	I18n     *i18n.Bundle
+	Router   *Router
+	// Tracer records a span per update, nil disables tracing.
+	Tracer *trace.Tracer
+	// Debug switches the logging of the API requests, if the client sends
+	// them through it.
+	Debug *DebugTransport
+
+	started  time.Time
+	counters *counters
+}
+
+// counters are allocated separately to keep them 64-bit aligned for atomic access.
+type counters struct {
+	updates int64
+	errors  int64
+}
+
+// Stats is a snapshot of the update handling statistics.
+type Stats struct {
+	Started time.Time
+	Updates int64
+	Errors  int64
+}
+
+// New creates a bot with an empty router. Messages are sent through a
+// SplitSender without the document fallback.
+func New(api *tgbotapi.BotAPI, store storage.Store, bundle *i18n.Bundle) *Bot {
+	return &Bot{
+		API:      api,
+		Sender:   NewSplitSender(api, 0),
+		Store:    store,
+		Settings: &settings.Store{Store: store},
+		I18n:     bundle,
+		Router:   NewRouter(),
+		Debug:    new(DebugTransport),
+
+		started:  time.Now(),
+		counters: new(counters),
+	}
+}
+
+// Run handles updates until the channel is closed or the context is done.
+func (b *Bot) Run(ctx context.Context, updates tgbotapi.UpdatesChannel) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			b.HandleUpdate(update)
+		}
+	}
+}
+
+// HandleUpdate dispatches a single update. Handler errors and panics are logged.
+func (b *Bot) HandleUpdate(update tgbotapi.Update) {
+	atomic.AddInt64(&b.counters.updates, 1)
+
+	kind := UpdateKind(update)
+	updatesTotal.With(b.Name, kind).Inc()
+	start := time.Now()
+
+	c := &Context{Bot: b, Update: update}
+	c.span = b.Tracer.Start("update "+kind, trace.KindInternal)
+	c.span.SetAttribute("telegram.update_id", update.UpdateID)
+	c.span.SetAttribute("telegram.update_kind", kind)
+	if b.Name != "" {
+		c.span.SetAttribute("telegram.bot", b.Name)
+	}
+	if chat := c.Chat(); chat != nil {
+		c.span.SetAttribute("telegram.chat_id", chat.ID)
+	}
+	if from := c.From(); from != nil {
+		c.span.SetAttribute("telegram.user_id", from.ID)
+	}
+
+	outcome := "panic"
+	defer func() {
+		handlerDuration.With(b.Name, kind).Observe(time.Since(start).Seconds())
+		handlerResults.With(b.Name, outcome).Inc()
+
+		if r := recover(); r != nil {
+			atomic.AddInt64(&b.counters.errors, 1)
+			log.Printf("%sPanic while handling update %d: %v\n%s", b.logPrefix(), update.UpdateID, r, debug.Stack())
+			c.span.SetError(fmt.Errorf("panic: %v", r))
+		}
+
+		c.span.SetAttribute("outcome", outcome)
+		c.span.Finish()
+	}()
+
+	if err := b.Router.Dispatch(c); err != nil {
+		c.span.SetError(err)
+		outcome = "error"
+		atomic.AddInt64(&b.counters.errors, 1)
+		log.Printf("%sFailed to handle update %d: %s", b.logPrefix(), update.UpdateID, err)
+		return
+	}
+	outcome = "ok"
+}
+
+// logPrefix starts the log lines of the bot with its name, if it has one.
+func (b *Bot) logPrefix() string {
+	if b.Name == "" {
+		return ""
+	}
+	return "[" + b.Name + "] "
+}
+
+// Stats returns the update handling statistics since the bot was created.
+func (b *Bot) Stats() Stats {
+	return Stats{
+		Started: b.started,
+		Updates: atomic.LoadInt64(&b.counters.updates),
+		Errors:  atomic.LoadInt64(&b.counters.errors),
+	}
+}
+
+// UserLanguage returns the language explicitly chosen by the user.
+func (b *Bot) UserLanguage(userID int) (string, bool) {
+	var lang string
+	if err := b.Store.Get(languageBucket, strconv.Itoa(userID), &lang); err != nil {
+		if err != storage.ErrNotFound {
+			log.Printf("Failed to load language of user %d: %s", userID, err)
+		}
+		return "", false
+	}
+	return lang, true
+}
+
+// SetUserLanguage stores the language chosen by the user.
+// An empty language removes the choice, so the Telegram client language is used again.
+func (b *Bot) SetUserLanguage(userID int, lang string) error {
+	if lang == "" {
+		return b.Store.Delete(languageBucket, strconv.Itoa(userID))
+	}
+	return b.Store.Put(languageBucket, strconv.Itoa(userID), lang)
+}
@@ -0,0 +1,248 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"unicode/utf16"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+	"github.com/nskondratev/go-telegram-bot-example/internal/trace"
+)
+
+// Context is passed to handlers and carries the update being handled.
+type Context struct {
+	Bot    *Bot
+	Update tgbotapi.Update
+
+	lang string
+	span *trace.Span
+	// chat caches the settings of the chat of the update, see ChatSettings.
+	chat *chatSettings
+}
+
+type chatSettings struct {
+	cfg settings.Chat
+	err error
+}
+
+// Span returns the span of the handler being run, nil if tracing is disabled.
+func (c *Context) Span() *trace.Span {
+	return c.span
+}
+
+// trace runs h in a child span of the current one.
+func (c *Context) trace(name string, h HandlerFunc) error {
+	parent := c.span
+	if parent == nil {
+		return h(c)
+	}
+
+	c.span = parent.Child(name, trace.KindInternal)
+	defer func() { c.span = parent }()
+
+	err := h(c)
+	c.span.SetError(err)
+	c.span.Finish()
+	return err
+}
+
+// ChatSettings returns the settings of the chat of the update, or the
+// default ones for updates without a chat. They are loaded once per update,
+// so routing checks don't read the store again and again; handlers changing
+// the settings read them from Bot.Settings and call SetLang("") to drop the
+// loaded ones. A failure to load them is logged once and returned every time.
+func (c *Context) ChatSettings() (settings.Chat, error) {
+	if c.chat == nil {
+		c.chat = &chatSettings{cfg: settings.Default()}
+		if ch := c.Chat(); ch != nil {
+			c.chat.cfg, c.chat.err = c.Bot.Settings.Get(ch.ID)
+			if c.chat.err != nil {
+				log.Printf("%sFailed to load settings of chat %d: %s", c.Bot.logPrefix(), ch.ID, c.chat.err)
+			}
+		}
+	}
+	return c.chat.cfg, c.chat.err
+}
+
+// Message returns the message of the update. For callback queries it is the
+// message the pressed button belongs to. It returns nil for other updates.
+func (c *Context) Message() *tgbotapi.Message {
+	switch {
+	case c.Update.Message != nil:
+		return c.Update.Message
+	case c.Update.CallbackQuery != nil:
+		return c.Update.CallbackQuery.Message
+	}
+	return nil
+}
+
+// Chat returns the chat the update came from or nil if it is unknown.
+func (c *Context) Chat() *tgbotapi.Chat {
+	if m := c.Message(); m != nil {
+		return m.Chat
+	}
+	return nil
+}
+
+// From returns the user who sent the update or nil if it is unknown.
+func (c *Context) From() *tgbotapi.User {
+	switch {
+	case c.Update.Message != nil:
+		return c.Update.Message.From
+	case c.Update.CallbackQuery != nil:
+		return c.Update.CallbackQuery.From
+	case c.Update.InlineQuery != nil:
+		return c.Update.InlineQuery.From
+	}
+	return nil
+}
+
+// Lang returns the language replies should be written in.
+//
+// The language chosen by the chat admins in /settings takes precedence over
+// the language chosen with /language, which takes precedence over the language
+// of the user's Telegram client. The bundle fallback language is used if none
+// is supported.
+func (c *Context) Lang() string {
+	if c.lang != "" {
+		return c.lang
+	}
+
+	var candidates []string
+	if ch := c.Chat(); ch != nil && !ch.IsPrivate() {
+		// A load failure is logged by ChatSettings.
+		if cfg, err := c.ChatSettings(); err == nil && cfg.Language != "" {
+			candidates = append(candidates, cfg.Language)
+		}
+	}
+	if u := c.From(); u != nil {
+		if lang, ok := c.Bot.UserLanguage(u.ID); ok {
+			candidates = append(candidates, lang)
+		}
+		candidates = append(candidates, u.LanguageCode)
+	}
+	c.lang = c.Bot.I18n.Match(candidates...)
+
+	return c.lang
+}
+
+// SetLang overrides the language of replies for the rest of the update
+// handling. An empty language is derived again, from the chat settings
+// loaded again in case the handler changed them.
+func (c *Context) SetLang(lang string) {
+	c.lang = lang
+	if lang == "" {
+		c.chat = nil
+	}
+}
+
+// T translates the message key into the reply language. See i18n.Bundle.Translate.
+func (c *Context) T(key string, args ...interface{}) string {
+	return c.Bot.I18n.Translate(c.Lang(), key, args...)
+}
+
+// TN translates the plural message key for n into the reply language. See i18n.Bundle.Plural.
+func (c *Context) TN(key string, n int, args ...interface{}) string {
+	return c.Bot.I18n.Plural(c.Lang(), key, n, args...)
+}
+
+// Send sends a chattable through the bot sender. The call is recorded in a
+// child span of the handler, which includes the flood limit waits.
+func (c *Context) Send(msg tgbotapi.Chattable) (tgbotapi.Message, error) {
+	span := c.span.Child("telegram send", trace.KindClient)
+	span.SetAttribute("telegram.request", fmt.Sprintf("%T", msg))
+	m, err := c.Bot.Sender.Send(msg)
+	span.SetError(err)
+	span.Finish()
+	return m, err
+}
+
+// Answer answers the callback query of the update. A non-empty text is shown
+// to the user as a notification, or as an alert if alert is true.
+func (c *Context) Answer(text string, alert bool) error {
+	q := c.Update.CallbackQuery
+	if q == nil {
+		return nil
+	}
+
+	cfg := tgbotapi.NewCallback(q.ID, text)
+	cfg.ShowAlert = alert
+	span := c.span.Child("telegram answerCallbackQuery", trace.KindClient)
+	_, err := c.Bot.API.AnswerCallbackQuery(cfg)
+	span.SetError(err)
+	span.Finish()
+
+	return err
+}
+
+// Reply sends text to the chat of the update as a reply to the update message.
+func (c *Context) Reply(text string) error {
+	m := c.Message()
+	if m == nil {
+		return nil
+	}
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	if c.Update.Message != nil {
+		msg.ReplyToMessageID = m.MessageID
+	}
+
+	_, err := c.Send(msg)
+
+	return err
+}
+
+// ReplyText sends formatted text in the given parse mode as a reply to the update
+// message. The markup is validated before sending.
+func (c *Context) ReplyText(mode, text string) error {
+	m := c.Message()
+	if m == nil {
+		return nil
+	}
+
+	if err := render.Validate(text, mode); err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	msg.ParseMode = mode
+	if c.Update.Message != nil {
+		msg.ReplyToMessageID = m.MessageID
+	}
+
+	_, err := c.Send(msg)
+
+	return err
+}
+
+// ReplyTemplate renders the template with data and sends the result as ReplyText does.
+func (c *Context) ReplyTemplate(t *render.Template, data interface{}) error {
+	text, err := t.Execute(data)
+	if err != nil {
+		return err
+	}
+	return c.ReplyText(t.Mode(), text)
+}
+
+// ReplyFormat uses the translation of key as a template in the given parse mode,
+// renders it with data and sends the result as ReplyText does.
+func (c *Context) ReplyFormat(mode, key string, data interface{}) error {
+	t, err := render.Parse(mode, key, c.T(key))
+	if err != nil {
+		return err
+	}
+	return c.ReplyTemplate(t, data)
+}
+
+// EntityText returns the part of the text covered by the entity. Entity
+// offsets are counted in UTF-16 code units.
+func EntityText(text string, e tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	if e.Offset < 0 || e.Length < 0 || e.Offset+e.Length > len(units) {
+		return ""
+	}
+	return string(utf16.Decode(units[e.Offset : e.Offset+e.Length]))
+}
@@ -0,0 +1,151 @@
+package bot
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+	"github.com/nskondratev/go-telegram-bot-example/internal/trace"
+)
+
+// spans collects the exported spans.
+type spans struct {
+	mu   sync.Mutex
+	list []*trace.Span
+}
+
+func (e *spans) Export(list []*trace.Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.list = append(e.list, list...)
+	return nil
+}
+
+func (e *spans) Close() error { return nil }
+
+func (e *spans) named(name string) *trace.Span {
+	for _, s := range e.list {
+		if s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// senderFunc calls the function with every message.
+type senderFunc func(tgbotapi.Chattable) (tgbotapi.Message, error)
+
+func (f senderFunc) Send(msg tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return f(msg)
+}
+
+func TestSendSpans(t *testing.T) {
+	exported := &spans{}
+	tracer := trace.New(exported)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		tracer.Run(ctx)
+		close(done)
+	}()
+
+	// The API call made by the sender goes through the instrumented client,
+	// as any call made at the same time by background work would.
+	transport := &Transport{Tracer: tracer, Next: roundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	})}
+
+	b := New(&tgbotapi.BotAPI{}, storage.NewMemory(), nil)
+	b.Tracer = tracer
+	b.Sender = senderFunc(func(tgbotapi.Chattable) (tgbotapi.Message, error) {
+		req, _ := http.NewRequest(http.MethodPost, "https://api.telegram.org/bottoken/sendMessage", nil)
+		resp, err := transport.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return tgbotapi.Message{}, err
+	})
+	b.Router.Handle(func(*Context) bool { return true }, func(c *Context) error {
+		_, err := c.Send(tgbotapi.NewMessage(1, "pong"))
+		return err
+	})
+
+	b.HandleUpdate(tgbotapi.Update{UpdateID: 1, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: 1}, Text: "ping"}})
+	cancel()
+	<-done
+
+	update, send, call := exported.named("update message"), exported.named("telegram send"), exported.named("telegram sendMessage")
+	if update == nil || send == nil || call == nil {
+		t.Fatalf("exported spans: %+v", exported.list)
+	}
+	if send.TraceID != update.TraceID || send.ParentID == "" {
+		t.Errorf("the send span is not part of the update trace: %+v", send)
+	}
+	if call.TraceID == update.TraceID || call.ParentID != "" {
+		t.Errorf("the API call span is attributed to the update: %+v", call)
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// countingStore counts the reads.
+type countingStore struct {
+	storage.Store
+	gets int
+}
+
+func (s *countingStore) Get(bucket, key string, v interface{}) error {
+	s.gets++
+	return s.Store.Get(bucket, key, v)
+}
+
+func TestLangUsesChatSettings(t *testing.T) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &countingStore{Store: storage.NewMemory()}
+	b := New(&tgbotapi.BotAPI{}, store, bundle)
+	setLang := func(lang string) {
+		err := b.Settings.Update(-1, 1, "lang="+lang, func(cfg *settings.Chat) error {
+			cfg.Language = lang
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	setLang("ru")
+
+	c := &Context{Bot: b, Update: tgbotapi.Update{Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -1, Type: "group"}}}}
+	store.gets = 0
+	if _, err := c.ChatSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if lang := c.Lang(); lang != "ru" {
+		t.Errorf("Lang() = %s, want ru", lang)
+	}
+	if store.gets != 1 {
+		t.Errorf("settings read %d times, want once", store.gets)
+	}
+
+	// A handler changing the language derives it again.
+	setLang("en")
+	c.SetLang("")
+	if lang := c.Lang(); lang != "en" {
+		t.Errorf("Lang() after the change = %s, want en", lang)
+	}
+}
@@ -0,0 +1,73 @@
+package bot
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// DebugTransport is an http.RoundTripper logging the Bot API requests and
+// responses while it is on. Unlike tgbotapi.BotAPI.Debug, which the client
+// reads without synchronization, it can be switched while calls are made.
+type DebugTransport struct {
+	// Next makes the requests, http.DefaultTransport if nil.
+	Next http.RoundTripper
+
+	on int32
+}
+
+// SetOn switches the logging on or off.
+func (t *DebugTransport) SetOn(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&t.on, v)
+}
+
+// On reports whether the requests are logged.
+func (t *DebugTransport) On() bool {
+	return atomic.LoadInt32(&t.on) == 1
+}
+
+// RoundTrip implements http.RoundTripper. The logs leave out the token, file
+// uploads and downloads.
+func (t *DebugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	method := APIMethod(req.URL.Path)
+	if !t.On() || method == "file" {
+		return next.RoundTrip(req)
+	}
+
+	// Form requests can be read again, the multipart uploads can't.
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := ioutil.ReadAll(body)
+			body.Close()
+			log.Printf("%s req: %s", method, data)
+		}
+	} else {
+		log.Printf("%s req: %s", method, req.Header.Get("Content-Type"))
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil {
+		log.Printf("%s failed: %s", method, err)
+		return resp, err
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("%s resp: %s", method, data)
+
+	return resp, nil
+}
@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestDebugTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+
+	debug := &DebugTransport{}
+	client := &http.Client{Transport: debug}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(on bool) {
+			defer wg.Done()
+			debug.SetOn(on)
+			resp, err := client.PostForm(srv.URL+"/bottoken/sendMessage", url.Values{"text": {"hi"}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			// The logged response is still passed on.
+			if body, _ := ioutil.ReadAll(resp.Body); string(body) != `{"ok":true}` {
+				t.Errorf("body = %q", body)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,123 @@
+package bot
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/metrics"
+	"github.com/nskondratev/go-telegram-bot-example/internal/trace"
+)
+
+// apiBuckets are the API latency buckets. Long polling requests take up to a minute.
+var apiBuckets = []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 90}
+
+// Every metric has a bot label with the name of the bot, which is empty and
+// therefore left out when the process runs a single bot.
+var (
+	updatesTotal = metrics.NewCounterVec("telegram_bot_updates_total",
+		"Updates received by kind.", "bot", "kind")
+	handlerResults = metrics.NewCounterVec("telegram_bot_handler_results_total",
+		"Handled updates by outcome: ok, error or panic.", "bot", "outcome")
+	handlerDuration = metrics.NewHistogramVec("telegram_bot_handler_duration_seconds",
+		"Time spent handling updates by kind.", metrics.DefBuckets, "bot", "kind")
+
+	apiCalls = metrics.NewCounterVec("telegram_bot_api_calls_total",
+		"Telegram Bot API calls by method and HTTP status code, which is the error code of failed calls.", "bot", "method", "code")
+	apiDuration = metrics.NewHistogramVec("telegram_bot_api_duration_seconds",
+		"Telegram Bot API call latency by method.", apiBuckets, "bot", "method")
+
+	retryAfterTotal = metrics.NewCounterVec("telegram_bot_retry_after_total",
+		"Requests rejected by flood control with retry_after.", "bot")
+	retryAfterSeconds = metrics.NewCounterVec("telegram_bot_retry_after_seconds_total",
+		"Time waited for flood control.", "bot")
+)
+
+// Transport is an http.RoundTripper recording the Bot API call metrics and
+// spans. Pass it to the API client with tgbotapi.NewBotAPIWithClient.
+//
+// The library doesn't pass a context along with its requests, so the spans
+// can't tell which update a call belongs to and start traces of their own.
+// The calls made through Context are recorded in the update trace as well.
+type Transport struct {
+	// Next makes the requests, http.DefaultTransport if nil.
+	Next http.RoundTripper
+	// Tracer records a span per call. Nil disables tracing.
+	Tracer *trace.Tracer
+	// Bot is the name of the bot the metrics are labelled with.
+	Bot string
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	method := APIMethod(req.URL.Path)
+	start := time.Now()
+	span := t.Tracer.Start("telegram "+method, trace.KindClient)
+	span.SetAttribute("telegram.method", method)
+	if t.Bot != "" {
+		span.SetAttribute("telegram.bot", t.Bot)
+	}
+
+	resp, err := next.RoundTrip(req)
+
+	apiDuration.With(t.Bot, method).Observe(time.Since(start).Seconds())
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+		span.SetAttribute("http.status_code", resp.StatusCode)
+		if resp.StatusCode != http.StatusOK {
+			span.SetError(errors.New(resp.Status))
+		}
+	}
+	apiCalls.With(t.Bot, method, code).Inc()
+	span.SetError(err)
+	span.Finish()
+
+	return resp, err
+}
+
+// APIMethod returns the Bot API method of the request path, which also
+// contains the token: /bot<token>/<method>. File downloads are "file".
+func APIMethod(path string) string {
+	if strings.HasPrefix(path, "/file/") {
+		return "file"
+	}
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+	return path
+}
+
+// UpdateKind returns the kind of the update: message, callback_query and so on.
+func UpdateKind(u tgbotapi.Update) string {
+	switch {
+	case u.Message != nil:
+		return "message"
+	case u.EditedMessage != nil:
+		return "edited_message"
+	case u.ChannelPost != nil:
+		return "channel_post"
+	case u.EditedChannelPost != nil:
+		return "edited_channel_post"
+	case u.CallbackQuery != nil:
+		return "callback_query"
+	case u.InlineQuery != nil:
+		return "inline_query"
+	case u.ChosenInlineResult != nil:
+		return "chosen_inline_result"
+	case u.ShippingQuery != nil:
+		return "shipping_query"
+	case u.PreCheckoutQuery != nil:
+		return "pre_checkout_query"
+	}
+	return "unknown"
+}
@@ -0,0 +1,200 @@
+package bot
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// HandlerFunc handles an update.
+type HandlerFunc func(c *Context) error
+
+// Middleware wraps a handler. It may stop the handling by not calling next.
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Matcher reports whether a route should handle the update.
+type Matcher func(c *Context) bool
+
+type route struct {
+	match   Matcher
+	handler HandlerFunc
+}
+
+type middleware struct {
+	name string
+	mw   Middleware
+}
+
+// Router selects the handler for an update.
+//
+// Commands are matched first, then the routes in the order they were added.
+// Only the first matching handler is called.
+type Router struct {
+	middleware []middleware
+	// commands are routes too: a command whose match rejects the update is
+	// treated as unknown.
+	commands map[string]route
+	routes   []route
+
+	// parent and when are set for the groups, see Group.
+	parent *Router
+	when   Matcher
+}
+
+// NewRouter creates an empty router.
+func NewRouter() *Router {
+	return &Router{commands: make(map[string]route)}
+}
+
+// Group returns a router adding its middleware, commands and routes to r,
+// active only for the updates accepted by when. Inactive middleware passes
+// the update on, and inactive commands and routes don't match, so that the
+// next route gets the update.
+func (r *Router) Group(when Matcher) *Router {
+	return &Router{parent: r, when: when}
+}
+
+// Use adds middleware wrapping every handler of the router.
+// Middleware is applied in the order it was added.
+func (r *Router) Use(mw ...Middleware) {
+	for _, m := range mw {
+		r.use(funcName(m), m)
+	}
+}
+
+func (r *Router) use(name string, mw Middleware) {
+	if r.parent == nil {
+		r.middleware = append(r.middleware, middleware{name: name, mw: mw})
+		return
+	}
+
+	when := r.when
+	r.parent.use(name, func(next HandlerFunc) HandlerFunc {
+		h := mw(next)
+		return func(c *Context) error {
+			if !when(c) {
+				return next(c)
+			}
+			return h(c)
+		}
+	})
+}
+
+// Command registers the handler of the /name command.
+func (r *Router) Command(name string, h HandlerFunc) {
+	r.add(strings.ToLower(name), route{handler: h})
+}
+
+// Handle registers a handler for updates accepted by match.
+func (r *Router) Handle(match Matcher, h HandlerFunc) {
+	r.add("", route{match: match, handler: h})
+}
+
+// add adds the route of the command, or a plain route if command is empty.
+func (r *Router) add(command string, rt route) {
+	if r.parent != nil {
+		if match, when := rt.match, r.when; match == nil {
+			rt.match = when
+		} else {
+			rt.match = func(c *Context) bool { return when(c) && match(c) }
+		}
+		r.parent.add(command, rt)
+		return
+	}
+
+	if command != "" {
+		r.commands[command] = rt
+		return
+	}
+	r.routes = append(r.routes, rt)
+}
+
+// Dispatch runs the middleware chain and the matching handler. With tracing
+// enabled every middleware and the handler run in their own span.
+func (r *Router) Dispatch(c *Context) error {
+	h := r.resolve
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		h = r.middleware[i].mw(h)
+		if c.span != nil {
+			h = traced(r.middleware[i].name, h)
+		}
+	}
+	return h(c)
+}
+
+func (r *Router) resolve(c *Context) error {
+	if name := command(c); name != "" {
+		if rt, ok := r.commands[name]; ok && (rt.match == nil || rt.match(c)) {
+			return c.trace("/"+name, rt.handler)
+		}
+	}
+
+	for _, rt := range r.routes {
+		if rt.match(c) {
+			if c.span == nil {
+				return rt.handler(c)
+			}
+			return c.trace(funcName(rt.handler), rt.handler)
+		}
+	}
+
+	return nil
+}
+
+// traced wraps the handler to run in a span of the name.
+func traced(name string, h HandlerFunc) HandlerFunc {
+	return func(c *Context) error {
+		return c.trace(name, h)
+	}
+}
+
+// funcName returns the name of the function f without the module path, such
+// as "moderation.(*Moderator).Middleware-fm".
+func funcName(f interface{}) string {
+	fn := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
+	if fn == nil {
+		return "handler"
+	}
+	name := fn.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// command returns the lowercased command of the update message. Commands
+// addressed to another bot with the /command@bot syntax are ignored.
+func command(c *Context) string {
+	m := c.Update.Message
+	if m == nil || !m.IsCommand() {
+		return ""
+	}
+
+	name := m.CommandWithAt()
+	if i := strings.Index(name, "@"); i != -1 {
+		if !strings.EqualFold(name[i+1:], c.Bot.API.Self.UserName) {
+			return ""
+		}
+		name = name[:i]
+	}
+
+	return strings.ToLower(name)
+}
+
+// IsText matches updates with a text message.
+func IsText(c *Context) bool {
+	return c.Update.Message != nil && c.Update.Message.Text != ""
+}
+
+// IsMessage matches updates with a message of any kind.
+func IsMessage(c *Context) bool {
+	return c.Update.Message != nil
+}
+
+// IsCallback matches callback queries whose data starts with prefix.
+func IsCallback(prefix string) Matcher {
+	return func(c *Context) bool {
+		q := c.Update.CallbackQuery
+		return q != nil && strings.HasPrefix(q.Data, prefix)
+	}
+}
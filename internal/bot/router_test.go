@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestRouterGroups(t *testing.T) {
+	var calls []string
+	record := func(name string) HandlerFunc {
+		return func(*Context) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	wrap := func(name string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(c *Context) error {
+				calls = append(calls, name)
+				return next(c)
+			}
+		}
+	}
+	inChat := func(id int64) Matcher {
+		return func(c *Context) bool { return c.Chat() != nil && c.Chat().ID == id }
+	}
+
+	r := NewRouter()
+	r.Use(wrap("mw"))
+	g := r.Group(inChat(1))
+	g.Use(wrap("group mw"))
+	g.Command("ping", record("group /ping"))
+	nested := g.Group(func(c *Context) bool { return strings.Contains(c.Update.Message.Text, "nested") })
+	nested.Handle(IsText, record("nested text"))
+	g.Handle(IsText, record("group text"))
+	r.Command("help", record("/help"))
+	r.Handle(IsText, record("text"))
+
+	tests := []struct {
+		chat int64
+		text string
+		want []string
+	}{
+		{chat: 1, text: "/ping", want: []string{"mw", "group mw", "group /ping"}},
+		{chat: 2, text: "/ping", want: []string{"mw", "text"}},
+		{chat: 1, text: "/help", want: []string{"mw", "group mw", "/help"}},
+		{chat: 1, text: "hello", want: []string{"mw", "group mw", "group text"}},
+		{chat: 1, text: "nested", want: []string{"mw", "group mw", "nested text"}},
+		{chat: 2, text: "nested", want: []string{"mw", "text"}},
+		{chat: 2, text: "hello", want: []string{"mw", "text"}},
+	}
+	for _, tt := range tests {
+		calls = nil
+		m := &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: tt.chat}, Text: tt.text}
+		if strings.HasPrefix(tt.text, "/") {
+			m.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(tt.text)}}
+		}
+		c := &Context{Bot: &Bot{API: &tgbotapi.BotAPI{}}, Update: tgbotapi.Update{Message: m}}
+		if err := r.Dispatch(c); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(calls, tt.want) {
+			t.Errorf("chat %d, %q: calls %v, want %v", tt.chat, tt.text, calls, tt.want)
+		}
+	}
+}
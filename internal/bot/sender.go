@@ -0,0 +1,130 @@
+package bot
+
+import (
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+)
+
+// Sender sends chattables to Telegram. *tgbotapi.BotAPI is a Sender.
+type Sender interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// SplitSender sends messages exceeding the Telegram size limits in parts.
+//
+// Text messages longer than 4096 characters are split into several messages
+// and captions longer than 1024 characters are continued in text messages
+// following the media. Only the first part replies to the original message,
+// while the reply markup is attached to the last part, so buttons stay below
+// the whole text.
+//
+// If DocumentThreshold is positive, text messages longer than it are sent as a
+// .txt document instead.
+type SplitSender struct {
+	Next              Sender
+	DocumentThreshold int
+}
+
+// NewSplitSender creates a SplitSender sending through next.
+func NewSplitSender(next Sender, documentThreshold int) *SplitSender {
+	return &SplitSender{Next: next, DocumentThreshold: documentThreshold}
+}
+
+// Send implements Sender. For messages sent in parts it returns the last sent message.
+func (s *SplitSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	switch cfg := c.(type) {
+	case tgbotapi.MessageConfig:
+		return s.sendText(cfg)
+	case tgbotapi.PhotoConfig:
+		rest := splitCaption(&cfg.Caption, cfg.ParseMode)
+		return s.sendCaptioned(cfg, cfg.BaseChat, cfg.ParseMode, rest)
+	case tgbotapi.AudioConfig:
+		rest := splitCaption(&cfg.Caption, cfg.ParseMode)
+		return s.sendCaptioned(cfg, cfg.BaseChat, cfg.ParseMode, rest)
+	case tgbotapi.DocumentConfig:
+		rest := splitCaption(&cfg.Caption, cfg.ParseMode)
+		return s.sendCaptioned(cfg, cfg.BaseChat, cfg.ParseMode, rest)
+	case tgbotapi.VideoConfig:
+		rest := splitCaption(&cfg.Caption, cfg.ParseMode)
+		return s.sendCaptioned(cfg, cfg.BaseChat, cfg.ParseMode, rest)
+	case tgbotapi.AnimationConfig:
+		rest := splitCaption(&cfg.Caption, cfg.ParseMode)
+		return s.sendCaptioned(cfg, cfg.BaseChat, cfg.ParseMode, rest)
+	case tgbotapi.VoiceConfig:
+		rest := splitCaption(&cfg.Caption, cfg.ParseMode)
+		return s.sendCaptioned(cfg, cfg.BaseChat, cfg.ParseMode, rest)
+	}
+
+	return s.Next.Send(c)
+}
+
+func (s *SplitSender) sendText(cfg tgbotapi.MessageConfig) (tgbotapi.Message, error) {
+	length := render.Length(cfg.Text)
+	if length <= render.MaxMessageLength {
+		return s.Next.Send(cfg)
+	}
+
+	if s.DocumentThreshold > 0 && length > s.DocumentThreshold {
+		doc := tgbotapi.NewDocumentUpload(cfg.ChatID, tgbotapi.FileBytes{
+			Name:  "message.txt",
+			Bytes: []byte(render.Plain(cfg.Text, cfg.ParseMode)),
+		})
+		doc.BaseChat = cfg.BaseChat
+		return s.Next.Send(doc)
+	}
+
+	parts := render.Split(cfg.Text, cfg.ParseMode, render.MaxMessageLength)
+
+	var last tgbotapi.Message
+	for i, part := range parts {
+		msg := cfg
+		msg.Text = part
+		if i > 0 {
+			msg.ReplyToMessageID = 0
+		}
+		if i < len(parts)-1 {
+			msg.ReplyMarkup = nil
+		}
+
+		sent, err := s.Next.Send(msg)
+		if err != nil {
+			return sent, err
+		}
+		last = sent
+	}
+
+	return last, nil
+}
+
+// sendCaptioned sends media and continues its caption in text messages.
+func (s *SplitSender) sendCaptioned(c tgbotapi.Chattable, chat tgbotapi.BaseChat, mode, rest string) (tgbotapi.Message, error) {
+	sent, err := s.Next.Send(c)
+	if err != nil || rest == "" {
+		return sent, err
+	}
+
+	msg := tgbotapi.NewMessage(chat.ChatID, rest)
+	msg.ChannelUsername = chat.ChannelUsername
+	msg.DisableNotification = chat.DisableNotification
+	msg.ParseMode = mode
+
+	return s.sendText(msg)
+}
+
+// splitCaption cuts the caption down to the caption size limit and returns
+// the text that did not fit.
+func splitCaption(caption *string, mode string) string {
+	if render.Length(*caption) <= render.MaxCaptionLength {
+		return ""
+	}
+
+	parts := render.Split(*caption, mode, render.MaxCaptionLength)
+	*caption = parts[0]
+
+	// Every part is valid markup on its own, so the rest can be split again
+	// at the larger message size limit.
+	return strings.Join(parts[1:], "\n")
+}
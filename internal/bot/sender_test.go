@@ -0,0 +1,142 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+)
+
+// recorder records the sent chattables.
+type recorder struct {
+	sent []tgbotapi.Chattable
+}
+
+func (r *recorder) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	r.sent = append(r.sent, c)
+	return tgbotapi.Message{MessageID: len(r.sent)}, nil
+}
+
+// paragraphs returns n paragraphs of the length, so the text splits at them.
+func paragraphs(n, length int) string {
+	list := make([]string, n)
+	for i := range list {
+		list[i] = strings.Repeat("x", length)
+	}
+	return strings.Join(list, "\n\n")
+}
+
+func TestSplitSenderText(t *testing.T) {
+	r := &recorder{}
+	s := NewSplitSender(r, 0)
+
+	msg := tgbotapi.NewMessage(1, paragraphs(3, 3000))
+	msg.ReplyToMessageID = 10
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("ok", "ok")))
+	last, err := s.Send(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.sent) != 3 {
+		t.Fatalf("sent %d messages, want 3", len(r.sent))
+	}
+	if last.MessageID != 3 {
+		t.Errorf("Send() returned message %d, want the last one", last.MessageID)
+	}
+	for i, c := range r.sent {
+		part := c.(tgbotapi.MessageConfig)
+		if n := render.Length(part.Text); n > render.MaxMessageLength {
+			t.Errorf("part %d is %d long", i, n)
+		}
+		if replies := part.ReplyToMessageID != 0; replies != (i == 0) {
+			t.Errorf("part %d replies to %d", i, part.ReplyToMessageID)
+		}
+		if hasMarkup := part.ReplyMarkup != nil; hasMarkup != (i == len(r.sent)-1) {
+			t.Errorf("part %d has the reply markup = %v", i, hasMarkup)
+		}
+	}
+}
+
+func TestSplitSenderShortText(t *testing.T) {
+	r := &recorder{}
+	s := NewSplitSender(r, 100)
+
+	msg := tgbotapi.NewMessage(1, strings.Repeat("x", render.MaxMessageLength))
+	if _, err := s.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.sent) != 1 || r.sent[0].(tgbotapi.MessageConfig).Text != msg.Text {
+		t.Errorf("sent %v, want the message as is", r.sent)
+	}
+}
+
+func TestSplitSenderCaption(t *testing.T) {
+	r := &recorder{}
+	s := NewSplitSender(r, 0)
+
+	photo := tgbotapi.NewPhotoShare(1, "file")
+	photo.Caption = "<b>" + paragraphs(2, 1000) + "</b>"
+	photo.ParseMode = tgbotapi.ModeHTML
+	photo.ReplyToMessageID = 10
+	if _, err := s.Send(photo); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.sent) != 2 {
+		t.Fatalf("sent %d messages, want the photo and a text", len(r.sent))
+	}
+	sent := r.sent[0].(tgbotapi.PhotoConfig)
+	if n := render.Length(sent.Caption); n > render.MaxCaptionLength {
+		t.Errorf("caption is %d long", n)
+	}
+	if sent.ReplyToMessageID != 10 {
+		t.Errorf("photo replies to %d, want 10", sent.ReplyToMessageID)
+	}
+	rest := r.sent[1].(tgbotapi.MessageConfig)
+	if want := "<b>" + strings.Repeat("x", 1000) + "</b>"; rest.Text != want || rest.ParseMode != tgbotapi.ModeHTML {
+		t.Errorf("continued caption = %q in %q, want %q", rest.Text, rest.ParseMode, want)
+	}
+	if rest.ReplyToMessageID != 0 {
+		t.Errorf("continued caption replies to %d", rest.ReplyToMessageID)
+	}
+}
+
+func TestSplitSenderDocument(t *testing.T) {
+	r := &recorder{}
+	s := NewSplitSender(r, 6000)
+
+	msg := tgbotapi.NewMessage(1, "<b>"+paragraphs(2, 3000)+"</b>")
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyToMessageID = 10
+	if _, err := s.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(r.sent) != 1 {
+		t.Fatalf("sent %d messages, want a document", len(r.sent))
+	}
+	doc, ok := r.sent[0].(tgbotapi.DocumentConfig)
+	if !ok {
+		t.Fatalf("sent %T, want a document", r.sent[0])
+	}
+	file := doc.File.(tgbotapi.FileBytes)
+	if want := paragraphs(2, 3000); string(file.Bytes) != want || file.Name != "message.txt" {
+		t.Errorf("document %s has %d bytes, want message.txt with the plain text", file.Name, len(file.Bytes))
+	}
+	if doc.ReplyToMessageID != 10 {
+		t.Errorf("document replies to %d, want 10", doc.ReplyToMessageID)
+	}
+
+	// Below the threshold the text is split.
+	r.sent = nil
+	msg.Text = paragraphs(2, 2500)
+	if _, err := s.Send(msg); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.sent) != 2 {
+		t.Errorf("sent %d messages below the threshold, want 2", len(r.sent))
+	}
+}
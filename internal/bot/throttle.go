@@ -0,0 +1,149 @@
+package bot
+
+import (
+	"log"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/tgerr"
+)
+
+// Telegram flood limits, see https://core.telegram.org/bots/faq#my-bot-is-hitting-limits-how-do-i-avoid-this
+const (
+	globalInterval  = time.Second / 30
+	privateInterval = time.Second
+	groupInterval   = time.Minute / 20
+
+	// maxRetries limits the retries of a request rejected by flood control.
+	maxRetries = 3
+)
+
+// Throttle is a Sender queueing requests to stay within the Telegram flood
+// limits: 30 messages per second overall, one message per second to the same
+// private chat and 20 messages per minute to the same group.
+//
+// Send blocks until the request is allowed to go out. Requests rejected with
+// retry_after are retried after the requested delay.
+type Throttle struct {
+	Next Sender
+	// Bot is the name of the bot the metrics are labelled with.
+	Bot string
+
+	mu      sync.Mutex
+	next    time.Time           // the earliest time of the next request
+	chats   map[int64]time.Time // the earliest time of the next request per chat
+	waiting int
+}
+
+// NewThrottle creates a Throttle sending through next.
+func NewThrottle(next Sender) *Throttle {
+	return &Throttle{Next: next, chats: make(map[int64]time.Time)}
+}
+
+// Send implements Sender.
+func (t *Throttle) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chatID := chatID(c)
+
+	time.Sleep(time.Until(t.reserve(chatID)))
+
+	t.mu.Lock()
+	t.waiting--
+	t.mu.Unlock()
+
+	for attempt := 0; ; attempt++ {
+		msg, err := t.Next.Send(c)
+
+		wait := tgerr.RetryAfter(err)
+		if wait == 0 || attempt == maxRetries {
+			return msg, err
+		}
+
+		retryAfterTotal.With(t.Bot).Inc()
+		retryAfterSeconds.With(t.Bot).Add(wait.Seconds())
+		log.Printf("Flood control for chat %d, retrying in %s", chatID, wait)
+		t.delay(chatID, wait)
+		time.Sleep(wait)
+	}
+}
+
+// Waiting returns the number of requests waiting in the queue.
+func (t *Throttle) Waiting() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.waiting
+}
+
+// reserve returns the time the request to the chat may be sent at and
+// reserves the slot. The request counts as waiting until it is sent.
+func (t *Throttle) reserve(chatID int64) time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.waiting++
+
+	now := time.Now()
+	at := now
+	if t.next.After(at) {
+		at = t.next
+	}
+	if next := t.chats[chatID]; next.After(at) {
+		at = next
+	}
+
+	t.next = at.Add(globalInterval)
+	if chatID != 0 {
+		t.chats[chatID] = at.Add(chatInterval(chatID))
+	}
+
+	// Forget chats whose slots have passed, so the map does not grow forever.
+	if len(t.chats) > 1024 {
+		for id, next := range t.chats {
+			if next.Before(now) {
+				delete(t.chats, id)
+			}
+		}
+	}
+
+	return at
+}
+
+// delay postpones all requests to the chat by d.
+func (t *Throttle) delay(chatID int64, d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if until := time.Now().Add(d); until.After(t.chats[chatID]) {
+		t.chats[chatID] = until
+	}
+}
+
+func chatInterval(chatID int64) time.Duration {
+	// Group and channel IDs are negative.
+	if chatID < 0 {
+		return groupInterval
+	}
+	return privateInterval
+}
+
+// chatID returns the ChatID field of a chattable config, or 0 if it has none.
+func chatID(c tgbotapi.Chattable) int64 {
+	v := reflect.ValueOf(c)
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return 0
+	}
+
+	// ChatID is usually promoted from the embedded BaseChat or BaseEdit.
+	f := v.FieldByName("ChatID")
+	if f.IsValid() && f.Kind() == reflect.Int64 {
+		return f.Int()
+	}
+
+	return 0
+}
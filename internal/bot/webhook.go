@@ -0,0 +1,43 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// WebhookHandler passes the updates Telegram posts to the webhook to the
+// channel. received, if not nil, is called after every update is queued.
+//
+// Unlike tgbotapi.BotAPI.ListenForWebhook it rejects malformed requests, so
+// Telegram reports them in the webhook info, and it doesn't use the default
+// ServeMux.
+func WebhookHandler(updates chan<- tgbotapi.Update, received func()) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var update tgbotapi.Update
+		if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// A full channel holds the request, so Telegram retries it later
+		// instead of the update being lost.
+		select {
+		case updates <- update:
+		case <-r.Context().Done():
+			http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+			return
+		}
+
+		if received != nil {
+			received()
+		}
+	})
+}
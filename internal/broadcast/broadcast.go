@@ -0,0 +1,254 @@
+// Package broadcast sends announcements to every chat the bot has seen.
+package broadcast
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/tgerr"
+)
+
+// saveInterval is how often the counters of the job being sent are stored.
+// Storing them after every delivery would write the whole job, recipients
+// included, once per recipient.
+const saveInterval = 5 * time.Second
+
+// Broadcaster runs broadcast jobs one at a time in the background.
+type Broadcaster struct {
+	bot      *bot.Bot
+	registry *Registry
+	jobs     jobs
+	only     bot.Middleware
+
+	notify chan struct{}
+
+	mu        sync.Mutex
+	active    string // ID of the job being sent
+	cancelled map[string]bool
+}
+
+// New creates a broadcaster for the bot. Its commands are restricted by the
+// only middleware, usually admin.Admin.Only.
+func New(b *bot.Bot, only bot.Middleware) *Broadcaster {
+	return &Broadcaster{
+		bot:       b,
+		registry:  &Registry{Store: b.Store, Self: b.API.Self.ID},
+		jobs:      jobs{store: b.Store},
+		only:      only,
+		notify:    make(chan struct{}, 1),
+		cancelled: make(map[string]bool),
+	}
+}
+
+// Registry returns the registry of chats known to the bot.
+func (br *Broadcaster) Registry() *Registry {
+	return br.registry
+}
+
+// Run sends the running jobs, including those interrupted by a restart,
+// until the context is done.
+func (br *Broadcaster) Run(ctx context.Context) {
+	for {
+		list, err := br.jobs.list()
+		if err != nil {
+			log.Printf("Failed to load broadcast jobs: %s", err)
+		}
+		for _, j := range list {
+			if j.Status == StatusRunning {
+				br.process(ctx, j)
+			} else {
+				// Deliveries left by a crash before the job was pruned.
+				br.prune(j)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-br.notify:
+		}
+	}
+}
+
+// start stores a new job and wakes up the runner.
+func (br *Broadcaster) start(j *Job) error {
+	j.Status = StatusRunning
+	if err := br.jobs.put(j); err != nil {
+		return err
+	}
+
+	select {
+	case br.notify <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+// cancel stops the job. It reports false if the job is already finished.
+func (br *Broadcaster) cancel(id string) (bool, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	j, err := br.jobs.get(id)
+	if err != nil {
+		return false, err
+	}
+	if j.Status != StatusRunning {
+		return false, nil
+	}
+
+	if br.active == id {
+		// The runner owns the job and stores the status itself.
+		br.cancelled[id] = true
+		return true, nil
+	}
+
+	j.Status = StatusCancelled
+	j.Finished = time.Now()
+
+	return true, br.jobs.put(j)
+}
+
+// acquire marks the job as being sent. It reports false if the job was
+// cancelled: while it was being sent, so the runner stores the cancellation
+// itself, or before, so the stored job isn't running anymore and its status
+// is copied to j. The stored job is read under the lock cancel holds, since
+// the runner's copy is older than a cancellation of a job not sent yet.
+func (br *Broadcaster) acquire(j *Job) (bool, error) {
+	br.mu.Lock()
+	defer br.mu.Unlock()
+
+	if br.cancelled[j.ID] {
+		delete(br.cancelled, j.ID)
+		return false, nil
+	}
+
+	stored, err := br.jobs.get(j.ID)
+	if err != nil {
+		return false, err
+	}
+	if stored.Status != StatusRunning {
+		j.Status, j.Finished = stored.Status, stored.Finished
+		return false, nil
+	}
+	br.active = j.ID
+
+	return true, nil
+}
+
+func (br *Broadcaster) release() {
+	br.mu.Lock()
+	br.active = ""
+	br.mu.Unlock()
+}
+
+// process sends the job to the recipients without a recorded delivery. The
+// counters are derived from the recorded deliveries, so they stay right when
+// the job is resumed after a crash, and the job is only stored every
+// saveInterval to show the progress.
+func (br *Broadcaster) process(ctx context.Context, j *Job) {
+	defer br.release()
+
+	j.Sent, j.Failed, j.Blocked = 0, 0, 0
+	saved := time.Now()
+	for _, chatID := range j.Recipients {
+		if ctx.Err() != nil {
+			// The job stays running and is resumed after the restart.
+			return
+		}
+		ok, err := br.acquire(j)
+		if err != nil {
+			// The job stays running and is resumed with the next one.
+			log.Printf("Failed to load broadcast %s: %s", j.ID, err)
+			return
+		}
+		if !ok {
+			if j.Status == StatusRunning {
+				br.finish(j, StatusCancelled)
+			}
+			return
+		}
+
+		if d, ok := br.jobs.delivery(j.ID, chatID); ok {
+			j.count(d)
+			continue
+		}
+
+		d := br.deliver(j, chatID)
+		if err := br.jobs.deliver(j.ID, chatID, d); err != nil {
+			log.Printf("Failed to record delivery of broadcast %s to chat %d: %s", j.ID, chatID, err)
+		}
+		j.count(d)
+		if time.Since(saved) >= saveInterval {
+			if err := br.jobs.put(j); err != nil {
+				log.Printf("Failed to store broadcast %s: %s", j.ID, err)
+			}
+			saved = time.Now()
+		}
+	}
+
+	br.finish(j, StatusDone)
+}
+
+func (br *Broadcaster) prune(j *Job) {
+	if err := br.jobs.prune(j); err != nil {
+		log.Printf("Failed to prune deliveries of broadcast %s: %s", j.ID, err)
+	}
+}
+
+func (br *Broadcaster) deliver(j *Job, chatID int64) Delivery {
+	msg, err := br.bot.Sender.Send(j.Chattable(chatID))
+
+	if to := tgerr.MigratedTo(err); to != 0 {
+		if err := br.registry.Migrate(chatID, to); err != nil {
+			log.Printf("Failed to migrate chat %d to %d: %s", chatID, to, err)
+		}
+		msg, err = br.bot.Sender.Send(j.Chattable(to))
+	}
+
+	d := Delivery{Status: DeliverySent, MessageID: msg.MessageID, Time: time.Now()}
+	switch {
+	case err == nil:
+	case tgerr.IsForbidden(err):
+		d.Status, d.Error = DeliveryBlocked, err.Error()
+		if err := br.registry.Deactivate(chatID, d.Error); err != nil {
+			log.Printf("Failed to deactivate chat %d: %s", chatID, err)
+		}
+	default:
+		d.Status, d.Error = DeliveryFailed, err.Error()
+	}
+
+	return d
+}
+
+// finish stores the final job status, prunes its deliveries and reports the
+// result to its creator.
+func (br *Broadcaster) finish(j *Job, status string) {
+	j.Status = status
+	j.Finished = time.Now()
+	if err := br.jobs.put(j); err != nil {
+		log.Printf("Failed to store broadcast %s: %s", j.ID, err)
+	} else {
+		br.prune(j)
+	}
+
+	key := "broadcast.report.done"
+	if status == StatusCancelled {
+		key = "broadcast.report.cancelled"
+	}
+	text := br.bot.I18n.Translate(j.Lang, key,
+		"id", j.ID, "sent", j.Sent, "failed", j.Failed, "blocked", j.Blocked, "total", len(j.Recipients))
+
+	if _, err := br.bot.Sender.Send(tgbotapi.NewMessage(j.ReportTo, text)); err != nil {
+		log.Printf("Failed to report broadcast %s: %s", j.ID, err)
+	}
+}
@@ -0,0 +1,175 @@
+package broadcast
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// sender records the chats messages are sent to.
+type sender struct {
+	chats []int64
+}
+
+func (s *sender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg := c.(tgbotapi.MessageConfig)
+	s.chats = append(s.chats, msg.ChatID)
+	return tgbotapi.Message{MessageID: len(s.chats)}, nil
+}
+
+func newBroadcaster(t *testing.T) (*Broadcaster, *sender) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bot.New(&tgbotapi.BotAPI{}, storage.NewMemory(), bundle)
+	s := &sender{}
+	b.Sender = s
+	return New(b, nil), s
+}
+
+func startJob(t *testing.T, br *Broadcaster, id string, recipients ...int64) *Job {
+	j := &Job{ID: id, Kind: KindText, Text: "news", ReportTo: 100, Lang: "en", Created: time.Now(), Recipients: recipients}
+	if err := br.start(j); err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func TestCancelQueuedJob(t *testing.T) {
+	br, s := newBroadcaster(t)
+	startJob(t, br, "a", 1)
+	startJob(t, br, "b", 2, 3)
+
+	// The runner lists the jobs before b is cancelled.
+	list, err := br.jobs.list()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := br.cancel("b"); !ok || err != nil {
+		t.Fatalf("cancel(b) = %v, %v", ok, err)
+	}
+	for _, j := range list {
+		br.process(context.Background(), j)
+	}
+
+	// Only a is sent, followed by its report.
+	if want := []int64{1, 100}; !reflect.DeepEqual(s.chats, want) {
+		t.Errorf("sent to %v, want %v", s.chats, want)
+	}
+	b, err := br.jobs.get("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Status != StatusCancelled || b.Sent != 0 {
+		t.Errorf("b is %s with %d sent, want cancelled with none", b.Status, b.Sent)
+	}
+}
+
+func TestCancelActiveJob(t *testing.T) {
+	br, s := newBroadcaster(t)
+	j := startJob(t, br, "a", 1, 2)
+
+	// The job is cancelled while it is being sent to the first recipient.
+	br.bot.Sender = senderFunc(func(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+		if ok, err := br.cancel("a"); !ok || err != nil {
+			t.Errorf("cancel(a) = %v, %v", ok, err)
+		}
+		br.bot.Sender = s
+		return s.Send(c)
+	})
+	br.process(context.Background(), j)
+
+	if want := []int64{1, 100}; !reflect.DeepEqual(s.chats, want) {
+		t.Errorf("sent to %v, want %v", s.chats, want)
+	}
+	stored, err := br.jobs.get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != StatusCancelled || stored.Sent != 1 {
+		t.Errorf("a is %s with %d sent, want cancelled with 1", stored.Status, stored.Sent)
+	}
+}
+
+func TestResumeCountsRecordedDeliveries(t *testing.T) {
+	br, s := newBroadcaster(t)
+	j := startJob(t, br, "a", 1, 2, 3)
+
+	// The deliveries to 1 and 2 were recorded, but the job counters weren't
+	// stored before the crash.
+	if err := br.jobs.deliver("a", 1, Delivery{Status: DeliverySent}); err != nil {
+		t.Fatal(err)
+	}
+	if err := br.jobs.deliver("a", 2, Delivery{Status: DeliveryBlocked}); err != nil {
+		t.Fatal(err)
+	}
+	br.process(context.Background(), j)
+
+	if want := []int64{3, 100}; !reflect.DeepEqual(s.chats, want) {
+		t.Errorf("sent to %v, want %v", s.chats, want)
+	}
+	stored, err := br.jobs.get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != StatusDone || stored.Sent != 2 || stored.Blocked != 1 || stored.Pending() != 0 {
+		t.Errorf("a is %s with %d sent and %d blocked, want done with 2 and 1", stored.Status, stored.Sent, stored.Blocked)
+	}
+}
+
+type senderFunc func(c tgbotapi.Chattable) (tgbotapi.Message, error)
+
+func (f senderFunc) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return f(c)
+}
+
+// countingStore counts the writes to each bucket.
+type countingStore struct {
+	storage.Store
+	puts map[string]int
+}
+
+func (s *countingStore) Put(bucket, key string, v interface{}) error {
+	s.puts[bucket]++
+	return s.Store.Put(bucket, key, v)
+}
+
+func TestProcessStoresJobOnce(t *testing.T) {
+	br, s := newBroadcaster(t)
+	store := &countingStore{Store: br.bot.Store, puts: make(map[string]int)}
+	br.jobs.store = store
+
+	recipients := make([]int64, 50)
+	for i := range recipients {
+		recipients[i] = int64(i + 1)
+	}
+	j := startJob(t, br, "a", recipients...)
+	br.process(context.Background(), j)
+
+	if len(s.chats) != len(recipients)+1 {
+		t.Errorf("sent %d messages, want %d", len(s.chats), len(recipients)+1)
+	}
+	// The job is stored when it starts and finishes, well within saveInterval.
+	if n := store.puts[jobsBucket]; n != 2 {
+		t.Errorf("job stored %d times, want 2", n)
+	}
+	stored, err := br.jobs.get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != StatusDone || stored.Sent != len(recipients) {
+		t.Errorf("a is %s with %d sent, want done with %d", stored.Status, stored.Sent, len(recipients))
+	}
+	if keys, err := store.Keys(deliveriesBucket); err != nil || len(keys) != 0 {
+		t.Errorf("deliveries after finish = %v, %v, want none", keys, err)
+	}
+}
@@ -0,0 +1,156 @@
+package broadcast
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/media"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// listSize is the number of jobs shown by /broadcasts.
+const listSize = 5
+
+// Register adds the chat tracking middleware and the broadcast commands to the router.
+func (br *Broadcaster) Register(r *bot.Router) {
+	r.Use(br.registry.Track)
+
+	r.Command("broadcast", br.only(br.broadcast))
+	r.Command("broadcasts", br.only(br.list))
+	r.Command("broadcast_cancel", br.only(br.cancelCommand))
+}
+
+// broadcast handles /broadcast [--dry-run] [forward] [text].
+//
+// Replying to a message broadcasts that message: a copy of its text or media,
+// or the message itself with the forward argument. Otherwise the text
+// argument is broadcast. A dry run sends a preview to the current chat only.
+func (br *Broadcaster) broadcast(c *bot.Context) error {
+	m := c.Update.Message
+
+	args := strings.TrimSpace(m.CommandArguments())
+
+	dryRun := strings.HasPrefix(args, "--dry-run")
+	if dryRun {
+		args = strings.TrimSpace(strings.TrimPrefix(args, "--dry-run"))
+	}
+	forward := m.ReplyToMessage != nil && args == "forward"
+
+	j, ok := newJob(m.ReplyToMessage, forward, args)
+	if !ok {
+		return c.Reply(c.T("broadcast.usage"))
+	}
+
+	recipients, err := br.registry.Active()
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		if _, err := c.Send(j.Chattable(m.Chat.ID)); err != nil {
+			return err
+		}
+		return c.Reply(c.TN("broadcast.dry_run", len(recipients)))
+	}
+
+	now := time.Now()
+	j.ID = newJobID(now)
+	j.CreatedBy = m.From.ID
+	j.ReportTo = m.Chat.ID
+	j.Lang = c.Lang()
+	j.Created = now
+	j.Recipients = recipients
+
+	if err := br.start(j); err != nil {
+		return err
+	}
+
+	return c.Reply(c.TN("broadcast.started", len(recipients), "id", j.ID))
+}
+
+// newJob builds the content of a job from the replied message or the text.
+func newJob(reply *tgbotapi.Message, forward bool, text string) (*Job, bool) {
+	switch {
+	case reply != nil && forward:
+		return &Job{Kind: KindForward, FromChatID: reply.Chat.ID, MessageID: reply.MessageID}, true
+	case reply != nil:
+		if mediaType, fileID := media.Of(reply); fileID != "" {
+			return &Job{Kind: KindMedia, MediaType: mediaType, FileID: fileID, Text: reply.Caption}, true
+		}
+		text = reply.Text
+	}
+
+	if text == "" {
+		return nil, false
+	}
+	return &Job{Kind: KindText, Text: text}, true
+}
+
+func (br *Broadcaster) list(c *bot.Context) error {
+	list, err := br.jobs.list()
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return c.Reply(c.T("broadcast.list.empty"))
+	}
+	if len(list) > listSize {
+		list = list[len(list)-listSize:]
+	}
+
+	lines := make([]string, 0, len(list))
+	for i := len(list) - 1; i >= 0; i-- {
+		j := list[i]
+		lines = append(lines, c.T("broadcast.list.job",
+			"id", j.ID, "kind", j.Kind, "status", j.Status,
+			"sent", j.Sent, "failed", j.Failed, "blocked", j.Blocked, "pending", j.Pending()))
+	}
+
+	return c.Reply(strings.Join(lines, "\n"))
+}
+
+func (br *Broadcaster) cancelCommand(c *bot.Context) error {
+	id := strings.TrimSpace(c.Update.Message.CommandArguments())
+	if id == "" {
+		return c.Reply(c.T("broadcast.cancel.usage"))
+	}
+
+	ok, err := br.cancel(id)
+	switch {
+	case err == storage.ErrNotFound:
+		return c.Reply(c.T("broadcast.cancel.unknown", "id", id))
+	case err != nil:
+		return err
+	case !ok:
+		return c.Reply(c.T("broadcast.cancel.finished", "id", id))
+	}
+
+	return c.Reply(c.T("broadcast.cancel.done", "id", id))
+}
+
+// Stats returns the /stats lines about the known chats.
+func (br *Broadcaster) Stats(c *bot.Context) ([]string, error) {
+	total, active, err := br.registry.Count()
+	if err != nil {
+		return nil, err
+	}
+	return []string{c.TN("broadcast.stats.chats", total, "active", active)}, nil
+}
+
+// Whois returns the /whois lines about the chat.
+func (br *Broadcaster) Whois(c *bot.Context, id int64) []string {
+	ch, err := br.registry.Get(id)
+	if err != nil {
+		return nil
+	}
+
+	lines := []string{c.T("broadcast.whois.seen", "time", ch.LastSeen.Format(time.RFC3339))}
+	if ch.Inactive {
+		lines = append(lines, c.T("broadcast.whois.inactive",
+			"time", ch.InactiveSince.Format(time.RFC3339), "reason", ch.InactiveReason))
+	}
+	return lines
+}
@@ -0,0 +1,176 @@
+package broadcast
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/media"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	jobsBucket       = "broadcast_jobs"
+	deliveriesBucket = "broadcast_deliveries"
+)
+
+// Kinds of broadcast content.
+const (
+	KindText    = "text"
+	KindMedia   = "media"
+	KindForward = "forward"
+)
+
+// Job statuses.
+const (
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusCancelled = "cancelled"
+)
+
+// Delivery statuses.
+const (
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+	DeliveryBlocked = "blocked"
+)
+
+// Job is a broadcast to all active chats.
+//
+// The recipients are fixed when the job is created, and every delivery is
+// recorded, so a job interrupted by a restart continues where it stopped.
+type Job struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+
+	// Text is the message text for text broadcasts and the caption for media.
+	Text      string `json:"text,omitempty"`
+	ParseMode string `json:"parse_mode,omitempty"`
+
+	// MediaType is one of the types of the media package.
+	MediaType string `json:"media_type,omitempty"`
+	FileID    string `json:"file_id,omitempty"`
+
+	// FromChatID and MessageID identify the forwarded message.
+	FromChatID int64 `json:"from_chat_id,omitempty"`
+	MessageID  int   `json:"message_id,omitempty"`
+
+	CreatedBy int   `json:"created_by"`
+	ReportTo  int64 `json:"report_to"`
+	// Lang is the language of the report sent to the creator.
+	Lang     string    `json:"lang"`
+	Created  time.Time `json:"created"`
+	Finished time.Time `json:"finished,omitempty"`
+	Status   string    `json:"status"`
+
+	Recipients []int64 `json:"recipients"`
+	Sent       int     `json:"sent"`
+	Failed     int     `json:"failed"`
+	Blocked    int     `json:"blocked"`
+}
+
+// Delivery is the outcome of sending a job to one chat.
+type Delivery struct {
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	MessageID int       `json:"message_id,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// Chattable returns the request delivering the job content to the chat.
+func (j *Job) Chattable(chatID int64) tgbotapi.Chattable {
+	switch j.Kind {
+	case KindForward:
+		return tgbotapi.NewForward(chatID, j.FromChatID, j.MessageID)
+	case KindMedia:
+		return media.Share(chatID, j.MediaType, j.FileID, j.Text, j.ParseMode)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, j.Text)
+	msg.ParseMode = j.ParseMode
+	return msg
+}
+
+// count adds the delivery to the counters of the job.
+func (j *Job) count(d Delivery) {
+	switch d.Status {
+	case DeliverySent:
+		j.Sent++
+	case DeliveryBlocked:
+		j.Blocked++
+	default:
+		j.Failed++
+	}
+}
+
+// Pending returns the number of recipients without a recorded delivery.
+func (j *Job) Pending() int {
+	return len(j.Recipients) - j.Sent - j.Failed - j.Blocked
+}
+
+// jobs persists broadcast jobs and their deliveries.
+type jobs struct {
+	store storage.Store
+}
+
+func (s jobs) get(id string) (*Job, error) {
+	var j Job
+	if err := s.store.Get(jobsBucket, id, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (s jobs) put(j *Job) error {
+	return s.store.Put(jobsBucket, j.ID, j)
+}
+
+// list returns all jobs, oldest first.
+func (s jobs) list() ([]*Job, error) {
+	ids, err := s.store.Keys(jobsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Job, 0, len(ids))
+	for _, id := range ids {
+		j, err := s.get(id)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, j)
+	}
+
+	return list, nil
+}
+
+func (s jobs) delivery(jobID string, chatID int64) (Delivery, bool) {
+	var d Delivery
+	err := s.store.Get(deliveriesBucket, deliveryKey(jobID, chatID), &d)
+	return d, err == nil
+}
+
+func (s jobs) deliver(jobID string, chatID int64, d Delivery) error {
+	return s.store.Put(deliveriesBucket, deliveryKey(jobID, chatID), d)
+}
+
+// prune deletes the recorded deliveries of the job. They are only needed to
+// resume a running job, and the finished one keeps its counters.
+func (s jobs) prune(j *Job) error {
+	for _, chatID := range j.Recipients {
+		if err := s.store.Delete(deliveriesBucket, deliveryKey(j.ID, chatID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func deliveryKey(jobID string, chatID int64) string {
+	return jobID + "/" + strconv.FormatInt(chatID, 10)
+}
+
+// newJobID returns a short ID ordered by creation time.
+func newJobID(now time.Time) string {
+	return strconv.FormatInt(now.UnixNano()/int64(time.Millisecond), 36)
+}
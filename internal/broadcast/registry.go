@@ -0,0 +1,178 @@
+package broadcast
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// chatsBucket stores the chats the bot has received updates from.
+const chatsBucket = "chats"
+
+// trackInterval limits how often the last seen time of a chat is updated.
+const trackInterval = time.Hour
+
+// Chat is a chat known to the bot.
+type Chat struct {
+	ID       int64     `json:"id"`
+	Type     string    `json:"type"`
+	Title    string    `json:"title,omitempty"`
+	LastSeen time.Time `json:"last_seen"`
+	// Inactive chats are skipped by broadcasts, e.g. because the bot was
+	// blocked or removed from the group.
+	Inactive       bool      `json:"inactive,omitempty"`
+	InactiveReason string    `json:"inactive_reason,omitempty"`
+	InactiveSince  time.Time `json:"inactive_since,omitempty"`
+}
+
+// Registry keeps track of all chats the bot has seen.
+type Registry struct {
+	Store storage.Store
+	Self  int // user ID of the bot
+}
+
+// Track is middleware registering the chat of every update. It also marks
+// groups the bot was removed from as inactive and follows group upgrades.
+func (r *Registry) Track(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		ch := c.Chat()
+		if ch == nil {
+			return next(c)
+		}
+
+		var err error
+		switch m := c.Update.Message; {
+		case m != nil && m.LeftChatMember != nil && m.LeftChatMember.ID == r.Self:
+			err = r.Deactivate(ch.ID, "removed from the chat")
+		case m != nil && m.MigrateToChatID != 0:
+			err = r.Migrate(ch.ID, m.MigrateToChatID)
+		default:
+			err = r.Seen(ch)
+		}
+		if err != nil {
+			log.Printf("Failed to track chat %d: %s", ch.ID, err)
+		}
+
+		return next(c)
+	}
+}
+
+// Seen registers the chat as active.
+func (r *Registry) Seen(ch *tgbotapi.Chat) error {
+	seen := Chat{ID: ch.ID, Type: ch.Type, Title: chatTitle(ch), LastSeen: time.Now()}
+
+	prev, err := r.Get(ch.ID)
+	if err == nil && !prev.Inactive && prev.Title == seen.Title && seen.LastSeen.Sub(prev.LastSeen) < trackInterval {
+		return nil
+	}
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+
+	return r.put(seen)
+}
+
+// Deactivate excludes the chat from broadcasts until an update comes from it again.
+func (r *Registry) Deactivate(id int64, reason string) error {
+	ch, err := r.Get(id)
+	if err == storage.ErrNotFound {
+		ch = Chat{ID: id}
+	} else if err != nil {
+		return err
+	}
+
+	if ch.Inactive {
+		return nil
+	}
+
+	ch.Inactive = true
+	ch.InactiveReason = reason
+	ch.InactiveSince = time.Now()
+
+	return r.put(ch)
+}
+
+// Migrate moves a group upgraded to a supergroup to its new ID.
+func (r *Registry) Migrate(from, to int64) error {
+	ch, err := r.Get(from)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+
+	if err := r.Store.Delete(chatsBucket, key(from)); err != nil {
+		return err
+	}
+
+	ch.ID = to
+	ch.Type = "supergroup"
+	ch.LastSeen = time.Now()
+
+	return r.put(ch)
+}
+
+// Get returns the chat with the ID.
+func (r *Registry) Get(id int64) (Chat, error) {
+	var ch Chat
+	err := r.Store.Get(chatsBucket, key(id), &ch)
+	ch.ID = id
+	return ch, err
+}
+
+// Active returns the IDs of all active chats.
+func (r *Registry) Active() ([]int64, error) {
+	keys, err := r.Store.Keys(chatsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]int64, 0, len(keys))
+	for _, k := range keys {
+		id, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		ch, err := r.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !ch.Inactive {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, nil
+}
+
+// Count returns the number of all and of active chats.
+func (r *Registry) Count() (total, active int, err error) {
+	keys, err := r.Store.Keys(chatsBucket)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ids, err := r.Active()
+
+	return len(keys), len(ids), err
+}
+
+func (r *Registry) put(ch Chat) error {
+	return r.Store.Put(chatsBucket, key(ch.ID), ch)
+}
+
+func key(id int64) string {
+	return strconv.FormatInt(id, 10)
+}
+
+func chatTitle(ch *tgbotapi.Chat) string {
+	if ch.Title != "" {
+		return ch.Title
+	}
+	return strings.TrimSpace(ch.FirstName + " " + ch.LastName)
+}
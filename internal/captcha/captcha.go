@@ -0,0 +1,325 @@
+// Package captcha makes new group members prove they are human before they
+// are allowed to write.
+package captcha
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	// callbackPrefix starts the data of the answer buttons.
+	callbackPrefix = "captcha:"
+	// maxAttempts is the number of answers a new member may give.
+	maxAttempts = 3
+	// checkInterval is how often expired challenges are looked for.
+	checkInterval = 5 * time.Second
+	// statusRestricted is the chat member status of restricted members.
+	statusRestricted = "restricted"
+)
+
+// Guard restricts new members until they answer a challenge. Members who
+// answer wrong too often or do not answer in time are kicked.
+type Guard struct {
+	bot        *bot.Bot
+	timeout    time.Duration
+	gen        *generator
+	challenges challenges
+
+	// mu serializes answers and timeouts of challenges. It only guards the
+	// stored challenges: a challenge is taken from the store under it, and
+	// the API calls that follow are made after it is released.
+	mu sync.Mutex
+}
+
+// New creates a guard giving new members timeout to answer.
+func New(b *bot.Bot, timeout time.Duration) *Guard {
+	return &Guard{
+		bot:        b,
+		timeout:    timeout,
+		gen:        newGenerator(),
+		challenges: challenges{store: b.Store},
+	}
+}
+
+// Register adds the middleware challenging new members and the handler of
+// the answer buttons to the router.
+func (g *Guard) Register(r *bot.Router) {
+	r.Use(g.join)
+	r.Handle(bot.IsCallback(callbackPrefix), g.answer)
+}
+
+// Run kicks the members who did not answer in time, including those whose
+// challenge was pending during a restart, until the context is done.
+func (g *Guard) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		g.expire()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// join challenges the humans joining a group. Other handlers still see the update.
+func (g *Guard) join(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		m := c.Update.Message
+		if m == nil || !m.Chat.IsGroup() && !m.Chat.IsSuperGroup() {
+			return next(c)
+		}
+
+		if m.NewChatMembers != nil {
+			for i := range *m.NewChatMembers {
+				u := &(*m.NewChatMembers)[i]
+				if u.IsBot {
+					continue
+				}
+				if err := g.challenge(m, u); err != nil {
+					log.Printf("Failed to challenge user %d in chat %d: %s", u.ID, m.Chat.ID, err)
+				}
+			}
+		}
+
+		if u := m.LeftChatMember; u != nil {
+			g.mu.Lock()
+			ch, err := g.challenges.get(m.Chat.ID, u.ID)
+			if err == nil {
+				g.forget(ch)
+			}
+			g.mu.Unlock()
+
+			if err == nil {
+				// The member wasn't restricted before the challenge, and
+				// keeps the rights when joining again.
+				if err := g.restrict(ch.ChatID, ch.UserID, true); err != nil {
+					log.Printf("Failed to unrestrict user %d in chat %d: %s", ch.UserID, ch.ChatID, err)
+				}
+				g.delete(ch.ChatID, ch.MessageID)
+			}
+		}
+
+		return next(c)
+	}
+}
+
+func (g *Guard) challenge(m *tgbotapi.Message, u *tgbotapi.User) error {
+	member, err := g.bot.API.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: m.Chat.ID, UserID: u.ID})
+	if err != nil {
+		return err
+	}
+	if member.Status == statusRestricted {
+		// Passing the challenge would lift the restrictions set by the admins.
+		return nil
+	}
+
+	if err := g.restrict(m.Chat.ID, u.ID, false); err != nil {
+		return err
+	}
+
+	ch := g.gen.challenge()
+	ch.ChatID = m.Chat.ID
+	ch.UserID = u.ID
+	ch.JoinMessageID = m.MessageID
+	ch.Deadline = time.Now().Add(g.timeout)
+
+	// The challenge is stored before it is sent, so the member is still kicked
+	// after the timeout if the bot stops in between.
+	if err := g.challenges.put(&ch); err != nil {
+		return err
+	}
+
+	lang := g.bot.I18n.Match(u.LanguageCode)
+	question := ch.Question
+	if ch.Kind == KindEmoji {
+		question = g.bot.I18n.Translate(lang, "captcha.emoji."+question)
+	}
+
+	text, err := render.Render(tgbotapi.ModeHTML, g.bot.I18n.Translate(lang, "captcha."+ch.Kind), map[string]interface{}{
+		"User":     u,
+		"Question": question,
+		"Seconds":  int(g.timeout / time.Second),
+	})
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = ch.Keyboard()
+
+	sent, err := g.bot.Sender.Send(msg)
+	if err != nil {
+		return err
+	}
+	ch.MessageID = sent.MessageID
+
+	g.mu.Lock()
+	_, err = g.challenges.get(ch.ChatID, ch.UserID)
+	expired := err != nil
+	if !expired {
+		err = g.challenges.put(&ch)
+	}
+	g.mu.Unlock()
+
+	if expired {
+		// A very short timeout expired the challenge meanwhile.
+		g.delete(ch.ChatID, ch.MessageID)
+		return nil
+	}
+	return err
+}
+
+func (g *Guard) answer(c *bot.Context) error {
+	q := c.Update.CallbackQuery
+	if q.Message == nil {
+		return c.Answer("", false)
+	}
+
+	userID, option, ok := parseCallbackData(q.Data)
+	if !ok {
+		return c.Answer("", false)
+	}
+	if q.From.ID != userID {
+		return c.Answer(c.T("captcha.not_yours"), false)
+	}
+
+	// The challenge is answered under the lock, so it is either answered or
+	// expired, and the member is unrestricted or kicked after it.
+	var passed bool
+	g.mu.Lock()
+	ch, err := g.challenges.get(q.Message.Chat.ID, userID)
+	if err == nil {
+		passed = option == ch.Answer
+		if !passed {
+			ch.Attempts++
+		}
+		if passed || ch.Attempts >= maxAttempts {
+			err = g.challenges.delete(ch)
+		} else {
+			err = g.challenges.put(ch)
+		}
+	}
+	g.mu.Unlock()
+
+	if err == storage.ErrNotFound {
+		return c.Answer(c.T("captcha.expired"), false)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case passed:
+		if err := g.restrict(ch.ChatID, ch.UserID, true); err != nil {
+			// The challenge is stored again, so the member can answer again.
+			g.mu.Lock()
+			if err := g.challenges.put(ch); err != nil {
+				log.Printf("Failed to store captcha challenge of user %d in chat %d: %s", ch.UserID, ch.ChatID, err)
+			}
+			g.mu.Unlock()
+			return err
+		}
+		g.delete(ch.ChatID, ch.MessageID)
+		return c.Answer(c.T("captcha.passed"), false)
+	case ch.Attempts >= maxAttempts:
+		g.fail(ch)
+		return c.Answer(c.T("captcha.failed"), true)
+	}
+
+	return c.Answer(c.TN("captcha.wrong", maxAttempts-ch.Attempts), true)
+}
+
+// expire fails the challenges whose deadline has passed.
+func (g *Guard) expire() {
+	g.mu.Lock()
+	list, err := g.challenges.expired(time.Now())
+	for _, ch := range list {
+		g.forget(ch)
+	}
+	g.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Failed to load captcha challenges: %s", err)
+		return
+	}
+	for _, ch := range list {
+		g.fail(ch)
+	}
+}
+
+// fail kicks the member of the forgotten challenge and deletes its messages.
+// The member may join again and get a new challenge.
+func (g *Guard) fail(ch *Challenge) {
+	member := tgbotapi.ChatMemberConfig{ChatID: ch.ChatID, UserID: ch.UserID}
+	if _, err := g.bot.API.KickChatMember(tgbotapi.KickChatMemberConfig{ChatMemberConfig: member}); err != nil {
+		log.Printf("Failed to kick user %d from chat %d: %s", ch.UserID, ch.ChatID, err)
+	} else if _, err := g.bot.API.UnbanChatMember(member); err != nil {
+		log.Printf("Failed to unban user %d in chat %d: %s", ch.UserID, ch.ChatID, err)
+	}
+
+	g.delete(ch.ChatID, ch.JoinMessageID)
+	g.delete(ch.ChatID, ch.MessageID)
+}
+
+// forget deletes the stored challenge. It is called with mu held.
+func (g *Guard) forget(ch *Challenge) {
+	if err := g.challenges.delete(ch); err != nil {
+		log.Printf("Failed to delete captcha challenge of user %d in chat %d: %s", ch.UserID, ch.ChatID, err)
+	}
+}
+
+// delete deletes a message. Errors are logged only, the message may be gone already.
+func (g *Guard) delete(chatID int64, messageID int) {
+	if messageID == 0 {
+		return
+	}
+	if _, err := g.bot.API.DeleteMessage(tgbotapi.NewDeleteMessage(chatID, messageID)); err != nil {
+		log.Printf("Failed to delete message %d in chat %d: %s", messageID, chatID, err)
+	}
+}
+
+// restrict takes away or gives back the right of the user to write.
+func (g *Guard) restrict(chatID int64, userID int, allow bool) error {
+	_, err := g.bot.API.RestrictChatMember(tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig:      tgbotapi.ChatMemberConfig{ChatID: chatID, UserID: userID},
+		CanSendMessages:       &allow,
+		CanSendMediaMessages:  &allow,
+		CanSendOtherMessages:  &allow,
+		CanAddWebPagePreviews: &allow,
+	})
+	return err
+}
+
+func callbackData(userID int, option string) string {
+	return callbackPrefix + strconv.Itoa(userID) + ":" + option
+}
+
+func parseCallbackData(data string) (userID int, option string, ok bool) {
+	parts := strings.SplitN(strings.TrimPrefix(data, callbackPrefix), ":", 2)
+	if len(parts) != 2 {
+		return 0, "", false
+	}
+
+	userID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", false
+	}
+
+	return userID, parts[1], true
+}
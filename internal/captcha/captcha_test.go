@@ -0,0 +1,148 @@
+package captcha
+
+import (
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newGuard returns a guard whose API answers with the results by method and
+// records the called methods. The API calls check that the lock is free.
+func newGuard(t *testing.T, results map[string]string) (*Guard, *[]string) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var g *Guard
+	var methods []string
+	api := &tgbotapi.BotAPI{Token: "token", Client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+		methods = append(methods, method)
+
+		locked := make(chan struct{})
+		go func() {
+			g.mu.Lock()
+			g.mu.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			t.Errorf("%s is called with the lock held", method)
+		}
+
+		result, ok := results[method]
+		if !ok {
+			result = "true"
+		}
+		body := `{"ok":true,"result":` + result + `}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})}}
+
+	b := bot.New(api, storage.NewMemory(), bundle)
+	b.Sender = api
+	g = New(b, time.Minute)
+	return g, &methods
+}
+
+func join(chatID int64, userID int) *bot.Context {
+	return &bot.Context{Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID:      10,
+		Chat:           &tgbotapi.Chat{ID: chatID, Type: "supergroup"},
+		NewChatMembers: &[]tgbotapi.User{{ID: userID, FirstName: "Ann"}},
+	}}}
+}
+
+func TestJoinSkipsRestrictedMembers(t *testing.T) {
+	for _, status := range []string{"member", statusRestricted} {
+		g, methods := newGuard(t, map[string]string{
+			"getChatMember": `{"user":{"id":2},"status":"` + status + `"}`,
+			"sendMessage":   `{"message_id":11,"chat":{"id":-1}}`,
+		})
+		c := join(-1, 2)
+		c.Bot = g.bot
+		if err := g.join(func(*bot.Context) error { return nil })(c); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := g.challenges.get(-1, 2)
+		if challenged := err == nil; challenged != (status != statusRestricted) {
+			t.Errorf("%s member: challenged = %v after %v", status, challenged, *methods)
+		}
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	tests := []struct {
+		option   string
+		attempts int
+		want     []string
+		stored   bool
+	}{
+		{"right", 0, []string{"restrictChatMember", "deleteMessage", "answerCallbackQuery"}, false},
+		{"wrong", 0, []string{"answerCallbackQuery"}, true},
+		{"wrong", maxAttempts - 1, []string{"kickChatMember", "unbanChatMember", "deleteMessage", "deleteMessage", "answerCallbackQuery"}, false},
+	}
+	for _, tt := range tests {
+		g, methods := newGuard(t, nil)
+		ch := &Challenge{ChatID: -1, UserID: 2, Answer: "right", Attempts: tt.attempts, MessageID: 11, JoinMessageID: 10, Deadline: time.Now().Add(time.Minute)}
+		if err := g.challenges.put(ch); err != nil {
+			t.Fatal(err)
+		}
+
+		c := &bot.Context{Bot: g.bot, Update: tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "q",
+			From:    &tgbotapi.User{ID: 2},
+			Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -1}},
+			Data:    callbackData(2, tt.option),
+		}}}
+		if err := g.answer(c); err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Join(*methods, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s after %d attempts: called %v, want %v", tt.option, tt.attempts, *methods, tt.want)
+		}
+		if _, err := g.challenges.get(-1, 2); (err == nil) != tt.stored {
+			t.Errorf("%s after %d attempts: stored = %v, want %v", tt.option, tt.attempts, err == nil, tt.stored)
+		}
+	}
+}
+
+func TestExpire(t *testing.T) {
+	g, methods := newGuard(t, nil)
+	for i, deadline := range []time.Time{time.Now().Add(-time.Second), time.Now().Add(time.Minute)} {
+		ch := &Challenge{ChatID: -1, UserID: i + 1, Deadline: deadline}
+		if err := g.challenges.put(ch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	g.expire()
+
+	if want := "kickChatMember unbanChatMember"; strings.Join(*methods, " ") != want {
+		t.Errorf("called %v, want %s", *methods, want)
+	}
+	if _, err := g.challenges.get(-1, 1); err != storage.ErrNotFound {
+		t.Errorf("expired challenge: get() error = %v, want not found", err)
+	}
+	if _, err := g.challenges.get(-1, 2); err != nil {
+		t.Errorf("pending challenge: get() error = %v", err)
+	}
+}
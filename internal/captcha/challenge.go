@@ -0,0 +1,187 @@
+package captcha
+
+import (
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// challengesBucket stores the pending challenges.
+const challengesBucket = "captcha"
+
+// Kinds of challenges.
+const (
+	KindMath  = "math"
+	KindEmoji = "emoji"
+)
+
+// options is the number of answer buttons of a challenge.
+const options = 6
+
+// emoji are the pictures of the emoji challenge. The names are translated
+// with the captcha.emoji.<name> keys.
+var emoji = []struct{ name, char string }{
+	{"apple", "🍎"},
+	{"car", "🚗"},
+	{"cat", "🐱"},
+	{"dog", "🐶"},
+	{"flower", "🌸"},
+	{"house", "🏠"},
+	{"star", "⭐"},
+	{"sun", "☀️"},
+}
+
+// Challenge is a question a new member has to answer to be allowed to write.
+type Challenge struct {
+	ChatID int64 `json:"chat_id"`
+	UserID int   `json:"user_id"`
+
+	Kind string `json:"kind"`
+	// Question is the math expression or the name of the emoji to pick.
+	Question string   `json:"question"`
+	Answer   string   `json:"answer"`
+	Options  []string `json:"options"`
+
+	// JoinMessageID and MessageID are deleted when the challenge is over.
+	JoinMessageID int       `json:"join_message_id"`
+	MessageID     int       `json:"message_id"`
+	Attempts      int       `json:"attempts"`
+	Deadline      time.Time `json:"deadline"`
+}
+
+// Keyboard returns the answer buttons of the challenge.
+func (ch *Challenge) Keyboard() tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, o := range ch.Options {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(o, callbackData(ch.UserID, o)))
+		if len(row) == 3 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// generator creates random challenges.
+type generator struct {
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newGenerator() *generator {
+	return &generator{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// challenge returns a random math or emoji challenge.
+func (g *generator) challenge() Challenge {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.rnd.Intn(2) == 0 {
+		return g.math()
+	}
+	return g.emoji()
+}
+
+func (g *generator) math() Challenge {
+	a, b := 1+g.rnd.Intn(9), 1+g.rnd.Intn(9)
+	answer := a + b
+
+	// The wrong options are close to the answer, so guessing does not help.
+	values := map[int]bool{answer: true}
+	for len(values) < options {
+		if v := answer - options + g.rnd.Intn(2*options); v > 0 {
+			values[v] = true
+		}
+	}
+
+	var opts []string
+	for v := range values {
+		opts = append(opts, strconv.Itoa(v))
+	}
+
+	return Challenge{
+		Kind:     KindMath,
+		Question: strconv.Itoa(a) + " + " + strconv.Itoa(b),
+		Answer:   strconv.Itoa(answer),
+		Options:  g.shuffle(opts),
+	}
+}
+
+func (g *generator) emoji() Challenge {
+	picked := g.rnd.Perm(len(emoji))[:options]
+
+	opts := make([]string, 0, options)
+	for _, i := range picked {
+		opts = append(opts, emoji[i].char)
+	}
+
+	answer := emoji[picked[g.rnd.Intn(options)]]
+
+	return Challenge{
+		Kind:     KindEmoji,
+		Question: answer.name,
+		Answer:   answer.char,
+		Options:  opts,
+	}
+}
+
+func (g *generator) shuffle(s []string) []string {
+	g.rnd.Shuffle(len(s), func(i, j int) { s[i], s[j] = s[j], s[i] })
+	return s
+}
+
+// challenges persists the pending challenges, so their timeouts survive restarts.
+type challenges struct {
+	store storage.Store
+}
+
+func (s challenges) get(chatID int64, userID int) (*Challenge, error) {
+	var ch Challenge
+	if err := s.store.Get(challengesBucket, challengeKey(chatID, userID), &ch); err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
+
+func (s challenges) put(ch *Challenge) error {
+	return s.store.Put(challengesBucket, challengeKey(ch.ChatID, ch.UserID), ch)
+}
+
+func (s challenges) delete(ch *Challenge) error {
+	return s.store.Delete(challengesBucket, challengeKey(ch.ChatID, ch.UserID))
+}
+
+// expired returns the challenges whose deadline has passed.
+func (s challenges) expired(now time.Time) ([]*Challenge, error) {
+	keys, err := s.store.Keys(challengesBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Challenge
+	for _, k := range keys {
+		var ch Challenge
+		if err := s.store.Get(challengesBucket, k, &ch); err != nil {
+			return nil, err
+		}
+		if now.After(ch.Deadline) {
+			list = append(list, &ch)
+		}
+	}
+
+	return list, nil
+}
+
+func challengeKey(chatID int64, userID int) string {
+	return strconv.FormatInt(chatID, 10) + "/" + strconv.Itoa(userID)
+}
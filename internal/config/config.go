@@ -0,0 +1,381 @@
+// Package config reads the bot configuration from environment variables.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config is the bot configuration.
+type Config struct {
+	// Name tells the bot apart from the other bots of the process, see
+	// Offline. It is empty when the process runs a single unnamed bot.
+	Name string
+	// Token is the bot API token provided by @BotFather.
+	Token string
+	// Debug makes the API client log every request and response.
+	Debug bool
+	// AdminIDs are the user IDs of the bot owners allowed to run admin commands.
+	AdminIDs []int
+	// StoragePath is the path of the state journal. Empty means in-memory state.
+	StoragePath string
+	// LocalesDir is the directory with the message catalogs.
+	LocalesDir string
+	// DefaultLanguage is used when the language of a user is unknown or unsupported.
+	DefaultLanguage string
+	// DocumentThreshold is the text length above which a message is sent as
+	// a .txt document instead of several messages. Zero disables documents.
+	DocumentThreshold int
+	// WarnActions lists the moderation actions taken automatically after a
+	// number of warnings, see moderation.ParsePolicy.
+	WarnActions string
+	// CaptchaTimeout is the time new group members have to answer the
+	// challenge. Zero disables the challenge.
+	CaptchaTimeout time.Duration
+	// StatsReport is the cron schedule of the stats report sent to the bot
+	// owners. Empty disables the report.
+	StatsReport string
+	// AuditRetention is how long audit log entries are kept. Zero keeps them forever.
+	AuditRetention time.Duration
+	// HTTPAddr is the address of the HTTP server exposing /metrics, the
+	// health probes and the webhook. Empty disables it.
+	HTTPAddr string
+	// WebhookURL is the public URL Telegram posts updates to. Empty means the
+	// bot polls for updates. The webhook is served on the path of the URL.
+	WebhookURL string
+	// DeleteWebhook makes the bot remove a webhook keeping it from polling
+	// for updates.
+	DeleteWebhook bool
+	// UpdateQueue is the queue between receiving and handling updates: memory
+	// or file. Empty means the updates are handled one at a time as received.
+	UpdateQueue string
+	// QueueDir is the directory of the file queue.
+	QueueDir string
+	// QueueWorkers is the number of queue partitions, each handled by a worker.
+	QueueWorkers int
+	// LeaderLock is the path of the lock file electing the instance polling
+	// for updates. Empty disables the election.
+	LeaderLock string
+	// ReadyTimeout is how long ago updates may have been received for the
+	// bot to be ready.
+	ReadyTimeout time.Duration
+	// RecordFile is the file the received updates and the API calls are
+	// recorded to. Empty disables recording.
+	RecordFile string
+	// TraceExporter is where spans are exported: otlp, stdout or file. Empty
+	// disables tracing.
+	TraceExporter string
+	// TraceEndpoint is the OTLP/HTTP endpoint or the path of the trace file.
+	TraceEndpoint string
+	// TraceService is the service name reported with the spans.
+	TraceService string
+	// Modules chooses the enabled modules, see module.New. Empty enables all.
+	Modules []string
+}
+
+// Bots returns the names of the bots the process runs, listed in BOTS. A
+// single bot without a name runs if BOTS is empty.
+func Bots() ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(os.Getenv("BOTS"), ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("config: BOTS: %q is not a valid bot name, use lowercase letters, digits and _", name)
+		}
+		for _, other := range names {
+			if other == name {
+				return nil, fmt.Errorf("config: BOTS: %q is listed twice", name)
+			}
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return []string{""}, nil
+	}
+	return names, nil
+}
+
+var validName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// All loads the configuration of every bot of the process and checks that
+// they don't get in each other's way.
+func All() ([]Config, error) {
+	names, err := Bots()
+	if err != nil {
+		return nil, err
+	}
+
+	cfgs := make([]Config, len(names))
+	for i, name := range names {
+		if cfgs[i], err = FromEnv(name); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, cfg := range cfgs {
+		for _, other := range cfgs[:i] {
+			switch {
+			case cfg.Token == other.Token:
+				return nil, fmt.Errorf("config: bots %s and %s have the same token", other.Name, cfg.Name)
+			case cfg.WebhookURL != "" && other.WebhookURL != "" && cfg.WebhookPath() == other.WebhookPath():
+				return nil, fmt.Errorf("config: bots %s and %s have webhooks on the same path %s", other.Name, cfg.Name, cfg.WebhookPath())
+			case cfg.RecordFile != "" && cfg.RecordFile == other.RecordFile:
+				return nil, fmt.Errorf("config: bots %s and %s record to the same file, set %s_RECORD_FILE", other.Name, cfg.Name, strings.ToUpper(cfg.Name))
+			}
+		}
+	}
+	return cfgs, nil
+}
+
+// FromEnv loads the configuration of the bot from the environment. The name
+// may be empty when BOTS lists a single bot or none.
+func FromEnv(name string) (Config, error) {
+	cfg, err := Offline(name)
+	if err == nil && cfg.Token == "" {
+		err = fmt.Errorf("config: %sTELEGRAM_APITOKEN is not set", prefix(cfg.Name))
+	}
+	return cfg, err
+}
+
+// Offline loads the configuration like FromEnv but doesn't require the token,
+// for commands not calling the Bot API.
+//
+// The settings of a bot named in BOTS are read from the variables prefixed
+// with its name in upper case, SHOP_ADMIN_IDS for the bot shop, falling back
+// to the unprefixed ones. The storage, the HTTP server, the leader lock and
+// tracing are shared by the bots and have no prefixed variables.
+func Offline(name string) (Config, error) {
+	names, err := Bots()
+	if err != nil {
+		return Config{}, err
+	}
+	switch {
+	case name == "" && len(names) == 1:
+		name = names[0]
+	case name == "":
+		return Config{}, fmt.Errorf("config: BOTS lists several bots, choose one of %s", strings.Join(names, ", "))
+	case !contains(names, name):
+		return Config{}, fmt.Errorf("config: unknown bot %q, BOTS lists %s", name, strings.Join(names, ", "))
+	}
+
+	shared, own := env(""), env(prefix(name))
+	cfg := Config{
+		Name:            name,
+		Token:           own.getenv("TELEGRAM_APITOKEN", ""),
+		StoragePath:     shared.getenv("STORAGE_PATH", "data/state.jsonl"),
+		LocalesDir:      shared.getenv("LOCALES_DIR", "locales"),
+		DefaultLanguage: own.getenv("DEFAULT_LANGUAGE", "en"),
+		WarnActions:     own.getenv("WARN_ACTIONS", "3:mute:1d,5:ban"),
+		StatsReport:     own.getenv("STATS_REPORT", ""),
+		HTTPAddr:        shared.getenv("HTTP_ADDR", os.Getenv("METRICS_ADDR")),
+		WebhookURL:      own.getenv("WEBHOOK_URL", ""),
+		LeaderLock:      shared.getenv("LEADER_LOCK", ""),
+		UpdateQueue:     own.getenv("UPDATE_QUEUE", ""),
+		QueueDir:        filepath.Join(shared.getenv("QUEUE_DIR", "data/queue"), name),
+		RecordFile:      own.getenv("RECORD_FILE", ""),
+		TraceExporter:   shared.getenv("TRACE_EXPORTER", ""),
+		TraceEndpoint:   shared.getenv("TRACE_ENDPOINT", ""),
+		TraceService:    shared.getenv("TRACE_SERVICE", "telegram-bot"),
+		Modules:         own.getenvList("MODULES"),
+	}
+
+	if cfg.Debug, err = own.getenvBool("BOT_DEBUG", false); err != nil {
+		return cfg, err
+	}
+	if cfg.DeleteWebhook, err = own.getenvBool("DELETE_WEBHOOK", false); err != nil {
+		return cfg, err
+	}
+	if cfg.AdminIDs, err = own.getenvInts("ADMIN_IDS"); err != nil {
+		return cfg, err
+	}
+	if cfg.DocumentThreshold, err = own.getenvInt("DOCUMENT_THRESHOLD", 0); err != nil {
+		return cfg, err
+	}
+	if cfg.QueueWorkers, err = own.getenvInt("QUEUE_WORKERS", 4); err != nil {
+		return cfg, err
+	}
+
+	if cfg.CaptchaTimeout, err = own.getenvDuration("CAPTCHA_TIMEOUT", 2*time.Minute); err != nil {
+		return cfg, err
+	}
+	if cfg.AuditRetention, err = own.getenvDuration("AUDIT_RETENTION", 90*24*time.Hour); err != nil {
+		return cfg, err
+	}
+	if cfg.ReadyTimeout, err = own.getenvDuration("READY_TIMEOUT", 3*time.Minute); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// WebhookPath returns the path of the webhook URL the updates are served on.
+func (cfg Config) WebhookPath() string {
+	u, err := url.Parse(cfg.WebhookURL)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// Validate checks the settings depending on each other. Call it after
+// changing the loaded configuration.
+func (cfg Config) Validate() error {
+	switch cfg.UpdateQueue {
+	case "", "memory", "file":
+	default:
+		return fmt.Errorf("config: UPDATE_QUEUE must be memory or file, not %q", cfg.UpdateQueue)
+	}
+	if cfg.QueueWorkers < 1 {
+		return errors.New("config: QUEUE_WORKERS must be positive")
+	}
+	switch cfg.TraceExporter {
+	case "", "otlp", "stdout", "file":
+	default:
+		return fmt.Errorf("config: TRACE_EXPORTER must be otlp, stdout or file, not %q", cfg.TraceExporter)
+	}
+	if cfg.WebhookURL != "" {
+		u, err := url.Parse(cfg.WebhookURL)
+		if err != nil {
+			return fmt.Errorf("config: WEBHOOK_URL: %s", err)
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return errors.New("config: WEBHOOK_URL must be an https URL")
+		}
+		if cfg.HTTPAddr == "" {
+			return errors.New("config: WEBHOOK_URL requires HTTP_ADDR")
+		}
+		switch cfg.WebhookPath() {
+		case "/metrics", "/healthz", "/readyz":
+			return fmt.Errorf("config: WEBHOOK_URL must not have the path %s, it is taken", cfg.WebhookPath())
+		}
+	}
+
+	return nil
+}
+
+// env reads environment variables, preferring the ones with its prefix.
+type env string
+
+// prefix returns the prefix of the variables of the named bot.
+func prefix(name string) string {
+	if name == "" {
+		return ""
+	}
+	return strings.ToUpper(name) + "_"
+}
+
+// lookup returns the value of the variable with the prefix, or of the
+// variable itself, and the name of the variable found.
+func (e env) lookup(key string) (string, string, bool) {
+	if e != "" {
+		if v, ok := os.LookupEnv(string(e) + key); ok {
+			return v, string(e) + key, true
+		}
+	}
+	v, ok := os.LookupEnv(key)
+	return v, key, ok
+}
+
+// getenv returns the value of the environment variable or def if it is not set.
+func (e env) getenv(key, def string) string {
+	if v, _, ok := e.lookup(key); ok {
+		return v
+	}
+	return def
+}
+
+// getenvInt returns the integer value of the environment variable or def if it is not set.
+func (e env) getenvInt(key string, def int) (int, error) {
+	v, key, ok := e.lookup(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("config: %s: %s", key, err)
+	}
+
+	return n, nil
+}
+
+// getenvBool returns the boolean value of the environment variable or def if it is not set.
+func (e env) getenvBool(key string, def bool) (bool, error) {
+	v, key, ok := e.lookup(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("config: %s: %s", key, err)
+	}
+
+	return b, nil
+}
+
+// getenvDuration returns the duration value of the environment variable or def if it is not set.
+func (e env) getenvDuration(key string, def time.Duration) (time.Duration, error) {
+	v, key, ok := e.lookup(key)
+	if !ok || v == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("config: %s: %s", key, err)
+	}
+
+	return d, nil
+}
+
+// getenvInts parses the environment variable as a comma-separated list of integers.
+func (e env) getenvInts(key string) ([]int, error) {
+	v, key, _ := e.lookup(key)
+
+	var ints []int
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("config: %s: %s", key, err)
+		}
+		ints = append(ints, n)
+	}
+
+	return ints, nil
+}
+
+// getenvList parses the environment variable as a comma-separated list.
+func (e env) getenvList(key string) []string {
+	v, _, _ := e.lookup(key)
+
+	var list []string
+	for _, f := range strings.Split(v, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			list = append(list, f)
+		}
+	}
+	return list
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
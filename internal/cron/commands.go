@@ -0,0 +1,75 @@
+package cron
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+)
+
+// timeLayout formats the job times.
+const timeLayout = "02 Jan 15:04:05 MST"
+
+// Register adds the /jobs command, guarded by the only middleware, to the
+// router. The command lists and runs only the jobs named with the prefix,
+// which it shows without it, so a bot sharing the scheduler with others only
+// sees its own jobs. An empty prefix shows all jobs.
+func (s *Scheduler) Register(r *bot.Router, only bot.Middleware, prefix string) {
+	r.Command("jobs", only(func(c *bot.Context) error {
+		return s.command(c, prefix)
+	}))
+}
+
+// command handles /jobs, listing the jobs, and /jobs run <name>.
+func (s *Scheduler) command(c *bot.Context, prefix string) error {
+	args := strings.Fields(c.Update.Message.CommandArguments())
+	switch {
+	case len(args) == 0:
+		return s.list(c, prefix)
+	case len(args) == 2 && args[0] == "run":
+		switch err := s.Trigger(prefix + args[1]); err {
+		case nil:
+			return c.Reply(c.T("cron.triggered", "name", args[1]))
+		case ErrRunning:
+			return c.Reply(c.T("cron.running", "name", args[1]))
+		default:
+			return c.Reply(c.T("cron.failed", "error", err))
+		}
+	}
+	return c.Reply(c.T("cron.usage"))
+}
+
+func (s *Scheduler) list(c *bot.Context, prefix string) error {
+	var lines []string
+	for _, j := range s.Jobs() {
+		if !strings.HasPrefix(j.Name, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(j.Name, prefix)
+		lines = append(lines, c.T("cron.job", "name", name, "spec", j.Spec, "next", format(j.Next)))
+
+		switch {
+		case j.Running:
+			lines = append(lines, c.T("cron.job_running", "start", format(j.LastStart)))
+		case j.Runs > 0:
+			lines = append(lines, c.T("cron.job_last",
+				"start", format(j.LastStart), "duration", j.LastDuration.Round(time.Millisecond),
+				"runs", j.Runs, "failures", j.Failures))
+		}
+		if j.LastError != "" {
+			lines = append(lines, c.T("cron.job_error", "error", j.LastError))
+		}
+	}
+	if len(lines) == 0 {
+		return c.Reply(c.T("cron.none"))
+	}
+
+	return c.Reply(strings.Join(lines, "\n"))
+}
+
+func format(t time.Time) string {
+	if t.IsZero() {
+		return "—"
+	}
+	return t.Format(timeLayout)
+}
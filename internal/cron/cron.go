@@ -0,0 +1,243 @@
+// Package cron runs the periodic jobs of the bot, like reports and cleanups,
+// on cron schedules.
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Func is the function of a job. It should return soon after the context is
+// cancelled, which happens when the bot stops.
+type Func func(ctx context.Context) error
+
+// Status describes a job and its last run.
+type Status struct {
+	Name   string
+	Spec   string
+	Jitter time.Duration
+	// Next is the time of the next scheduled run.
+	Next time.Time
+
+	Running      bool
+	Runs         int
+	Failures     int
+	LastStart    time.Time
+	LastDuration time.Duration
+	// LastError is the error of the last run, or "" if it succeeded.
+	LastError string
+}
+
+type job struct {
+	schedule Schedule
+	run      Func
+
+	mu     sync.Mutex
+	status Status
+}
+
+// ErrRunning is returned by Trigger when the job is already running.
+var ErrRunning = errors.New("cron: the job is already running")
+
+// Scheduler runs the jobs. A job never runs concurrently with itself: a run
+// due while the previous one is still going is skipped.
+//
+// The bots of a process share a scheduler, every bot adds its jobs to it.
+type Scheduler struct {
+	rnd *rand.Rand
+
+	mu   sync.Mutex
+	jobs []*job
+	// ctx and wg are set by Run for the runs triggered with /jobs.
+	ctx context.Context
+	wg  sync.WaitGroup
+}
+
+// New creates a scheduler without jobs.
+func New() *Scheduler {
+	return &Scheduler{rnd: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Add registers the job. Each run is delayed by a random duration up to the
+// jitter, so jobs of several bots do not hit the API at the same time. Jobs
+// must be added before Run.
+func (s *Scheduler) Add(name, spec string, jitter time.Duration, fn Func) error {
+	schedule, err := Parse(spec)
+	if err != nil {
+		return err
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("cron: %q is never due", spec)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, j := range s.jobs {
+		if j.status.Name == name {
+			return fmt.Errorf("cron: duplicate job %q", name)
+		}
+	}
+	s.jobs = append(s.jobs, &job{
+		schedule: schedule,
+		run:      fn,
+		status:   Status{Name: name, Spec: spec, Jitter: jitter},
+	})
+
+	return nil
+}
+
+// Run runs the jobs on their schedules until the context is cancelled, then
+// waits for the running jobs to return.
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	jobs := s.jobs
+	s.mu.Unlock()
+
+	for _, j := range jobs {
+		s.wg.Add(1)
+		go func(j *job) {
+			defer s.wg.Done()
+			s.loop(ctx, j)
+		}(j)
+	}
+
+	<-ctx.Done()
+
+	// No runs can be triggered once the wait begins.
+	s.mu.Lock()
+	s.ctx = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *job) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			log.Printf("Job %s is never due", j.status.Name)
+			return
+		}
+
+		j.mu.Lock()
+		j.status.Next = next
+		jitter := j.status.Jitter
+		j.mu.Unlock()
+
+		delay := time.Until(next)
+		if jitter > 0 {
+			s.mu.Lock()
+			delay += time.Duration(s.rnd.Int63n(int64(jitter)))
+			s.mu.Unlock()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := s.execute(ctx, j); err == ErrRunning {
+			log.Printf("Job %s skipped: the previous run has not finished", j.status.Name)
+		}
+	}
+}
+
+// Trigger runs the job now in the background.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j := s.job(name)
+	switch {
+	case j == nil:
+		return fmt.Errorf("cron: unknown job %q", name)
+	case s.ctx == nil:
+		return errors.New("cron: the scheduler is not running")
+	}
+
+	j.mu.Lock()
+	running := j.status.Running
+	j.mu.Unlock()
+	if running {
+		return ErrRunning
+	}
+
+	ctx := s.ctx
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		s.execute(ctx, j)
+	}()
+
+	return nil
+}
+
+// execute runs the job unless it is already running, recovering from panics.
+func (s *Scheduler) execute(ctx context.Context, j *job) (err error) {
+	j.mu.Lock()
+	if j.status.Running {
+		j.mu.Unlock()
+		return ErrRunning
+	}
+	j.status.Running = true
+	j.status.LastStart = time.Now()
+	name := j.status.Name
+	j.mu.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			log.Printf("Job %s panicked: %v\n%s", name, r, debug.Stack())
+		}
+		if err != nil {
+			log.Printf("Job %s failed: %s", name, err)
+		}
+
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.status.Running = false
+		j.status.Runs++
+		j.status.LastDuration = time.Since(j.status.LastStart)
+		j.status.LastError = ""
+		if err != nil {
+			j.status.Failures++
+			j.status.LastError = err.Error()
+		}
+	}()
+
+	return j.run(ctx)
+}
+
+// Jobs returns the status of the jobs in the order they were added.
+func (s *Scheduler) Jobs() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list := make([]Status, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		list = append(list, j.status)
+		j.mu.Unlock()
+	}
+	return list
+}
+
+// job returns the job with the name or nil. The caller must hold s.mu.
+func (s *Scheduler) job(name string) *job {
+	for _, j := range s.jobs {
+		if j.status.Name == name {
+			return j
+		}
+	}
+	return nil
+}
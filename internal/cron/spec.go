@@ -0,0 +1,215 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+	// The bits of the fields are set for the allowed values.
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set when the day fields are *. A day matches if
+	// either restricted day field matches, as in the standard cron.
+	domAny, dowAny bool
+	// every is the interval of @every schedules.
+	every time.Duration
+}
+
+// field describes the range of a cron field.
+type field struct {
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minutes = field{min: 0, max: 59}
+	hours   = field{min: 0, max: 23}
+	doms    = field{min: 1, max: 31}
+	months  = field{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// Both 0 and 7 are Sunday.
+	dows = field{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// allHours is the hour field of schedules running every hour.
+const allHours = 1<<24 - 1
+
+// descriptors are the shortcuts for common expressions.
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a cron expression with the five standard fields: minute, hour,
+// day of month, month and day of week. Fields are *, values, ranges (1-5) and
+// lists of them (1,15), optionally with a step (*/10). Months and weekdays
+// can be named (jan, mon). The descriptors @hourly, @daily, @weekly, @monthly,
+// @yearly and "@every <duration>" are supported too.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[len("@every "):]))
+		if err != nil || d < time.Second {
+			return Schedule{}, fmt.Errorf("cron: invalid interval in %q", spec)
+		}
+		return Schedule{every: d}, nil
+	}
+	if expr, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = expr
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("cron: %q must have 5 fields", spec)
+	}
+
+	var s Schedule
+	var err error
+	for i, p := range []struct {
+		bits *uint64
+		f    field
+	}{{&s.minute, minutes}, {&s.hour, hours}, {&s.dom, doms}, {&s.month, months}, {&s.dow, dows}} {
+		if *p.bits, err = parseField(fields[i], p.f); err != nil {
+			return Schedule{}, fmt.Errorf("cron: %q: %s", spec, err)
+		}
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny, s.dowAny = fields[2] == "*", fields[4] == "*"
+
+	return s, nil
+}
+
+func parseField(expr string, f field) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(expr, ",") {
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+			item, step = item[:i], n
+		}
+
+		lo, hi := f.min, f.max
+		if item != "*" {
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			if lo, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = f.value(bounds[1]); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 5/10 means from 5 to the end with the step.
+				hi = f.max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %q", item)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	return v, nil
+}
+
+// Next returns the first time matching the schedule after t, or the zero
+// time if there is none within five years (like February 30). Times skipped
+// when the clocks go forward don't run that day, and schedules with fixed
+// hours don't run again in the hour repeated when the clocks go back.
+func (s Schedule) Next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for limit := t.AddDate(5, 0, 0); t.Before(limit); {
+		switch {
+		case !has(s.month, int(t.Month())):
+			t = forward(t, t.Year(), t.Month()+1, 1, 0, 0)
+		case !s.day(t):
+			t = forward(t, t.Year(), t.Month(), t.Day()+1, 0, 0)
+		case !has(s.hour, t.Hour()):
+			t = forward(t, t.Year(), t.Month(), t.Day(), t.Hour()+1, 0)
+		case !has(s.minute, t.Minute()):
+			if s.hour == allHours {
+				// Schedules running every hour run in the hour repeated
+				// when the clocks go back too.
+				t = t.Add(time.Minute)
+			} else {
+				// The wall clock never goes back, so the times in the
+				// repeated hour run once.
+				t = forward(t, t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1)
+			}
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// forward returns the wall clock time in the location of t, which is later
+// than t. A time skipped when the clocks go forward becomes the time after
+// the gap, where time.Date would normalize it to one before the gap.
+func forward(t time.Time, year int, month time.Month, day, hour, minute int) time.Time {
+	next := time.Date(year, month, day, hour, minute, 0, 0, t.Location())
+	if next.After(t) {
+		return next
+	}
+
+	// The wall clock difference, as if the clocks didn't change.
+	wall := time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	from := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+	return t.Add(wall.Sub(from))
+}
+
+// day reports whether the day of t matches the day fields.
+func (s Schedule) day(t time.Time) bool {
+	dom, dow := has(s.dom, t.Day()), has(s.dow, int(t.Weekday()))
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dow
+	case s.dowAny:
+		return dom
+	}
+	return dom || dow
+}
+
+func has(set uint64, v int) bool {
+	return set&(1<<uint(v)) != 0
+}
@@ -0,0 +1,144 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Schedule
+		err  bool
+	}{
+		{spec: "* * * * *", want: Schedule{minute: 1<<60 - 1, hour: allHours, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1<<8 - 1, domAny: true, dowAny: true}},
+		{spec: "5 9 1 1 0", want: Schedule{minute: 1 << 5, hour: 1 << 9, dom: 1 << 1, month: 1 << 1, dow: 1}},
+		{spec: "1-3 * * * *", want: Schedule{minute: 1<<1 | 1<<2 | 1<<3, hour: allHours, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1<<8 - 1, domAny: true, dowAny: true}},
+		{spec: "*/20 0-6/3 * * *", want: Schedule{minute: 1 | 1<<20 | 1<<40, hour: 1 | 1<<3 | 1<<6, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1<<8 - 1, domAny: true, dowAny: true}},
+		{spec: "50/5 * * * *", want: Schedule{minute: 1<<50 | 1<<55, hour: allHours, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1<<8 - 1, domAny: true, dowAny: true}},
+		{spec: "0 0 1,15 * *", want: Schedule{minute: 1, hour: 1, dom: 1<<1 | 1<<15, month: 1<<13 - 2, dow: 1<<8 - 1, dowAny: true}},
+		{spec: "0 0 * JAN-mar Mon-Fri", want: Schedule{minute: 1, hour: 1, dom: 1<<32 - 2, month: 1<<1 | 1<<2 | 1<<3, dow: 1<<1 | 1<<2 | 1<<3 | 1<<4 | 1<<5, domAny: true}},
+		{spec: "0 0 * * 7", want: Schedule{minute: 1, hour: 1, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1 | 1<<7, domAny: true}},
+		{spec: "@daily", want: Schedule{minute: 1, hour: 1, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1<<8 - 1, domAny: true, dowAny: true}},
+		{spec: " @HOURLY ", want: Schedule{minute: 1, hour: allHours, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1<<8 - 1, domAny: true, dowAny: true}},
+		{spec: "@weekly", want: Schedule{minute: 1, hour: 1, dom: 1<<32 - 2, month: 1<<13 - 2, dow: 1, domAny: true}},
+		{spec: "@every 90s", want: Schedule{every: 90 * time.Second}},
+		{spec: "@every 500ms", err: true},
+		{spec: "@every often", err: true},
+		{spec: "* * * *", err: true},
+		{spec: "* * * * * *", err: true},
+		{spec: "60 * * * *", err: true},
+		{spec: "* 24 * * *", err: true},
+		{spec: "* * 0 * *", err: true},
+		{spec: "* * * 13 *", err: true},
+		{spec: "* * * * 8", err: true},
+		{spec: "5-1 * * * *", err: true},
+		{spec: "*/0 * * * *", err: true},
+		{spec: "*/x * * * *", err: true},
+		{spec: "* * * foo *", err: true},
+		{spec: "@sometimes", err: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.spec)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Parse(%q) succeeded", tt.spec)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v, want %+v", tt.spec, got, err, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		spec, after, want string
+	}{
+		{"* * * * *", "2024-03-06 12:00:30", "2024-03-06 12:01:00"},
+		{"*/15 * * * *", "2024-03-06 12:00:00", "2024-03-06 12:15:00"},
+		{"0 9 * * *", "2024-03-06 09:00:00", "2024-03-07 09:00:00"},
+		{"0 9 * * *", "2024-12-31 10:00:00", "2025-01-01 09:00:00"},
+		{"30 8 * * mon-fri", "2024-03-08 09:00:00", "2024-03-11 08:30:00"},
+		{"0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"0 0 31 * *", "2024-04-01 00:00:00", "2024-05-31 00:00:00"},
+		// The day fields match either: the 13th or any Friday.
+		{"0 0 13 * fri", "2024-03-06 00:00:00", "2024-03-08 00:00:00"},
+		{"0 0 13 * fri", "2024-03-09 00:00:00", "2024-03-13 00:00:00"},
+		// A restricted day of month alone ignores the weekdays.
+		{"0 0 13 * *", "2024-03-06 00:00:00", "2024-03-13 00:00:00"},
+		{"0 0 * * sun", "2024-03-06 00:00:00", "2024-03-10 00:00:00"},
+		{"0 0 * * 7", "2024-03-06 00:00:00", "2024-03-10 00:00:00"},
+		{"@monthly", "2024-03-06 00:00:00", "2024-04-01 00:00:00"},
+		{"@yearly", "2024-03-06 00:00:00", "2025-01-01 00:00:00"},
+		{"@every 90m", "2024-03-06 12:00:30", "2024-03-06 13:30:30"},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(utc(tt.after)); !got.Equal(utc(tt.want)) {
+			t.Errorf("%q.Next(%s) = %s, want %s", tt.spec, tt.after, got, tt.want)
+		}
+	}
+
+	never, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := never.Next(utc("2024-01-01 00:00:00")); !got.IsZero() {
+		t.Errorf("Next() of February 30 = %s, want zero", got)
+	}
+}
+
+func TestNextAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	at := func(s string, offset int) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The wall clock with the UTC offset in hours, which tells the
+		// repeated times apart.
+		return tm.Add(-time.Duration(offset) * time.Hour).In(loc)
+	}
+
+	tests := []struct {
+		name, spec string
+		after      time.Time
+		want       time.Time
+	}{
+		// The clocks go forward from 2:00 to 3:00 on 2024-03-10.
+		{"skipped time", "30 2 * * *", at("2024-03-10 00:00", -5), at("2024-03-11 02:30", -4)},
+		{"after the gap", "30 3 * * *", at("2024-03-10 00:00", -5), at("2024-03-10 03:30", -4)},
+		{"hourly over the gap", "0 * * * *", at("2024-03-10 01:30", -5), at("2024-03-10 03:00", -4)},
+		// The clocks go back from 2:00 to 1:00 on 2024-11-03.
+		{"repeated time", "30 1 * * *", at("2024-11-03 00:00", -4), at("2024-11-03 01:30", -4)},
+		{"repeated time once", "30 1 * * *", at("2024-11-03 01:30", -4), at("2024-11-04 01:30", -5)},
+		{"repeated time once later", "30 1 * * *", at("2024-11-03 01:40", -4), at("2024-11-04 01:30", -5)},
+		{"hourly in the repeated hour", "0 * * * *", at("2024-11-03 01:30", -4), at("2024-11-03 01:00", -5)},
+		{"daily", "@daily", at("2024-11-02 12:00", -4), at("2024-11-03 00:00", -4)},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%s: %q.Next(%s) = %s, want %s", tt.name, tt.spec, tt.after, got, tt.want)
+		}
+	}
+}
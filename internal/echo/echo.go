@@ -0,0 +1,60 @@
+// Package echo implements the basic bot behaviour: greeting and echoing text back.
+package echo
+
+import (
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+)
+
+// Register adds the /start and /help commands and the echo handler to the router.
+// The echo handler catches every message, so it should be registered last.
+func Register(r *bot.Router) {
+	r.Command("start", start)
+	r.Command("help", help)
+	r.Handle(bot.IsText, enabled(echo))
+	r.Handle(bot.IsMessage, enabled(unsupported))
+}
+
+// enabled skips the handler in group chats whose admins turned echo off.
+func enabled(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		chat := c.Update.Message.Chat
+		if chat.IsPrivate() {
+			return next(c)
+		}
+
+		cfg, err := c.ChatSettings()
+		if err != nil || !cfg.Echo {
+			return err
+		}
+
+		return next(c)
+	}
+}
+
+func start(c *bot.Context) error {
+	return c.ReplyFormat(tgbotapi.ModeHTML, "echo.start", map[string]interface{}{
+		"User": c.From(),
+	})
+}
+
+func help(c *bot.Context) error {
+	return c.Reply(c.T("echo.help"))
+}
+
+func echo(c *bot.Context) error {
+	return c.Reply(c.Update.Message.Text)
+}
+
+func unsupported(c *bot.Context) error {
+	m := c.Update.Message
+	// Service messages (members joining, title changes, ...) have no sender
+	// content to echo and must not be answered.
+	if m.NewChatMembers != nil || m.LeftChatMember != nil || m.NewChatTitle != "" ||
+		m.PinnedMessage != nil || m.GroupChatCreated || m.SuperGroupChatCreated ||
+		m.MigrateFromChatID != 0 || m.MigrateToChatID != 0 {
+		return nil
+	}
+	return c.Reply(c.T("echo.unsupported"))
+}
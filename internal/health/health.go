@@ -0,0 +1,293 @@
+// Package health serves the liveness and readiness probes of the bot.
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// remoteTTL is how long the results of the checks calling the store and the
+// Bot API are reused, so frequent probes don't load them.
+const remoteTTL = 10 * time.Second
+
+// Monitor tracks whether the bot receives updates. The API and the store are
+// set once they are created, the bot is not ready before that.
+type Monitor struct {
+	API   *tgbotapi.BotAPI
+	Store storage.Store
+
+	// Timeout is how long ago the last update poll or webhook delivery may be.
+	Timeout time.Duration
+	// Webhook tells that updates come from the webhook instead of getUpdates.
+	Webhook bool
+
+	mu       sync.Mutex
+	started  time.Time
+	received time.Time
+	lastErr  string
+	checks   map[string]Check
+
+	// remoteMu serializes the remote checks, so concurrent probes wait for
+	// one of them instead of repeating it.
+	remoteMu sync.Mutex
+	remote   remote
+}
+
+// remote holds the results of the last remote checks.
+type remote struct {
+	at         time.Time
+	storageErr error
+	webhook    tgbotapi.WebhookInfo
+	webhookErr error
+}
+
+// NewMonitor creates a monitor. Until the timeout passes the bot is ready
+// without receiving updates, since the first long poll may take a minute.
+func NewMonitor(timeout time.Duration, webhook bool) *Monitor {
+	return &Monitor{Timeout: timeout, Webhook: webhook, started: time.Now()}
+}
+
+// Received records a successful update poll or webhook delivery.
+func (m *Monitor) Received() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.received = time.Now()
+	m.lastErr = ""
+}
+
+// Set records the result of a check made elsewhere, such as the state of the
+// update poller. It is reported until it is set again.
+func (m *Monitor) Set(name string, c Check) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.checks == nil {
+		m.checks = make(map[string]Check)
+	}
+	m.checks[name] = c
+}
+
+func (m *Monitor) failed(msg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastErr = msg
+}
+
+// Transport returns an http.RoundTripper recording the outcome of getUpdates
+// calls made through next.
+func (m *Monitor) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := next.RoundTrip(req)
+		if bot.APIMethod(req.URL.Path) != "getUpdates" {
+			return resp, err
+		}
+
+		switch {
+		case err != nil:
+			m.failed(err.Error())
+		case resp.StatusCode != http.StatusOK:
+			m.failed(resp.Status)
+		default:
+			m.Received()
+		}
+		return resp, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Check is the result of a single readiness check.
+type Check struct {
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// WebhookStatus is the webhook state reported by Telegram.
+type WebhookStatus struct {
+	URL                string     `json:"url"`
+	PendingUpdateCount int        `json:"pending_update_count"`
+	LastErrorDate      *time.Time `json:"last_error_date,omitempty"`
+	LastErrorMessage   string     `json:"last_error_message,omitempty"`
+}
+
+// Report is the readiness report served by /readyz.
+type Report struct {
+	Ready   bool             `json:"ready"`
+	Checks  map[string]Check `json:"checks"`
+	Webhook *WebhookStatus   `json:"webhook,omitempty"`
+}
+
+// Ready runs the readiness checks: GetMe succeeded at startup, updates were
+// received recently and the storage is reachable. The storage and, in webhook
+// mode, the webhook info are checked at most once per remoteTTL.
+func (m *Monitor) Ready() Report {
+	r := Report{Ready: true, Checks: make(map[string]Check)}
+	add := func(name string, c Check) {
+		r.Checks[name] = c
+		r.Ready = r.Ready && c.OK
+	}
+
+	if m.API == nil || m.API.Self.ID == 0 {
+		add("getme", Check{Detail: "not called yet"})
+	} else {
+		add("getme", Check{OK: true, Detail: "@" + m.API.Self.UserName})
+	}
+
+	rem := m.check(time.Now())
+	if m.Store == nil {
+		add("storage", Check{Detail: "not opened yet"})
+	} else if rem.storageErr != nil {
+		add("storage", Check{Detail: rem.storageErr.Error()})
+	} else {
+		add("storage", Check{OK: true})
+	}
+
+	add("updates", m.updates(&r, rem))
+
+	m.mu.Lock()
+	for name, c := range m.checks {
+		add(name, c)
+	}
+	m.mu.Unlock()
+
+	return r
+}
+
+// updates checks that updates were received within the timeout. In webhook
+// mode a bot without pending updates is ready too, it just has nothing to do,
+// and the webhook state reported by Telegram is added to the report.
+func (m *Monitor) updates(r *Report, rem remote) Check {
+	m.mu.Lock()
+	received, lastErr := m.received, m.lastErr
+	m.mu.Unlock()
+
+	now := time.Now()
+	recent := !received.IsZero() && now.Sub(received) <= m.Timeout
+	since := fmt.Sprintf("last received %s ago", now.Sub(received).Round(time.Second))
+
+	if m.Webhook {
+		if m.API == nil {
+			return Check{Detail: "not started yet"}
+		}
+		info := rem.webhook
+		if rem.webhookErr != nil {
+			return Check{Detail: rem.webhookErr.Error()}
+		}
+
+		r.Webhook = &WebhookStatus{
+			URL:                info.URL,
+			PendingUpdateCount: info.PendingUpdateCount,
+			LastErrorMessage:   info.LastErrorMessage,
+		}
+		if info.LastErrorDate != 0 {
+			t := time.Unix(int64(info.LastErrorDate), 0).UTC()
+			r.Webhook.LastErrorDate = &t
+		}
+
+		switch {
+		case !info.IsSet():
+			return Check{Detail: "webhook is not set"}
+		case recent:
+			return Check{OK: true, Detail: since}
+		case info.PendingUpdateCount > 0:
+			return Check{Detail: fmt.Sprintf("%d updates pending", info.PendingUpdateCount)}
+		}
+		return Check{OK: true, Detail: "no pending updates"}
+	}
+
+	switch {
+	case recent:
+		return Check{OK: true, Detail: since}
+	case lastErr != "":
+		return Check{Detail: lastErr}
+	case received.IsZero() && now.Sub(m.started) <= m.Timeout:
+		return Check{OK: true, Detail: "starting"}
+	case received.IsZero():
+		return Check{Detail: "no updates received"}
+	}
+	return Check{Detail: since}
+}
+
+// check returns the results of the remote checks, repeating them if they
+// are older than remoteTTL. The store is only read, not written.
+func (m *Monitor) check(now time.Time) remote {
+	m.remoteMu.Lock()
+	defer m.remoteMu.Unlock()
+
+	if !m.remote.at.IsZero() && now.Sub(m.remote.at) < remoteTTL {
+		return m.remote
+	}
+
+	var rem remote
+	if m.Store != nil {
+		rem.storageErr = storage.Ping(m.Store)
+	}
+	if m.Webhook && m.API != nil {
+		rem.webhook, rem.webhookErr = m.API.GetWebhookInfo()
+	}
+	// Checks made before the store and the API are set are not reused.
+	if m.Store != nil && (!m.Webhook || m.API != nil) {
+		rem.at = now
+	}
+	m.remote = rem
+
+	return rem
+}
+
+// Healthz answers 200 as long as the process serves requests.
+func Healthz(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
+
+// Readyz serves the readiness report with status 200 or 503 if a check failed.
+func (m *Monitor) Readyz(w http.ResponseWriter, _ *http.Request) {
+	r := m.Ready()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !r.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+}
+
+// Monitors are the monitors of the bots of a process by name.
+type Monitors map[string]*Monitor
+
+// Readyz serves the readiness reports of all bots with status 200, or 503 if
+// a check of any bot failed.
+func (ms Monitors) Readyz(w http.ResponseWriter, _ *http.Request) {
+	r := struct {
+		Ready bool              `json:"ready"`
+		Bots  map[string]Report `json:"bots"`
+	}{Ready: true, Bots: make(map[string]Report)}
+	for name, m := range ms {
+		report := m.Ready()
+		r.Bots[name] = report
+		r.Ready = r.Ready && report.Ready
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !r.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(r)
+}
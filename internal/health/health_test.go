@@ -0,0 +1,70 @@
+package health
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// countingStore counts the calls made to the store.
+type countingStore struct {
+	storage.Store
+	gets, puts int
+}
+
+func (s *countingStore) Get(bucket, key string, v interface{}) error {
+	s.gets++
+	return s.Store.Get(bucket, key, v)
+}
+
+func (s *countingStore) Put(bucket, key string, v interface{}) error {
+	s.puts++
+	return s.Store.Put(bucket, key, v)
+}
+
+func TestRemoteChecksCached(t *testing.T) {
+	calls := 0
+	api := &tgbotapi.BotAPI{Token: "token", Client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		body := `{"ok":true,"result":{"url":"https://bot.example.com/hook","pending_update_count":0}}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})}}
+	api.Self.ID = 1
+
+	store := &countingStore{Store: storage.NewMemory()}
+	m := NewMonitor(time.Minute, true)
+	m.API, m.Store = api, store
+
+	now := time.Now()
+	tests := []struct {
+		at    time.Duration
+		calls int
+	}{
+		{at: 0, calls: 1},
+		{at: time.Second, calls: 1},
+		{at: remoteTTL - time.Second, calls: 1},
+		{at: remoteTTL, calls: 2},
+	}
+	for _, tt := range tests {
+		rem := m.check(now.Add(tt.at))
+		if rem.storageErr != nil || rem.webhookErr != nil || !rem.webhook.IsSet() {
+			t.Fatalf("check at %s = %+v", tt.at, rem)
+		}
+		if calls != tt.calls || store.gets != tt.calls {
+			t.Errorf("after check at %s: %d getWebhookInfo calls and %d reads, want %d", tt.at, calls, store.gets, tt.calls)
+		}
+	}
+	if store.puts != 0 {
+		t.Errorf("probes wrote to the store %d times", store.puts)
+	}
+
+	if r := m.Ready(); !r.Ready {
+		t.Errorf("Ready() = %+v", r)
+	}
+}
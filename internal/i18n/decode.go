@@ -0,0 +1,164 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// decoders decode the catalogs of a language by the extension of their file.
+var decoders = map[string]func(data []byte, lang string) (catalog, error){
+	".json": decodeJSON,
+	".yaml": decodeYAML,
+	".yml":  decodeYAML,
+	".po":   decodePO,
+}
+
+var errMessage = errors.New("message must be a string or an object of plural forms")
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &m.text); err == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, &m.forms); err != nil {
+		return errMessage
+	}
+	return nil
+}
+
+func (m *message) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	if err := unmarshal(&m.text); err == nil {
+		return nil
+	}
+	if err := unmarshal(&m.forms); err != nil {
+		return errMessage
+	}
+	return nil
+}
+
+func decodeJSON(data []byte, _ string) (catalog, error) {
+	var c catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func decodeYAML(data []byte, _ string) (catalog, error) {
+	var c catalog
+	if err := yaml.UnmarshalStrict(data, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// decodePO decodes a gettext PO catalog. Comments, flags and the header entry
+// are skipped, contexts aren't supported. The msgstr[n] of plural messages
+// are the forms of the plural rule of the language.
+func decodePO(data []byte, lang string) (catalog, error) {
+	c := make(catalog)
+	forms := ruleFor(lang).forms
+
+	var (
+		id, plural string
+		strs       map[int]*string
+		// field is the string continued by the following quoted lines.
+		field *string
+	)
+	flush := func() error {
+		defer func() { id, plural, strs, field = "", "", nil, nil }()
+		if id == "" {
+			// The header entry, or nothing read yet.
+			return nil
+		}
+		if _, ok := c[id]; ok {
+			return fmt.Errorf("duplicate msgid %q", id)
+		}
+		if plural == "" {
+			text := ""
+			if strs[0] != nil {
+				text = *strs[0]
+			}
+			c[id] = &message{text: text}
+			return nil
+		}
+		m := &message{forms: make(map[string]string, len(strs))}
+		for n, text := range strs {
+			if n >= len(forms) {
+				return fmt.Errorf("msgid %q: msgstr[%d] beyond the %d plural forms of %s", id, n, len(forms), lang)
+			}
+			m.forms[forms[n]] = *text
+		}
+		c[id] = m
+		return nil
+	}
+
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(text, `"`) {
+			if field == nil {
+				return nil, fmt.Errorf("line %d: string without a keyword", line)
+			}
+			str, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", line, err)
+			}
+			*field += str
+			continue
+		}
+
+		i := strings.IndexByte(text, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected a keyword and a string", line)
+		}
+		keyword := text[:i]
+		str, err := strconv.Unquote(strings.TrimSpace(text[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", line, err)
+		}
+
+		switch {
+		case keyword == "msgid":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			id = str
+			field = &id
+		case keyword == "msgid_plural":
+			plural = str
+			field = &plural
+		case keyword == "msgstr" || strings.HasPrefix(keyword, "msgstr["):
+			n := 0
+			if keyword != "msgstr" {
+				if n, err = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(keyword, "msgstr["), "]")); err != nil || n < 0 {
+					return nil, fmt.Errorf("line %d: bad keyword %s", line, keyword)
+				}
+			}
+			if strs == nil {
+				strs = make(map[int]*string)
+			}
+			strs[n] = &str
+			field = &str
+		default:
+			return nil, fmt.Errorf("line %d: unsupported keyword %s", line, keyword)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,267 @@
+// Package i18n translates bot replies using per-language message catalogs.
+//
+// Every file <lang>.json, <lang>.yaml (or .yml) and <lang>.po in the catalog
+// directory holds one catalog. A catalog maps message keys either to a string
+// or, for messages depending on a number, to an object of CLDR plural forms:
+//
+//	{
+//	  "language.set": "Language switched to {language}.",
+//	  "stats.users": {"one": "{count} user", "other": "{count} users"}
+//	}
+//
+// YAML catalogs have the same structure. In gettext PO catalogs the msgid is
+// the key, and the msgstr[n] of a plural message are the forms of the plural
+// rule of the language in CLDR order, for example one, few and many in
+// Russian.
+//
+// Placeholders in braces are substituted with the arguments of Translate and Plural.
+package i18n
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// message is a single catalog entry.
+type message struct {
+	text  string
+	forms map[string]string
+}
+
+// other returns the form of a plural message used when the number is not
+// known or its form is missing: other, or else the last form of the rule of
+// the language, like many in Russian, which has no other.
+func (m *message) other(lang string) string {
+	if text, ok := m.forms[Other]; ok {
+		return text
+	}
+	forms := ruleFor(lang).forms
+	for i := len(forms) - 1; i >= 0; i-- {
+		if text, ok := m.forms[forms[i]]; ok {
+			return text
+		}
+	}
+	return ""
+}
+
+type catalog map[string]*message
+
+// Bundle holds the catalogs of all supported languages.
+type Bundle struct {
+	dir      string
+	fallback string
+
+	mu       sync.RWMutex
+	catalogs map[string]catalog
+}
+
+// Load reads all catalogs from dir. The fallback language catalog must be present
+// and is used for keys missing in other catalogs.
+//
+// Load fails if the catalogs are inconsistent: every catalog must have exactly
+// the keys of the fallback catalog, plural messages must define all forms
+// required by their language and translations must use the same placeholders
+// as the fallback messages.
+func Load(dir, fallback string) (*Bundle, error) {
+	b := &Bundle{dir: dir, fallback: normalize(fallback)}
+	if err := b.Reload(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Reload reads the catalogs from disk again. The current catalogs are kept if
+// the new ones cannot be loaded or are inconsistent.
+func (b *Bundle) Reload() error {
+	entries, err := ioutil.ReadDir(b.dir)
+	if err != nil {
+		return err
+	}
+
+	catalogs := make(map[string]catalog, len(entries))
+	files := make(map[string]string, len(entries))
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		decode, ok := decoders[ext]
+		if !ok || e.IsDir() {
+			continue
+		}
+		path := filepath.Join(b.dir, e.Name())
+		lang := normalize(strings.TrimSuffix(e.Name(), ext))
+		if other, ok := files[lang]; ok {
+			return fmt.Errorf("i18n: %s and %s are catalogs of the same language", other, path)
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		c, err := decode(data, lang)
+		if err != nil {
+			return fmt.Errorf("i18n: %s: %s", path, err)
+		}
+
+		catalogs[lang] = c
+		files[lang] = path
+	}
+
+	if err := validate(catalogs, b.fallback); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	b.catalogs = catalogs
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Fallback returns the language used when nothing better is known.
+func (b *Bundle) Fallback() string {
+	return b.fallback
+}
+
+// Languages returns the sorted list of languages having a catalog.
+func (b *Bundle) Languages() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	langs := make([]string, 0, len(b.catalogs))
+	for lang := range b.catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	return langs
+}
+
+// Match returns the first of the candidate language tags supported by the bundle.
+// Regional tags match their base language, e.g. "pt-BR" matches "pt".
+// If no candidate is supported, the fallback language is returned.
+func (b *Bundle) Match(candidates ...string) string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, tag := range candidates {
+		tag = normalize(tag)
+		if tag == "" {
+			continue
+		}
+		if _, ok := b.catalogs[tag]; ok {
+			return tag
+		}
+		if _, ok := b.catalogs[baseLanguage(tag)]; ok {
+			return baseLanguage(tag)
+		}
+	}
+
+	return b.fallback
+}
+
+// Supports reports whether there is a catalog for exactly the given language.
+func (b *Bundle) Supports(lang string) bool {
+	b.mu.RLock()
+	_, ok := b.catalogs[normalize(lang)]
+	b.mu.RUnlock()
+
+	return ok
+}
+
+// Translate returns the message for key in lang with placeholders substituted.
+// Arguments are given as name/value pairs. If no catalog in the fallback chain
+// has the key, the key itself is returned.
+func (b *Bundle) Translate(lang, key string, args ...interface{}) string {
+	m, found := b.lookup(lang, key)
+	if m == nil {
+		return key
+	}
+
+	text := m.text
+	if m.forms != nil {
+		text = m.other(found)
+	}
+
+	return format(text, args)
+}
+
+// Plural returns the plural form of the message for key matching n.
+// The number is available in the message as the {count} placeholder.
+func (b *Bundle) Plural(lang, key string, n int, args ...interface{}) string {
+	m, found := b.lookup(lang, key)
+	if m == nil {
+		return key
+	}
+
+	args = append([]interface{}{"count", n}, args...)
+	if m.forms == nil {
+		return format(m.text, args)
+	}
+
+	if n < 0 {
+		n = -n
+	}
+	text, ok := m.forms[ruleFor(found).choose(n)]
+	if !ok {
+		text = m.other(found)
+	}
+
+	return format(text, args)
+}
+
+// lookup finds key along the fallback chain of lang. It also returns the
+// language of the catalog the message was found in.
+func (b *Bundle) lookup(lang, key string) (*message, string) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, l := range b.chain(lang) {
+		if m, ok := b.catalogs[l][key]; ok {
+			return m, l
+		}
+	}
+
+	return nil, ""
+}
+
+// chain returns the fallback chain for lang: the language itself, its base
+// language and the bundle fallback language.
+func (b *Bundle) chain(lang string) []string {
+	lang = normalize(lang)
+	chain := make([]string, 0, 3)
+	if lang != "" {
+		chain = append(chain, lang)
+	}
+	if base := baseLanguage(lang); base != lang {
+		chain = append(chain, base)
+	}
+	return append(chain, b.fallback)
+}
+
+// format substitutes {name} placeholders with the values of name/value pairs.
+func format(text string, args []interface{}) string {
+	if len(args) < 2 || !strings.Contains(text, "{") {
+		return text
+	}
+
+	pairs := make([]string, 0, len(args))
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(text)
+}
+
+func normalize(tag string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+}
+
+func baseLanguage(tag string) string {
+	if i := strings.Index(tag, "-"); i != -1 {
+		return tag[:i]
+	}
+	return tag
+}
@@ -0,0 +1,228 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestCatalogs fails on keys missing from any of the catalogs of the bot, and
+// on any other inconsistency between them.
+func TestCatalogs(t *testing.T) {
+	b, err := Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if langs := b.Languages(); len(langs) < 2 {
+		t.Errorf("Languages() = %v, want the catalogs in locales", langs)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		en, ru   string
+		problems []string
+	}{
+		{
+			name: "consistent",
+			en:   `{"a": "A {x}", "n": {"one": "{count} item", "other": "{count} items"}}`,
+			ru:   `{"a": "А {x}", "n": {"one": "{count} штука", "few": "{count} штуки", "many": "{count} штук"}}`,
+		},
+		{
+			name:     "missing key",
+			en:       `{"a": "A", "b": "B"}`,
+			ru:       `{"a": "А"}`,
+			problems: []string{`ru: "b": missing`},
+		},
+		{
+			name:     "extra key",
+			en:       `{"a": "A"}`,
+			ru:       `{"a": "А", "b": "Б"}`,
+			problems: []string{`ru: "b": not present in en`},
+		},
+		{
+			name:     "missing plural form",
+			en:       `{"n": {"one": "{count} item", "other": "{count} items"}}`,
+			ru:       `{"n": {"one": "{count} штука", "many": "{count} штук"}}`,
+			problems: []string{`ru: "n": missing plural form "few"`},
+		},
+		{
+			name:     "plural in one catalog only",
+			en:       `{"n": {"one": "item", "other": "items"}}`,
+			ru:       `{"n": "штуки"}`,
+			problems: []string{`ru: "n": plural forms must be used in all catalogs or in none`},
+		},
+		{
+			name:     "placeholders differ",
+			en:       `{"a": "Hi {name}"}`,
+			ru:       `{"a": "Привет {user}"}`,
+			problems: []string{`ru: "a": placeholders [{user}] differ from [{name}] in en`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			catalogs := make(map[string]catalog)
+			for lang, data := range map[string]string{"en": tt.en, "ru": tt.ru} {
+				c, err := decodeJSON([]byte(data), lang)
+				if err != nil {
+					t.Fatal(err)
+				}
+				catalogs[lang] = c
+			}
+
+			err := validate(catalogs, "en")
+			var problems []string
+			if err != nil {
+				verr, ok := err.(*ValidationError)
+				if !ok {
+					t.Fatalf("validate() = %v, want a *ValidationError", err)
+				}
+				problems = verr.Problems
+			}
+			if !reflect.DeepEqual(problems, tt.problems) {
+				t.Errorf("problems = %q, want %q", problems, tt.problems)
+			}
+		})
+	}
+}
+
+func TestValidateNoFallback(t *testing.T) {
+	if err := validate(map[string]catalog{"ru": {}}, "en"); err == nil {
+		t.Error("validate() without the fallback catalog succeeded")
+	}
+}
+
+func TestDecode(t *testing.T) {
+	want := catalog{
+		"greeting": {text: "Hello, \"{name}\"!\nWelcome."},
+		"items":    {forms: map[string]string{One: "{count} штука", Few: "{count} штуки", Many: "{count} штук"}},
+	}
+
+	tests := []struct {
+		ext  string
+		data string
+	}{
+		{".json", `{
+  "greeting": "Hello, \"{name}\"!\nWelcome.",
+  "items": {"one": "{count} штука", "few": "{count} штуки", "many": "{count} штук"}
+}`},
+		{".yaml", `greeting: "Hello, \"{name}\"!\nWelcome."
+items:
+  one: "{count} штука"
+  few: "{count} штуки"
+  many: "{count} штук"
+`},
+		{".po", `# Russian catalog
+msgid ""
+msgstr ""
+"Language: ru\n"
+
+#, c-format
+msgid "greeting"
+msgstr "Hello, \"{name}\"!\n"
+"Welcome."
+
+msgid "items"
+msgid_plural "items"
+msgstr[0] "{count} штука"
+msgstr[1] "{count} штуки"
+msgstr[2] "{count} штук"
+`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ext, func(t *testing.T) {
+			got, err := decoders[tt.ext]([]byte(tt.data), "ru")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("decoded %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		ext  string
+		data string
+		want string
+	}{
+		{".json", `{"a": 1}`, "message must be"},
+		{".yaml", "a: [1, 2]\n", "message must be"},
+		{".po", "msgid \"a\"\nmsgstr \"A\"\nmsgid \"a\"\nmsgstr \"B\"\n", `duplicate msgid "a"`},
+		{".po", "msgid \"n\"\nmsgid_plural \"n\"\nmsgstr[0] \"1\"\nmsgstr[1] \"2\"\nmsgstr[2] \"3\"\n", "beyond the 2 plural forms"},
+		{".po", "msgctxt \"menu\"\nmsgid \"a\"\nmsgstr \"A\"\n", "unsupported keyword msgctxt"},
+		{".po", "\"orphan\"\n", "string without a keyword"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ext+" "+tt.want, func(t *testing.T) {
+			_, err := decoders[tt.ext]([]byte(tt.data), "en")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"en.json":  `{"items": {"one": "{count} item", "other": "{count} items"}}`,
+		"ru.po":    "msgid \"items\"\nmsgid_plural \"items\"\nmsgstr[0] \"{count} штука\"\nmsgstr[1] \"{count} штуки\"\nmsgstr[2] \"{count} штук\"\n",
+		"de.yml":   "items:\n  one: \"{count} Stück\"\n  other: \"{count} Stücke\"\n",
+		"notes.md": "not a catalog",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := Load(dir, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.Languages(), []string{"de", "en", "ru"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Languages() = %v, want %v", got, want)
+	}
+	for _, tt := range []struct {
+		lang string
+		n    int
+		want string
+	}{
+		{"en", 1, "1 item"},
+		{"ru", 3, "3 штуки"},
+		{"ru", 11, "11 штук"},
+		{"de", 2, "2 Stücke"},
+	} {
+		if got := b.Plural(tt.lang, "items", tt.n); got != tt.want {
+			t.Errorf("Plural(%s, %d) = %q, want %q", tt.lang, tt.n, got, tt.want)
+		}
+	}
+	// Without a number, Russian has no other form to use but many.
+	for lang, want := range map[string]string{"en": "5 items", "ru": "5 штук"} {
+		if got := b.Translate(lang, "items", "count", 5); got != want {
+			t.Errorf("Translate(%s) = %q, want %q", lang, got, want)
+		}
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "ru.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Reload(); err == nil || !strings.Contains(err.Error(), "same language") {
+		t.Errorf("Reload() with two ru catalogs = %v, want an error", err)
+	}
+}
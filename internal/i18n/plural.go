@@ -0,0 +1,119 @@
+package i18n
+
+// Plural forms as named by the CLDR plural rules.
+const (
+	Zero  = "zero"
+	One   = "one"
+	Two   = "two"
+	Few   = "few"
+	Many  = "many"
+	Other = "other"
+)
+
+// pluralRule selects the plural form for a non-negative integer.
+type pluralRule struct {
+	forms  []string
+	choose func(n int) string
+}
+
+var (
+	ruleOther = pluralRule{
+		forms:  []string{Other},
+		choose: func(int) string { return Other },
+	}
+
+	ruleOneOther = pluralRule{
+		forms: []string{One, Other},
+		choose: func(n int) string {
+			if n == 1 {
+				return One
+			}
+			return Other
+		},
+	}
+
+	// ruleZeroOneOther is used by languages treating 0 as singular (French, Portuguese).
+	ruleZeroOneOther = pluralRule{
+		forms: []string{One, Other},
+		choose: func(n int) string {
+			if n == 0 || n == 1 {
+				return One
+			}
+			return Other
+		},
+	}
+
+	ruleEastSlavic = pluralRule{
+		forms: []string{One, Few, Many},
+		choose: func(n int) string {
+			mod10, mod100 := n%10, n%100
+			switch {
+			case mod10 == 1 && mod100 != 11:
+				return One
+			case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+				return Few
+			default:
+				return Many
+			}
+		},
+	}
+
+	rulePolish = pluralRule{
+		forms: []string{One, Few, Many},
+		choose: func(n int) string {
+			mod10, mod100 := n%10, n%100
+			switch {
+			case n == 1:
+				return One
+			case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+				return Few
+			default:
+				return Many
+			}
+		},
+	}
+
+	ruleCzech = pluralRule{
+		forms: []string{One, Few, Other},
+		choose: func(n int) string {
+			switch {
+			case n == 1:
+				return One
+			case n >= 2 && n <= 4:
+				return Few
+			default:
+				return Other
+			}
+		},
+	}
+)
+
+// pluralRules maps base language codes to their plural rule.
+// Languages missing from the table use the English rule.
+var pluralRules = map[string]pluralRule{
+	"be": ruleEastSlavic,
+	"cs": ruleCzech,
+	"de": ruleOneOther,
+	"en": ruleOneOther,
+	"es": ruleOneOther,
+	"fr": ruleZeroOneOther,
+	"id": ruleOther,
+	"it": ruleOneOther,
+	"ja": ruleOther,
+	"ko": ruleOther,
+	"nl": ruleOneOther,
+	"pl": rulePolish,
+	"pt": ruleZeroOneOther,
+	"ru": ruleEastSlavic,
+	"sk": ruleCzech,
+	"tr": ruleOneOther,
+	"uk": ruleEastSlavic,
+	"zh": ruleOther,
+}
+
+func ruleFor(lang string) pluralRule {
+	if r, ok := pluralRules[baseLanguage(lang)]; ok {
+		return r
+	}
+	return ruleOneOther
+}
@@ -0,0 +1,111 @@
+package i18n
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ValidationError lists all inconsistencies found between catalogs.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "i18n: inconsistent catalogs:\n\t" + strings.Join(e.Problems, "\n\t")
+}
+
+// validate checks the catalogs against the one of the fallback language, see
+// Load. Inconsistencies are returned as a *ValidationError.
+func validate(catalogs map[string]catalog, fallback string) error {
+	base, ok := catalogs[fallback]
+	if !ok {
+		return fmt.Errorf("i18n: no catalog for fallback language %q", fallback)
+	}
+
+	var problems []string
+	report := func(lang, key, format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("%s: %q: ", lang, key)+fmt.Sprintf(format, args...))
+	}
+
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+
+	for _, lang := range langs {
+		c := catalogs[lang]
+
+		for _, key := range sortedKeys(base) {
+			ref := base[key]
+			m, ok := c[key]
+			if !ok {
+				report(lang, key, "missing")
+				continue
+			}
+
+			if (ref.forms == nil) != (m.forms == nil) {
+				report(lang, key, "plural forms must be used in all catalogs or in none")
+				continue
+			}
+			if m.forms != nil {
+				for _, form := range ruleFor(lang).forms {
+					if _, ok := m.forms[form]; !ok {
+						report(lang, key, "missing plural form %q", form)
+					}
+				}
+			}
+
+			if want, got := placeholders(ref), placeholders(m); want != got {
+				report(lang, key, "placeholders [%s] differ from [%s] in %s", got, want, fallback)
+			}
+		}
+
+		for _, key := range sortedKeys(c) {
+			if _, ok := base[key]; !ok {
+				report(lang, key, "not present in %s", fallback)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+var placeholderRe = regexp.MustCompile(`\{[a-z_]+\}`)
+
+// placeholders returns the sorted distinct placeholders of all message texts.
+func placeholders(m *message) string {
+	texts := []string{m.text}
+	for _, text := range m.forms {
+		texts = append(texts, text)
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, text := range texts {
+		for _, p := range placeholderRe.FindAllString(text, -1) {
+			if !seen[p] {
+				seen[p] = true
+				names = append(names, p)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, " ")
+}
+
+func sortedKeys(c catalog) []string {
+	keys := make([]string, 0, len(c))
+	for key := range c {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,49 @@
+// Package language implements the /language command which lets users override
+// the language detected from their Telegram client.
+package language
+
+import (
+	"strings"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+)
+
+// auto resets the language to the one of the Telegram client.
+const auto = "auto"
+
+// Register adds the /language command to the router.
+func Register(r *bot.Router) {
+	r.Command("language", language)
+}
+
+func language(c *bot.Context) error {
+	u := c.From()
+	if u == nil {
+		return nil
+	}
+
+	langs := strings.Join(c.Bot.I18n.Languages(), ", ")
+
+	arg := strings.ToLower(strings.TrimSpace(c.Update.Message.CommandArguments()))
+	switch {
+	case arg == "":
+		return c.Reply(c.T("language.current", "language", c.Lang(), "languages", langs))
+
+	case arg == auto:
+		if err := c.Bot.SetUserLanguage(u.ID, ""); err != nil {
+			return err
+		}
+		c.SetLang(c.Bot.I18n.Match(u.LanguageCode))
+		return c.Reply(c.T("language.reset", "language", c.Lang()))
+
+	case !c.Bot.I18n.Supports(arg):
+		return c.Reply(c.T("language.unsupported", "language", arg, "languages", langs))
+	}
+
+	if err := c.Bot.SetUserLanguage(u.ID, arg); err != nil {
+		return err
+	}
+	c.SetLang(arg)
+
+	return c.Reply(c.T("language.set", "language", arg))
+}
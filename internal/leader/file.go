@@ -0,0 +1,126 @@
+package leader
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// File is a Lock on a file, for instances running on the same host. The file
+// keeps the last fencing token. The lock is released by the operating system
+// when the process exits, so a crashed leader is replaced right away.
+//
+// The tokens start over from 1 when the file is deleted, so it must be kept
+// as long as anything compares the tokens, for example out of directories
+// cleaned on reboot. A holder of a deleted file loses the lock, see Held.
+type File struct {
+	path string
+
+	mu sync.Mutex
+	f  *os.File
+}
+
+// NewFile creates a lock on the file at path, which is created if missing.
+func NewFile(path string) *File {
+	return &File{path: path}
+}
+
+// TryLock implements Lock.
+func (l *File) TryLock() (int64, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return 0, false, err
+	}
+
+	f, ok, err := lockFile(l.path)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+
+	token, err := readToken(f)
+	if err == nil {
+		token++
+		err = writeToken(f, token)
+	}
+	if err != nil {
+		f.Close()
+		return 0, false, err
+	}
+
+	l.f = f
+	return token, true, nil
+}
+
+// Held implements Lock. The lock is lost if the file was replaced or another
+// holder wrote a newer token, for example after the file was deleted.
+func (l *File) Held(token int64) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return false, nil
+	}
+
+	ours, err := l.f.Stat()
+	if err != nil {
+		return false, err
+	}
+	current, err := os.Stat(l.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !os.SameFile(ours, current) {
+		return false, nil
+	}
+
+	got, err := readToken(l.f)
+	if err != nil {
+		return false, err
+	}
+	return got == token, nil
+}
+
+// Unlock implements Lock.
+func (l *File) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+func readToken(f *os.File) (int64, error) {
+	buf := make([]byte, 32)
+	n, err := f.ReadAt(buf, 0)
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+
+	s := strings.TrimSpace(string(buf[:n]))
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+func writeToken(f *os.File, token int64) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte(strconv.FormatInt(token, 10)+"\n"), 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
@@ -0,0 +1,79 @@
+package leader
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func tempLock(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "leader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "lock"), func() { os.RemoveAll(dir) }
+}
+
+func TestFileTryLock(t *testing.T) {
+	path, cleanup := tempLock(t)
+	defer cleanup()
+
+	a, b := NewFile(path), NewFile(path)
+	first, ok, err := a.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("a.TryLock() = %d, %v, %v", first, ok, err)
+	}
+	if _, ok, err := b.TryLock(); err != nil || ok {
+		t.Fatalf("b.TryLock() while a holds the lock = %v, %v, want false", ok, err)
+	}
+	if held, err := a.Held(first); err != nil || !held {
+		t.Errorf("a.Held() = %v, %v, want true", held, err)
+	}
+
+	if err := a.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	second, ok, err := b.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("b.TryLock() after a unlocked = %d, %v, %v", second, ok, err)
+	}
+	defer b.Unlock()
+	if second <= first {
+		t.Errorf("token of b = %d, want greater than %d", second, first)
+	}
+	if held, err := a.Held(first); err != nil || held {
+		t.Errorf("a.Held() after unlocking = %v, %v, want false", held, err)
+	}
+}
+
+func TestFileHeldAfterReplace(t *testing.T) {
+	path, cleanup := tempLock(t)
+	defer cleanup()
+
+	l := NewFile(path)
+	token, ok, err := l.TryLock()
+	if err != nil || !ok {
+		t.Fatalf("TryLock() = %d, %v, %v", token, ok, err)
+	}
+	defer l.Unlock()
+
+	// Another file takes the place of the locked one.
+	replacement := path + ".new"
+	if err := ioutil.WriteFile(replacement, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(replacement, path); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := l.Held(token); err != nil || held {
+		t.Errorf("Held() after the file was replaced = %v, %v, want false", held, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := l.Held(token); err != nil || held {
+		t.Errorf("Held() after the file was deleted = %v, %v, want false", held, err)
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows
+// +build !windows
+
+package leader
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile opens the file and takes an exclusive flock on it without waiting.
+func lockFile(path string) (*os.File, bool, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+
+	return f, true, nil
+}
@@ -0,0 +1,32 @@
+//go:build windows
+// +build windows
+
+package leader
+
+import (
+	"os"
+	"syscall"
+)
+
+// errorSharingViolation is ERROR_SHARING_VIOLATION.
+const errorSharingViolation syscall.Errno = 32
+
+// lockFile opens the file without sharing it, so no other process can open it
+// until it is closed.
+func lockFile(path string) (*os.File, bool, error) {
+	name, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	h, err := syscall.CreateFile(name, syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+		0, nil, syscall.OPEN_ALWAYS, syscall.FILE_ATTRIBUTE_NORMAL, 0)
+	if err == errorSharingViolation {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return os.NewFile(uintptr(h), path), true, nil
+}
@@ -0,0 +1,165 @@
+// Package leader elects a single instance of the bot to poll for updates and
+// send messages, while the other instances stand by to take over.
+package leader
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+)
+
+// ErrLost is returned by Hold when another instance took over the lock.
+var ErrLost = errors.New("leader: leadership lost")
+
+// ErrNotLeader is returned for API calls an instance makes while it isn't
+// the leader.
+var ErrNotLeader = errors.New("leader: not the leader, the request was not sent")
+
+// Lock is a lock backend shared by the instances, such as a lock file or a
+// database advisory lock.
+type Lock interface {
+	// TryLock takes the lock if it is free. The returned fencing token is
+	// greater than the tokens of all previous holders.
+	TryLock() (token int64, ok bool, err error)
+	// Held reports whether the lock taken with the token is still held.
+	Held(token int64) (bool, error)
+	// Unlock releases the lock.
+	Unlock() error
+}
+
+// Elector campaigns for the lock and watches it while leading.
+type Elector struct {
+	Lock Lock
+	// Interval is how often the lock is tried while standing by and checked
+	// while leading.
+	Interval time.Duration
+	// OnChange, if not nil, is called when the instance becomes the leader or
+	// stops leading.
+	OnChange func(leading bool, token int64)
+
+	mu      sync.Mutex
+	leading bool
+	token   int64
+}
+
+// New creates an elector checking the lock every 5 seconds.
+func New(lock Lock) *Elector {
+	return &Elector{Lock: lock, Interval: 5 * time.Second}
+}
+
+// Leading reports whether the instance is the leader.
+func (e *Elector) Leading() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.leading
+}
+
+// Token returns the fencing token of the current leadership, 0 if the
+// instance isn't the leader.
+func (e *Elector) Token() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.leading {
+		return 0
+	}
+	return e.token
+}
+
+func (e *Elector) set(leading bool, token int64) {
+	e.mu.Lock()
+	e.leading, e.token = leading, token
+	e.mu.Unlock()
+
+	if e.OnChange != nil {
+		e.OnChange(leading, token)
+	}
+}
+
+// Campaign waits until the instance becomes the leader or the context is done.
+func (e *Elector) Campaign(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	for {
+		token, ok, err := e.Lock.TryLock()
+		switch {
+		case err != nil:
+			log.Printf("Failed to take the leader lock: %s", err)
+		case ok:
+			e.set(true, token)
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Hold checks the lock until the context is done. It returns ErrLost as soon
+// as the lock is not held anymore, after which the fence rejects all requests.
+// The lock stays held after the context is done until Resign, so the work
+// finishing on shutdown may still send messages.
+func (e *Elector) Hold(ctx context.Context) error {
+	ticker := time.NewTicker(e.Interval)
+	defer ticker.Stop()
+
+	token := e.Token()
+	for {
+		select {
+		case <-ticker.C:
+			held, err := e.Lock.Held(token)
+			if err != nil {
+				log.Printf("Failed to check the leader lock: %s", err)
+				continue
+			}
+			if !held {
+				e.set(false, 0)
+				return ErrLost
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// Resign releases the lock, so a standing by instance takes over.
+func (e *Elector) Resign() error {
+	if !e.Leading() {
+		return nil
+	}
+	e.set(false, 0)
+	return e.Lock.Unlock()
+}
+
+// Fence returns an http.RoundTripper failing the Bot API requests with
+// ErrNotLeader while the instance isn't the leader, so a leader that lost the
+// lock stops sending before it shuts down. getMe and getWebhookInfo are let
+// through for the start and the health checks.
+func (e *Elector) Fence(next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		switch bot.APIMethod(req.URL.Path) {
+		case "getMe", "getWebhookInfo":
+		default:
+			if !e.Leading() {
+				return nil, ErrNotLeader
+			}
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,74 @@
+package leader
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeLock is a Lock held until lost is set.
+type fakeLock struct {
+	mu   sync.Mutex
+	lost bool
+}
+
+func (l *fakeLock) TryLock() (int64, bool, error) { return 1, true, nil }
+
+func (l *fakeLock) Held(token int64) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return !l.lost, nil
+}
+
+func (l *fakeLock) Unlock() error { return nil }
+
+func TestFenceAfterLoss(t *testing.T) {
+	lock := &fakeLock{}
+	e := New(lock)
+	e.Interval = time.Millisecond
+
+	var sent []string
+	fence := e.Fence(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, req.URL.Path)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(`{"ok":true}`))}, nil
+	}))
+	call := func(method string) error {
+		req, err := http.NewRequest(http.MethodPost, "https://api.telegram.org/bottoken/"+method, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = fence.RoundTrip(req)
+		return err
+	}
+
+	if err := call("sendMessage"); err != ErrNotLeader {
+		t.Errorf("sendMessage before the campaign: error = %v, want ErrNotLeader", err)
+	}
+	if err := e.Campaign(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := call("sendMessage"); err != nil {
+		t.Errorf("sendMessage while leading: %v", err)
+	}
+
+	lock.mu.Lock()
+	lock.lost = true
+	lock.mu.Unlock()
+	if err := e.Hold(context.Background()); err != ErrLost {
+		t.Fatalf("Hold() = %v, want ErrLost", err)
+	}
+
+	if err := call("sendMessage"); err != ErrNotLeader {
+		t.Errorf("sendMessage after the loss: error = %v, want ErrNotLeader", err)
+	}
+	if err := call("getMe"); err != nil {
+		t.Errorf("getMe after the loss: %v", err)
+	}
+	if len(sent) != 2 {
+		t.Errorf("sent %v, want sendMessage while leading and getMe", sent)
+	}
+}
@@ -0,0 +1,73 @@
+// Package media re-sends media files by the file IDs Telegram assigned to
+// them, without downloading and uploading them again.
+package media
+
+import "github.com/go-telegram-bot-api/telegram-bot-api"
+
+// Media types.
+const (
+	Photo     = "photo"
+	Video     = "video"
+	Animation = "animation"
+	Document  = "document"
+	Audio     = "audio"
+	Voice     = "voice"
+	Sticker   = "sticker"
+)
+
+// Of returns the type and the file ID of the media of the message, or empty
+// strings if it has none.
+func Of(m *tgbotapi.Message) (typ, fileID string) {
+	switch {
+	case m.Photo != nil && len(*m.Photo) > 0:
+		photos := *m.Photo
+		// The last size is the largest one.
+		return Photo, photos[len(photos)-1].FileID
+	case m.Video != nil:
+		return Video, m.Video.FileID
+	case m.Animation != nil:
+		return Animation, m.Animation.FileID
+	case m.Document != nil:
+		return Document, m.Document.FileID
+	case m.Audio != nil:
+		return Audio, m.Audio.FileID
+	case m.Voice != nil:
+		return Voice, m.Voice.FileID
+	case m.Sticker != nil:
+		return Sticker, m.Sticker.FileID
+	}
+	return "", ""
+}
+
+// Share returns the request sending the media with the file ID to the chat.
+// Unknown types are sent as documents. Stickers have no caption.
+func Share(chatID int64, typ, fileID, caption, parseMode string) tgbotapi.Chattable {
+	switch typ {
+	case Photo:
+		cfg := tgbotapi.NewPhotoShare(chatID, fileID)
+		cfg.Caption, cfg.ParseMode = caption, parseMode
+		return cfg
+	case Video:
+		cfg := tgbotapi.NewVideoShare(chatID, fileID)
+		cfg.Caption, cfg.ParseMode = caption, parseMode
+		return cfg
+	case Animation:
+		cfg := tgbotapi.NewAnimationShare(chatID, fileID)
+		cfg.Caption, cfg.ParseMode = caption, parseMode
+		return cfg
+	case Audio:
+		cfg := tgbotapi.NewAudioShare(chatID, fileID)
+		cfg.Caption, cfg.ParseMode = caption, parseMode
+		return cfg
+	case Voice:
+		cfg := tgbotapi.NewVoiceShare(chatID, fileID)
+		cfg.Caption, cfg.ParseMode = caption, parseMode
+		return cfg
+	case Sticker:
+		return tgbotapi.NewStickerShare(chatID, fileID)
+	}
+
+	cfg := tgbotapi.NewDocumentShare(chatID, fileID)
+	cfg.Caption, cfg.ParseMode = caption, parseMode
+	return cfg
+}
@@ -0,0 +1,59 @@
+package media
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestOf(t *testing.T) {
+	tests := []struct {
+		m      tgbotapi.Message
+		typ    string
+		fileID string
+	}{
+		{m: tgbotapi.Message{Text: "hi"}},
+		{m: tgbotapi.Message{Photo: &[]tgbotapi.PhotoSize{}}},
+		{m: tgbotapi.Message{Photo: &[]tgbotapi.PhotoSize{{FileID: "s"}, {FileID: "m"}, {FileID: "l"}}}, typ: Photo, fileID: "l"},
+		{m: tgbotapi.Message{Video: &tgbotapi.Video{FileID: "v"}}, typ: Video, fileID: "v"},
+		// Animations come with a document for older clients.
+		{m: tgbotapi.Message{Animation: &tgbotapi.ChatAnimation{FileID: "a"}, Document: &tgbotapi.Document{FileID: "a"}}, typ: Animation, fileID: "a"},
+		{m: tgbotapi.Message{Document: &tgbotapi.Document{FileID: "d"}}, typ: Document, fileID: "d"},
+		{m: tgbotapi.Message{Audio: &tgbotapi.Audio{FileID: "au"}}, typ: Audio, fileID: "au"},
+		{m: tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "vo"}}, typ: Voice, fileID: "vo"},
+		{m: tgbotapi.Message{Sticker: &tgbotapi.Sticker{FileID: "st"}}, typ: Sticker, fileID: "st"},
+	}
+	for _, tt := range tests {
+		typ, fileID := Of(&tt.m)
+		if typ != tt.typ || fileID != tt.fileID {
+			t.Errorf("Of(%+v) = %q, %q; want %q, %q", tt.m, typ, fileID, tt.typ, tt.fileID)
+		}
+	}
+}
+
+func TestShare(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want tgbotapi.Chattable
+	}{
+		{typ: Photo, want: tgbotapi.PhotoConfig{BaseFile: base("f"), Caption: "c", ParseMode: "HTML"}},
+		{typ: Video, want: tgbotapi.VideoConfig{BaseFile: base("f"), Caption: "c", ParseMode: "HTML"}},
+		{typ: Animation, want: tgbotapi.AnimationConfig{BaseFile: base("f"), Caption: "c", ParseMode: "HTML"}},
+		{typ: Document, want: tgbotapi.DocumentConfig{BaseFile: base("f"), Caption: "c", ParseMode: "HTML"}},
+		{typ: Audio, want: tgbotapi.AudioConfig{BaseFile: base("f"), Caption: "c", ParseMode: "HTML"}},
+		{typ: Voice, want: tgbotapi.VoiceConfig{BaseFile: base("f"), Caption: "c", ParseMode: "HTML"}},
+		{typ: Sticker, want: tgbotapi.StickerConfig{BaseFile: base("f")}},
+		{typ: "video_note", want: tgbotapi.DocumentConfig{BaseFile: base("f"), Caption: "c", ParseMode: "HTML"}},
+	}
+	for _, tt := range tests {
+		got := Share(-1, tt.typ, "f", "c", "HTML")
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Share(%q) = %+v, want %+v", tt.typ, got, tt.want)
+		}
+	}
+}
+
+func base(fileID string) tgbotapi.BaseFile {
+	return tgbotapi.NewPhotoShare(-1, fileID).BaseFile
+}
@@ -0,0 +1,385 @@
+// Package metrics implements counters, gauges and histograms exposed in the
+// Prometheus text format.
+//
+// Metrics are registered in the Default registry when they are created,
+// usually as package variables, and served by Handler.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are the default histogram buckets, in seconds.
+var DefBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Default is the registry the metrics are created in.
+var Default = NewRegistry()
+
+// metric is a family of samples with the same name.
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds the metrics in the order they were registered.
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// register adds the metric. Registering a name twice is a programming error.
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, other := range r.metrics {
+		if other.name() == m.name() {
+			panic("metrics: duplicate metric " + m.name())
+		}
+	}
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics of the registry.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Handler serves the metrics of the Default registry.
+func Handler() http.Handler {
+	return Default.Handler()
+}
+
+// desc is the description shared by the metric types.
+type desc struct {
+	metricName string
+	help       string
+	kind       string
+	labels     []string
+}
+
+func (d *desc) name() string {
+	return d.metricName
+}
+
+func (d *desc) header(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.metricName, escapeHelp(d.help), d.metricName, d.kind)
+}
+
+// key joins the label values into a map key.
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s has %d labels, got %d values", d.metricName, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs formats the labels with the values of the key and the extra
+// pair. Labels with empty values are left out, Prometheus treats them as
+// missing anyway.
+func (d *desc) labelPairs(key string, extra ...string) string {
+	var pairs []string
+	if len(d.labels) > 0 {
+		for i, v := range strings.Split(key, "\xff") {
+			if v != "" {
+				pairs = append(pairs, d.labels[i]+`="`+escapeLabel(v)+`"`)
+			}
+		}
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+escapeLabel(extra[i+1])+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct {
+	desc
+
+	mu     sync.Mutex
+	values map[string]*Counter
+}
+
+// Counter is a value that only goes up.
+type Counter struct {
+	mu sync.Mutex
+	v  float64
+}
+
+// NewCounterVec creates a counter with the labels.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{
+		desc:   desc{metricName: name, help: help, kind: "counter", labels: labels},
+		values: make(map[string]*Counter),
+	}
+	Default.register(v)
+	return v
+}
+
+// NewCounter creates a counter without labels.
+func NewCounter(name, help string) *Counter {
+	return NewCounterVec(name, help).With()
+}
+
+// With returns the counter with the label values, creating it if needed.
+func (v *CounterVec) With(values ...string) *Counter {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	c, ok := v.values[key]
+	if !ok {
+		c = &Counter{}
+		v.values[key] = c
+	}
+	return c
+}
+
+// Inc adds one to the counter.
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds a non-negative value to the counter.
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("metrics: counters cannot decrease")
+	}
+	c.mu.Lock()
+	c.v += v
+	c.mu.Unlock()
+}
+
+func (c *Counter) value() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.v
+}
+
+func (v *CounterVec) write(w io.Writer) {
+	v.header(w)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, key := range sortedKeys(v.values) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(key), formatFloat(v.values[key].value()))
+	}
+}
+
+// GaugeFunc is a gauge whose value is read from a function when the metrics are collected.
+type GaugeFunc struct {
+	desc
+	fn func() float64
+}
+
+// NewGaugeFunc creates a gauge reading its value from fn.
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{metricName: name, help: help, kind: "gauge"}, fn: fn}
+	Default.register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	g.header(w)
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatFloat(g.fn()))
+}
+
+// GaugeFuncVec is a gauge partitioned by labels, reading the value of every
+// label set from its own function.
+type GaugeFuncVec struct {
+	desc
+
+	mu  sync.Mutex
+	fns map[string]func() float64
+}
+
+// NewGaugeFuncVec creates a gauge with the labels. Add the functions with Set.
+func NewGaugeFuncVec(name, help string, labels ...string) *GaugeFuncVec {
+	v := &GaugeFuncVec{
+		desc: desc{metricName: name, help: help, kind: "gauge", labels: labels},
+		fns:  make(map[string]func() float64),
+	}
+	Default.register(v)
+	return v
+}
+
+// Set makes fn read the value of the gauge with the label values.
+func (v *GaugeFuncVec) Set(fn func() float64, values ...string) {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.fns[key] = fn
+}
+
+func (v *GaugeFuncVec) write(w io.Writer) {
+	v.header(w)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, key := range sortedKeys(v.fns) {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, v.labelPairs(key), formatFloat(v.fns[key]()))
+	}
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	values map[string]*Histogram
+}
+
+// Histogram counts observations in buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+// NewHistogramVec creates a histogram with the upper bounds of the buckets and the labels.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	v := &HistogramVec{
+		desc:    desc{metricName: name, help: help, kind: "histogram", labels: labels},
+		buckets: buckets,
+		values:  make(map[string]*Histogram),
+	}
+	Default.register(v)
+	return v
+}
+
+// With returns the histogram with the label values, creating it if needed.
+func (v *HistogramVec) With(values ...string) *Histogram {
+	key := v.key(values)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	h, ok := v.values[key]
+	if !ok {
+		h = &Histogram{buckets: v.buckets, counts: make([]uint64, len(v.buckets))}
+		v.values[key] = h
+	}
+	return h
+}
+
+// Observe adds the value to the histogram.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (v *HistogramVec) write(w io.Writer) {
+	v.header(w)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, key := range sortedKeys(v.values) {
+		h := v.values[key]
+		h.mu.Lock()
+
+		// Prometheus buckets are cumulative.
+		var cumulative uint64
+		for i, upper := range h.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(key, "le", formatFloat(upper)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", v.metricName, v.labelPairs(key, "le", "+Inf"), h.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.metricName, v.labelPairs(key), formatFloat(h.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.metricName, v.labelPairs(key), h.count)
+
+		h.mu.Unlock()
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]*Counter:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]*Histogram:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[string]func() float64:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func output(m metric) string {
+	var buf bytes.Buffer
+	m.write(&buf)
+	return buf.String()
+}
+
+func TestCounterVec(t *testing.T) {
+	v := NewCounterVec("test_requests_total", "Requests.\nBy \\ method.", "method", "code")
+	v.With("get", "200").Inc()
+	v.With("get", "200").Add(2)
+	v.With(`say "hi"`+"\n\\", "").Inc()
+
+	want := `# HELP test_requests_total Requests.\nBy \\ method.
+# TYPE test_requests_total counter
+test_requests_total{method="get",code="200"} 3
+test_requests_total{method="say \"hi\"\n\\"} 1
+`
+	if got := output(v); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGaugeFunc(t *testing.T) {
+	g := NewGaugeFunc("test_temperature", "Temperature.", func() float64 { return math.Inf(-1) })
+	v := NewGaugeFuncVec("test_queue_length", "Queue length.", "queue")
+	v.Set(func() float64 { return 0.5 }, "b")
+	v.Set(func() float64 { return 1e21 }, "a")
+
+	want := "# HELP test_temperature Temperature.\n# TYPE test_temperature gauge\ntest_temperature -Inf\n"
+	if got := output(g); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+	want = `# HELP test_queue_length Queue length.
+# TYPE test_queue_length gauge
+test_queue_length{queue="a"} 1e+21
+test_queue_length{queue="b"} 0.5
+`
+	if got := output(v); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestHistogramVec(t *testing.T) {
+	v := NewHistogramVec("test_duration_seconds", "Duration.", []float64{1, 0.1, 0.5}, "handler")
+	h := v.With("start")
+	for _, d := range []float64{0.05, 0.1, 0.3, 0.7, 2, 5} {
+		h.Observe(d)
+	}
+
+	// The buckets are sorted and cumulative, +Inf counts every observation.
+	want := `# HELP test_duration_seconds Duration.
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{handler="start",le="0.1"} 2
+test_duration_seconds_bucket{handler="start",le="0.5"} 3
+test_duration_seconds_bucket{handler="start",le="1"} 4
+test_duration_seconds_bucket{handler="start",le="+Inf"} 6
+test_duration_seconds_sum{handler="start"} 8.15
+test_duration_seconds_count{handler="start"} 6
+`
+	if got := output(v); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.register(&GaugeFunc{desc: desc{metricName: "test_b", kind: "gauge"}, fn: func() float64 { return 2 }})
+	r.register(&GaugeFunc{desc: desc{metricName: "test_a", kind: "gauge"}, fn: func() float64 { return 1 }})
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	want := "# HELP test_b \n# TYPE test_b gauge\ntest_b 2\n# HELP test_a \n# TYPE test_a gauge\ntest_a 1\n"
+	if got := buf.String(); got != want {
+		t.Errorf("output:\n%s\nwant:\n%s", got, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering test_a twice doesn't panic")
+		}
+	}()
+	r.register(&GaugeFunc{desc: desc{metricName: "test_a", kind: "gauge"}})
+}
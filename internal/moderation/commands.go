@@ -0,0 +1,272 @@
+package moderation
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+)
+
+// untilLayout formats the end of timed restrictions.
+const untilLayout = "2006-01-02 15:04 MST"
+
+// Telegram treats restrictions shorter than 30 seconds or longer than 366 days
+// as permanent, so durations are clamped to these limits. The shortest one
+// leaves a margin for the time the request takes to be handled.
+const (
+	minRestriction = time.Minute
+	maxRestriction = 366 * 24 * time.Hour
+)
+
+// target is the template data of replies about a moderated user.
+type target struct {
+	User    *tgbotapi.User
+	Count   int
+	Limit   int
+	Reason  string
+	Reasons []string
+	Until   string
+}
+
+func (m *Moderator) warn(c *bot.Context, u *tgbotapi.User) error {
+	return m.Warn(c, u, strings.TrimSpace(c.Update.Message.CommandArguments()))
+}
+
+// Warn warns the user in the chat of the update message and takes the
+// automatic action of the policy if the user has collected enough warnings.
+func (m *Moderator) Warn(c *bot.Context, u *tgbotapi.User, reason string) error {
+	msg := c.Update.Message
+
+	w, err := m.warnings(msg.Chat.ID, u.ID)
+	if err != nil {
+		return err
+	}
+	w = append(w, Warning{Time: time.Now(), By: msg.From.ID, Reason: reason})
+
+	policy := m.Policy(c)
+	limit := policy.Limit()
+	count := len(w)
+	if limit > 0 && count >= limit {
+		// The last action is taken now, the user starts over afterwards.
+		w = nil
+	}
+	if err := m.setWarnings(msg.Chat.ID, u.ID, w); err != nil {
+		return err
+	}
+
+	data := target{User: u, Count: count, Limit: limit, Reason: reason}
+	if err := c.ReplyFormat(tgbotapi.ModeHTML, "moderation.warn.done", data); err != nil {
+		return err
+	}
+
+	a, ok := policy.At(count)
+	if !ok {
+		return nil
+	}
+
+	switch a.Kind {
+	case ActionMute:
+		return m.Mute(c, u, a.Duration)
+	case ActionKick:
+		return m.Kick(c, u)
+	}
+	return m.Ban(c, u, a.Duration)
+}
+
+func (m *Moderator) unwarn(c *bot.Context, u *tgbotapi.User) error {
+	if err := m.setWarnings(c.Update.Message.Chat.ID, u.ID, nil); err != nil {
+		return err
+	}
+	return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.unwarn.done", target{User: u})
+}
+
+func (m *Moderator) warns(c *bot.Context, u *tgbotapi.User) error {
+	w, err := m.warnings(c.Update.Message.Chat.ID, u.ID)
+	if err != nil {
+		return err
+	}
+
+	data := target{User: u, Count: len(w), Limit: m.Policy(c).Limit()}
+	for _, warning := range w {
+		line := warning.Time.UTC().Format(untilLayout)
+		if warning.Reason != "" {
+			line += " - " + warning.Reason
+		}
+		data.Reasons = append(data.Reasons, line)
+	}
+
+	return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.warns", data)
+}
+
+// mute handles /mute [duration]. Without a duration the user is muted forever.
+func (m *Moderator) mute(c *bot.Context, u *tgbotapi.User) error {
+	d, ok := durationArg(c)
+	if !ok {
+		return c.Reply(c.T("moderation.duration_invalid"))
+	}
+	return m.Mute(c, u, d)
+}
+
+func (m *Moderator) unmute(c *bot.Context, u *tgbotapi.User) error {
+	allow := true
+	_, err := m.bot.API.RestrictChatMember(tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig:      member(c, u),
+		CanSendMessages:       &allow,
+		CanSendMediaMessages:  &allow,
+		CanSendOtherMessages:  &allow,
+		CanAddWebPagePreviews: &allow,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.unmute.done", target{User: u})
+}
+
+// ban handles /ban [duration]. Without a duration the user is banned forever.
+func (m *Moderator) ban(c *bot.Context, u *tgbotapi.User) error {
+	d, ok := durationArg(c)
+	if !ok {
+		return c.Reply(c.T("moderation.duration_invalid"))
+	}
+	return m.Ban(c, u, d)
+}
+
+// unban handles /unban <user id> or a reply to a message of the user.
+func (m *Moderator) unban(c *bot.Context) error {
+	msg := c.Update.Message
+
+	var u *tgbotapi.User
+	if arg := strings.TrimSpace(msg.CommandArguments()); arg != "" {
+		id, err := strconv.Atoi(arg)
+		if err != nil {
+			return c.Reply(c.T("moderation.unban.usage"))
+		}
+		u = &tgbotapi.User{ID: id, FirstName: arg}
+	} else if msg.ReplyToMessage != nil && msg.ReplyToMessage.From != nil {
+		u = msg.ReplyToMessage.From
+	} else {
+		return c.Reply(c.T("moderation.unban.usage"))
+	}
+
+	// Unbanning a member removes them from the chat, so only banned users are unbanned.
+	cm, err := m.bot.API.GetChatMember(tgbotapi.ChatConfigWithUser{ChatID: msg.Chat.ID, UserID: u.ID})
+	if err != nil {
+		return err
+	}
+	if !cm.WasKicked() {
+		return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.unban.not_banned", target{User: u})
+	}
+
+	if _, err := m.bot.API.UnbanChatMember(member(c, u)); err != nil {
+		return err
+	}
+
+	return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.unban.done", target{User: u})
+}
+
+// del deletes the replied message together with the command.
+func (m *Moderator) del(c *bot.Context) error {
+	msg := c.Update.Message
+	if msg.ReplyToMessage == nil {
+		return c.Reply(c.T("moderation.no_target"))
+	}
+
+	for _, id := range []int{msg.ReplyToMessage.MessageID, msg.MessageID} {
+		if _, err := m.bot.API.DeleteMessage(tgbotapi.DeleteMessageConfig{ChatID: msg.Chat.ID, MessageID: id}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Mute mutes the user in the chat of the update message for d, or forever if d is zero.
+func (m *Moderator) Mute(c *bot.Context, u *tgbotapi.User, d time.Duration) error {
+	deny := false
+	until := untilDate(d, time.Now())
+	_, err := m.bot.API.RestrictChatMember(tgbotapi.RestrictChatMemberConfig{
+		ChatMemberConfig:      member(c, u),
+		UntilDate:             until,
+		CanSendMessages:       &deny,
+		CanSendMediaMessages:  &deny,
+		CanSendOtherMessages:  &deny,
+		CanAddWebPagePreviews: &deny,
+	})
+	if err != nil {
+		return err
+	}
+
+	if until == 0 {
+		return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.mute.forever", target{User: u})
+	}
+	return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.mute.until", target{User: u, Until: formatUntil(until)})
+}
+
+// Kick removes the user from the chat of the update message. Unlike a ban,
+// the user may join again.
+func (m *Moderator) Kick(c *bot.Context, u *tgbotapi.User) error {
+	if _, err := m.bot.API.KickChatMember(tgbotapi.KickChatMemberConfig{ChatMemberConfig: member(c, u)}); err != nil {
+		return err
+	}
+	if _, err := m.bot.API.UnbanChatMember(member(c, u)); err != nil {
+		return err
+	}
+
+	return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.kick.done", target{User: u})
+}
+
+// Ban bans the user from the chat of the update message for d, or forever if d is zero.
+func (m *Moderator) Ban(c *bot.Context, u *tgbotapi.User, d time.Duration) error {
+	until := untilDate(d, time.Now())
+	_, err := m.bot.API.KickChatMember(tgbotapi.KickChatMemberConfig{
+		ChatMemberConfig: member(c, u),
+		UntilDate:        until,
+	})
+	if err != nil {
+		return err
+	}
+
+	if until == 0 {
+		return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.ban.forever", target{User: u})
+	}
+	return c.ReplyFormat(tgbotapi.ModeHTML, "moderation.ban.until", target{User: u, Until: formatUntil(until)})
+}
+
+func member(c *bot.Context, u *tgbotapi.User) tgbotapi.ChatMemberConfig {
+	return tgbotapi.ChatMemberConfig{ChatID: c.Update.Message.Chat.ID, UserID: u.ID}
+}
+
+// durationArg parses the optional duration argument of a command.
+func durationArg(c *bot.Context) (time.Duration, bool) {
+	fields := strings.Fields(c.Update.Message.CommandArguments())
+	if len(fields) == 0 {
+		return 0, true
+	}
+
+	d, err := ParseDuration(fields[0])
+	return d, err == nil
+}
+
+// untilDate returns the Unix time a restriction for d from now ends at, or 0
+// for a permanent one if d is zero. Other durations Telegram would treat as
+// permanent are clamped to the shortest or longest restriction, so that a
+// typo like /ban 5s doesn't ban forever.
+func untilDate(d time.Duration, now time.Time) int64 {
+	switch {
+	case d <= 0:
+		return 0
+	case d < minRestriction:
+		d = minRestriction
+	case d > maxRestriction:
+		d = maxRestriction
+	}
+	return now.Add(d).Unix()
+}
+
+func formatUntil(until int64) string {
+	return time.Unix(until, 0).UTC().Format(untilLayout)
+}
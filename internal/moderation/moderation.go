@@ -0,0 +1,212 @@
+// Package moderation implements reply commands for group admins: warnings,
+// mutes, kicks and bans.
+package moderation
+
+import (
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// warningsBucket stores the warnings of users per chat.
+const warningsBucket = "warnings"
+
+// adminsTTL is how long the list of chat administrators is cached.
+const adminsTTL = 5 * time.Minute
+
+// Warning is issued to a user by a chat admin.
+type Warning struct {
+	Time   time.Time `json:"time"`
+	By     int       `json:"by"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Moderator implements the moderation commands.
+type Moderator struct {
+	bot    *bot.Bot
+	policy Policy
+
+	mu     sync.Mutex
+	admins map[int64]adminList
+}
+
+// adminList is the cached result of GetChatAdministrators.
+type adminList struct {
+	members map[int]tgbotapi.ChatMember
+	fetched time.Time
+}
+
+// New creates the moderation commands. The policy defines the actions taken
+// automatically when users collect warnings in chats without their own policy.
+func New(b *bot.Bot, policy Policy) *Moderator {
+	return &Moderator{
+		bot:    b,
+		policy: policy,
+		admins: make(map[int64]adminList),
+	}
+}
+
+// Register adds the moderation commands to the router.
+func (m *Moderator) Register(r *bot.Router) {
+	r.Command("warn", m.only(canRestrict, m.withTarget(m.warn)))
+	r.Command("unwarn", m.only(canRestrict, m.withTarget(m.unwarn)))
+	r.Command("warns", m.only(canRestrict, m.withTarget(m.warns)))
+	r.Command("mute", m.only(canRestrict, m.withTarget(m.mute)))
+	r.Command("unmute", m.only(canRestrict, m.withTarget(m.unmute)))
+	r.Command("kick", m.only(canRestrict, m.withTarget(m.Kick)))
+	r.Command("ban", m.only(canRestrict, m.withTarget(m.ban)))
+	r.Command("unban", m.only(canRestrict, m.unban))
+	r.Command("del", m.only(canDelete, m.del))
+}
+
+// Policy returns the automatic actions of the chat of the update. Chat
+// admins may override the policy of the bot in the chat settings.
+func (m *Moderator) Policy(c *bot.Context) Policy {
+	// A load failure is logged by ChatSettings.
+	chat, err := c.ChatSettings()
+	if err != nil || chat.WarnActions == "" {
+		return m.policy
+	}
+
+	p, err := ParsePolicy(chat.WarnActions)
+	if err != nil {
+		log.Printf("Invalid warn actions of chat %d: %s", c.Chat().ID, err)
+		return m.policy
+	}
+	return p
+}
+
+// OnlyAdmins restricts a command to the group admins allowed to restrict members.
+func (m *Moderator) OnlyAdmins(next bot.HandlerFunc) bot.HandlerFunc {
+	return m.only(canRestrict, next)
+}
+
+// IsAdmin reports whether the user is an administrator of the chat.
+func (m *Moderator) IsAdmin(chatID int64, userID int) (bool, error) {
+	_, ok, err := m.admin(chatID, userID)
+	return ok, err
+}
+
+// CanRestrict reports whether the user is an administrator of the chat
+// allowed to restrict members, as OnlyAdmins requires.
+func (m *Moderator) CanRestrict(chatID int64, userID int) (bool, error) {
+	member, ok, err := m.admin(chatID, userID)
+	return ok && (member.IsCreator() || canRestrict(member)), err
+}
+
+func canRestrict(cm tgbotapi.ChatMember) bool { return cm.CanRestrictMembers }
+
+func canDelete(cm tgbotapi.ChatMember) bool { return cm.CanDeleteMessages }
+
+// only restricts a command to the group admins having the permission checked
+// by can. The group creator has all permissions.
+func (m *Moderator) only(can func(tgbotapi.ChatMember) bool, next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		msg := c.Update.Message
+		if msg == nil || msg.From == nil {
+			return nil
+		}
+		if !msg.Chat.IsGroup() && !msg.Chat.IsSuperGroup() {
+			return c.Reply(c.T("moderation.group_only"))
+		}
+
+		member, ok, err := m.admin(msg.Chat.ID, msg.From.ID)
+		if err != nil {
+			return err
+		}
+		if !ok || !member.IsCreator() && !can(member) {
+			return c.Reply(c.T("moderation.forbidden"))
+		}
+
+		// The bot needs the same permission to carry out the command.
+		self, err := m.bot.API.GetChatMember(tgbotapi.ChatConfigWithUser{
+			ChatID: msg.Chat.ID,
+			UserID: m.bot.API.Self.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if !self.IsAdministrator() || !can(self) {
+			return c.Reply(c.T("moderation.bot_rights"))
+		}
+
+		return next(c)
+	}
+}
+
+// withTarget passes the author of the replied message to the handler.
+// Admins and the bot itself cannot be targeted.
+func (m *Moderator) withTarget(next func(c *bot.Context, u *tgbotapi.User) error) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		reply := c.Update.Message.ReplyToMessage
+		if reply == nil || reply.From == nil {
+			return c.Reply(c.T("moderation.no_target"))
+		}
+
+		u := reply.From
+		if u.ID == m.bot.API.Self.ID {
+			return c.Reply(c.T("moderation.target_self"))
+		}
+		if _, ok, err := m.admin(c.Update.Message.Chat.ID, u.ID); err != nil {
+			return err
+		} else if ok {
+			return c.Reply(c.T("moderation.target_admin"))
+		}
+
+		return next(c, u)
+	}
+}
+
+// admin returns the chat member if the user is an administrator of the chat.
+func (m *Moderator) admin(chatID int64, userID int) (tgbotapi.ChatMember, bool, error) {
+	m.mu.Lock()
+	list, ok := m.admins[chatID]
+	m.mu.Unlock()
+
+	if !ok || time.Since(list.fetched) > adminsTTL {
+		members, err := m.bot.API.GetChatAdministrators(tgbotapi.ChatConfig{ChatID: chatID})
+		if err != nil {
+			return tgbotapi.ChatMember{}, false, err
+		}
+
+		list = adminList{members: make(map[int]tgbotapi.ChatMember, len(members)), fetched: time.Now()}
+		for _, cm := range members {
+			list.members[cm.User.ID] = cm
+		}
+
+		m.mu.Lock()
+		m.admins[chatID] = list
+		m.mu.Unlock()
+	}
+
+	cm, ok := list.members[userID]
+	return cm, ok, nil
+}
+
+// warnings returns the warnings of the user in the chat.
+func (m *Moderator) warnings(chatID int64, userID int) ([]Warning, error) {
+	var w []Warning
+	err := m.bot.Store.Get(warningsBucket, warningsKey(chatID, userID), &w)
+	if err == storage.ErrNotFound {
+		return nil, nil
+	}
+	return w, err
+}
+
+// setWarnings stores the warnings of the user in the chat. No warnings removes the entry.
+func (m *Moderator) setWarnings(chatID int64, userID int, w []Warning) error {
+	if len(w) == 0 {
+		return m.bot.Store.Delete(warningsBucket, warningsKey(chatID, userID))
+	}
+	return m.bot.Store.Put(warningsBucket, warningsKey(chatID, userID), w)
+}
+
+func warningsKey(chatID int64, userID int) string {
+	return strconv.FormatInt(chatID, 10) + "/" + strconv.Itoa(userID)
+}
@@ -0,0 +1,60 @@
+package moderation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{in: "10s", want: 10 * time.Second},
+		{in: "90m", want: 90 * time.Minute},
+		{in: "2d", want: 48 * time.Hour},
+		{in: "1w", want: 7 * 24 * time.Hour},
+		{in: "0d", err: true},
+		{in: "-5m", err: true},
+		{in: "0s", err: true},
+		{in: "d", err: true},
+		{in: "soon", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %s, %v; want %s, error %t", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestUntilDate(t *testing.T) {
+	now := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		d    time.Duration
+		want int64
+	}{
+		{d: 0, want: 0},
+		{d: time.Second, want: now.Add(time.Minute).Unix()},
+		{d: 10 * time.Second, want: now.Add(time.Minute).Unix()},
+		{d: 30 * time.Second, want: now.Add(time.Minute).Unix()},
+		{d: time.Minute, want: now.Add(time.Minute).Unix()},
+		{d: 90 * time.Second, want: now.Add(90 * time.Second).Unix()},
+		{d: time.Hour, want: now.Add(time.Hour).Unix()},
+		{d: maxRestriction, want: now.Add(maxRestriction).Unix()},
+		{d: 400 * 24 * time.Hour, want: now.Add(maxRestriction).Unix()},
+	}
+	for _, tt := range tests {
+		if got := untilDate(tt.d, now); got != tt.want {
+			t.Errorf("untilDate(%s) = %d, want %d", tt.d, got, tt.want)
+		}
+	}
+
+	// Even a request handled a few seconds later, at a time truncated to
+	// seconds, must not be a permanent restriction.
+	late := time.Date(2020, 1, 1, 12, 0, 0, 999999999, time.UTC)
+	if left := time.Unix(untilDate(time.Second, late), 0).Sub(late.Add(5 * time.Second)); left <= 30*time.Second {
+		t.Errorf("a short restriction leaves %s when handled 5s later", left)
+	}
+}
@@ -0,0 +1,123 @@
+package moderation
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of moderation actions.
+const (
+	ActionMute = "mute"
+	ActionKick = "kick"
+	ActionBan  = "ban"
+)
+
+// Action is taken automatically when a user collects the given number of warnings.
+type Action struct {
+	Warnings int
+	Kind     string
+	// Duration limits mutes and bans. Zero means forever.
+	Duration time.Duration
+}
+
+// Policy lists the automatic actions ordered by the number of warnings.
+type Policy []Action
+
+// ParsePolicy parses a comma separated list of actions in the form
+// warnings:kind[:duration], e.g. "3:mute:1d,5:ban".
+func ParsePolicy(s string) (Policy, error) {
+	var p Policy
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("moderation: invalid action %q", part)
+		}
+
+		var a Action
+		var err error
+		if a.Warnings, err = strconv.Atoi(fields[0]); err != nil || a.Warnings < 1 {
+			return nil, fmt.Errorf("moderation: invalid number of warnings in %q", part)
+		}
+
+		a.Kind = fields[1]
+		switch a.Kind {
+		case ActionMute, ActionBan:
+		case ActionKick:
+			if len(fields) == 3 {
+				return nil, fmt.Errorf("moderation: kick does not take a duration in %q", part)
+			}
+		default:
+			return nil, fmt.Errorf("moderation: unknown action %q", a.Kind)
+		}
+
+		if len(fields) == 3 {
+			if a.Duration, err = ParseDuration(fields[2]); err != nil {
+				return nil, fmt.Errorf("moderation: %s in %q", err, part)
+			}
+		}
+
+		p = append(p, a)
+	}
+
+	sort.Slice(p, func(i, j int) bool { return p[i].Warnings < p[j].Warnings })
+	for i := 1; i < len(p); i++ {
+		if p[i].Warnings == p[i-1].Warnings {
+			return nil, fmt.Errorf("moderation: several actions for %d warnings", p[i].Warnings)
+		}
+	}
+
+	return p, nil
+}
+
+// At returns the action for exactly n warnings.
+func (p Policy) At(n int) (Action, bool) {
+	for _, a := range p {
+		if a.Warnings == n {
+			return a, true
+		}
+	}
+	return Action{}, false
+}
+
+// Limit returns the number of warnings triggering the last action. Warnings
+// are reset when it is reached. Zero means warnings are never reset.
+func (p Policy) Limit() int {
+	if len(p) == 0 {
+		return 0
+	}
+	return p[len(p)-1].Warnings
+}
+
+// ParseDuration parses a duration like time.ParseDuration and additionally
+// accepts days and weeks, e.g. "2d" or "1w".
+func ParseDuration(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+
+	if unit != 0 {
+		n, err := strconv.Atoi(s[:len(s)-1])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * unit, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,98 @@
+package module
+
+import (
+	"strings"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+)
+
+// Register adds the /modules command, guarded by the only middleware, to the
+// router.
+func (h *Host) Register(r *bot.Router, only bot.Middleware) {
+	r.Command("modules", only(h.command))
+}
+
+// command handles /modules, listing the modules and their state in the chat,
+// and /modules on|off <name>, switching a module on or off in the chat.
+func (h *Host) command(c *bot.Context) error {
+	args := strings.Fields(strings.ToLower(c.Update.Message.CommandArguments()))
+	switch {
+	case len(args) == 0:
+		return h.list(c)
+	case len(args) == 2 && (args[0] == "on" || args[0] == "off"):
+		return h.toggle(c, args[1], args[0] == "on")
+	}
+	return c.Reply(c.T("modules.usage"))
+}
+
+func (h *Host) list(c *bot.Context) error {
+	cfg, err := c.Bot.Settings.Get(c.Update.Message.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	lines := []string{c.T("modules.header")}
+	for _, m := range h.modules {
+		name := m.Name()
+		var line string
+		switch blocker := off(cfg.Modules, name, h.requires[name]); {
+		case isGlobal(m):
+			line = c.T("modules.global", "name", name)
+		case blocker == name:
+			line = c.T("modules.off", "name", name)
+		case blocker != "":
+			line = c.T("modules.blocked", "name", name, "module", blocker)
+		default:
+			line = c.T("modules.on", "name", name)
+		}
+		if reqs := m.Requires(); len(reqs) > 0 {
+			line += " " + c.T("modules.requires", "modules", strings.Join(reqs, ", "))
+		}
+		lines = append(lines, line)
+	}
+
+	return c.Reply(strings.Join(lines, "\n"))
+}
+
+func (h *Host) toggle(c *bot.Context, name string, on bool) error {
+	m := h.byName[name]
+	switch {
+	case m == nil:
+		return c.Reply(c.T("modules.unknown", "name", name))
+	case isGlobal(m):
+		return c.Reply(c.T("modules.not_switchable", "name", name))
+	}
+
+	chatID := c.Update.Message.Chat.ID
+	state := "off"
+	if on {
+		state = "on"
+	}
+	var blocker string
+	err := c.Bot.Settings.Update(chatID, c.From().ID, "module "+name+"="+state, func(cfg *settings.Chat) error {
+		// Modules are on unless switched off, so only the switched off ones
+		// are stored.
+		if on {
+			delete(cfg.Modules, name)
+		} else {
+			if cfg.Modules == nil {
+				cfg.Modules = make(map[string]bool)
+			}
+			cfg.Modules[name] = false
+		}
+		blocker = off(cfg.Modules, name, h.requires[name])
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case !on:
+		return c.Reply(c.T("modules.switched_off", "name", name))
+	case blocker != "":
+		return c.Reply(c.T("modules.switched_on_blocked", "name", name, "module", blocker))
+	}
+	return c.Reply(c.T("modules.switched_on", "name", name))
+}
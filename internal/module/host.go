@@ -0,0 +1,252 @@
+package module
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// Host runs the modules of a bot through their lifecycle: migrations, Init
+// and Register in dependency order, Run, and Shutdown in the reverse order.
+type Host struct {
+	// Logf logs the applied migrations and the failures of Shutdown,
+	// log.Printf if nil.
+	Logf func(format string, args ...interface{})
+
+	bot *bot.Bot
+	// modules are the enabled modules, every one after those it requires.
+	modules []Module
+	byName  map[string]Module
+	// requires holds the modules every module requires, directly or not.
+	requires map[string][]string
+	// initialized are the modules whose Init succeeded, shut down in reverse.
+	initialized []Module
+}
+
+// New creates the host of the modules of the bot chosen by spec out of the
+// available ones. An empty spec enables all modules, names prefixed with -
+// disable modules, otherwise the listed modules are enabled.
+//
+// The modules are initialized in the order they are available in, except
+// that every module comes after the modules it requires. Since the routes
+// are matched in the order they are added, catch-all handlers go last.
+func New(b *bot.Bot, available []Module, spec []string) (*Host, error) {
+	h := &Host{bot: b, byName: make(map[string]Module), requires: make(map[string][]string)}
+
+	all := make(map[string]Module)
+	for _, m := range available {
+		all[m.Name()] = m
+	}
+	enabled, err := choose(available, all, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	// A depth-first walk adds every module after the modules it requires.
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(m Module, path []string) error
+	visit = func(m Module, path []string) error {
+		name := m.Name()
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("module: %s require each other", strings.Join(append(path, name), " -> "))
+		case done:
+			return nil
+		}
+		state[name] = visiting
+
+		seen := make(map[string]bool)
+		for _, req := range m.Requires() {
+			dep, ok := all[req]
+			switch {
+			case !ok:
+				return fmt.Errorf("module: %s requires the unknown module %s", name, req)
+			case !enabled[req]:
+				return fmt.Errorf("module: %s requires %s, which is not enabled", name, req)
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+			for _, r := range append([]string{req}, h.requires[req]...) {
+				if !seen[r] {
+					seen[r] = true
+					h.requires[name] = append(h.requires[name], r)
+				}
+			}
+		}
+
+		state[name] = done
+		h.modules = append(h.modules, m)
+		h.byName[name] = m
+		return nil
+	}
+	for _, m := range available {
+		if enabled[m.Name()] {
+			if err := visit(m, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return h, nil
+}
+
+// choose returns the names of the modules enabled by the spec.
+func choose(available []Module, all map[string]Module, spec []string) (map[string]bool, error) {
+	enabled := make(map[string]bool)
+	exclude := len(spec) > 0 && strings.HasPrefix(spec[0], "-")
+	if len(spec) == 0 || exclude {
+		for _, m := range available {
+			enabled[m.Name()] = true
+		}
+	}
+
+	for _, s := range spec {
+		name := strings.TrimPrefix(s, "-")
+		if strings.HasPrefix(s, "-") != exclude {
+			return nil, fmt.Errorf("module: MODULES either lists the modules to enable or, prefixed with -, the ones to disable, not both")
+		}
+		if _, ok := all[name]; !ok {
+			return nil, fmt.Errorf("module: unknown module %q in MODULES", name)
+		}
+		enabled[name] = !exclude
+	}
+	return enabled, nil
+}
+
+// Modules returns the enabled modules in the order they are initialized.
+func (h *Host) Modules() []Module {
+	return h.modules
+}
+
+// Module returns the enabled module of the name, nil if it is not enabled.
+func (h *Host) Module(name string) Module {
+	return h.byName[name]
+}
+
+// Migrate applies the pending migrations of every module to the store of the bot.
+func (h *Host) Migrate(s storage.Store) error {
+	for _, m := range h.modules {
+		applied, err := storage.Migrate(s, m.Name(), m.Migrations())
+		for _, mig := range applied {
+			h.logf("Applied storage migration %d of module %s: %s", mig.Version, m.Name(), mig.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Init initializes the modules and registers them with the router of the
+// bot. The routes of the modules that aren't global are active only in the
+// chats the module is on in.
+func (h *Host) Init(cfg config.Config, jobs *Jobs) error {
+	for _, m := range h.modules {
+		name := m.Name()
+
+		d := &Deps{Bot: h.bot, Config: cfg, modules: make(map[string]Module)}
+		for _, req := range m.Requires() {
+			d.modules[req] = h.byName[req]
+		}
+		if err := m.Init(d); err != nil {
+			return fmt.Errorf("module %s: %s", name, err)
+		}
+		h.initialized = append(h.initialized, m)
+
+		r := h.bot.Router
+		if !isGlobal(m) {
+			r = r.Group(func(c *bot.Context) bool {
+				return h.Enabled(c, name)
+			})
+		}
+		if err := m.Register(r, jobs); err != nil {
+			return fmt.Errorf("module %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// Run runs the background work of the modules until the context is done and
+// all of it returned.
+func (h *Host) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, m := range h.initialized {
+		if r, ok := m.(Runner); ok {
+			wg.Add(1)
+			go func(r Runner) {
+				defer wg.Done()
+				r.Run(ctx)
+			}(r)
+		}
+	}
+	wg.Wait()
+}
+
+// Shutdown shuts the initialized modules down in the reverse order and
+// returns the first error.
+func (h *Host) Shutdown() error {
+	var first error
+	for i := len(h.initialized) - 1; i >= 0; i-- {
+		m := h.initialized[i]
+		if err := m.Shutdown(); err != nil {
+			h.logf("Failed to shut module %s down: %s", m.Name(), err)
+			if first == nil {
+				first = fmt.Errorf("module %s: %s", m.Name(), err)
+			}
+		}
+	}
+	h.initialized = nil
+	return first
+}
+
+// Enabled reports whether the module is on in the chat of the update: it is
+// global, or neither it nor a module it requires was switched off there.
+// Updates without a chat, like inline queries, have every module on. If the
+// settings of the chat can't be loaded, which bot.Context logs, the modules
+// that can be switched off are off.
+func (h *Host) Enabled(c *bot.Context, name string) bool {
+	if c.Chat() == nil || isGlobal(h.byName[name]) {
+		return true
+	}
+
+	cfg, err := c.ChatSettings()
+	if err != nil {
+		return false
+	}
+	return off(cfg.Modules, name, h.requires[name]) == ""
+}
+
+// off returns the first of the module and the modules it requires switched
+// off in the chat with the modules settings, "" if none is.
+func off(modules map[string]bool, name string, requires []string) string {
+	for _, n := range append([]string{name}, requires...) {
+		if on, ok := modules[n]; ok && !on {
+			return n
+		}
+	}
+	return ""
+}
+
+func (h *Host) logf(format string, args ...interface{}) {
+	if h.Logf != nil {
+		h.Logf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func isGlobal(m Module) bool {
+	g, ok := m.(Global)
+	return ok && g.Global()
+}
@@ -0,0 +1,142 @@
+package module
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+type testModule struct {
+	Base
+	name     string
+	requires []string
+	global   bool
+}
+
+func (m *testModule) Name() string                      { return m.name }
+func (m *testModule) Requires() []string                { return m.requires }
+func (m *testModule) Init(*Deps) error                  { return nil }
+func (m *testModule) Register(*bot.Router, *Jobs) error { return nil }
+func (m *testModule) Global() bool                      { return m.global }
+
+func modules(specs ...string) []Module {
+	var list []Module
+	for _, s := range specs {
+		parts := strings.Split(s, ":")
+		m := &testModule{name: parts[0]}
+		if len(parts) > 1 {
+			m.requires = strings.Split(parts[1], ",")
+		}
+		list = append(list, m)
+	}
+	return list
+}
+
+func names(list []Module) []string {
+	var n []string
+	for _, m := range list {
+		n = append(n, m.Name())
+	}
+	return n
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		available []string
+		spec      []string
+		want      []string
+		err       string
+	}{
+		{available: []string{"a", "b", "c"}, want: []string{"a", "b", "c"}},
+		{available: []string{"todo:mod,remind", "mod", "remind"}, want: []string{"mod", "remind", "todo"}},
+		{available: []string{"a:b", "b:c", "c"}, want: []string{"c", "b", "a"}},
+		{available: []string{"a", "b", "c"}, spec: []string{"c", "a"}, want: []string{"a", "c"}},
+		{available: []string{"a", "b", "c"}, spec: []string{"-b"}, want: []string{"a", "c"}},
+		{available: []string{"a:b", "b"}, spec: []string{"-b"}, err: "a requires b, which is not enabled"},
+		{available: []string{"a:x"}, err: "a requires the unknown module x"},
+		{available: []string{"a:b", "b:c", "c:a"}, err: "a -> b -> c -> a require each other"},
+		{available: []string{"a", "b"}, spec: []string{"a", "-b"}, err: "not both"},
+		{available: []string{"a"}, spec: []string{"z"}, err: `unknown module "z"`},
+	}
+	for _, tt := range tests {
+		h, err := New(nil, modules(tt.available...), tt.spec)
+		switch {
+		case tt.err != "":
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("New(%v, %v) error = %v, want %q", tt.available, tt.spec, err, tt.err)
+			}
+		case err != nil:
+			t.Errorf("New(%v, %v) error = %v", tt.available, tt.spec, err)
+		case !reflect.DeepEqual(names(h.Modules()), tt.want):
+			t.Errorf("New(%v, %v) = %v, want %v", tt.available, tt.spec, names(h.Modules()), tt.want)
+		}
+	}
+}
+
+// failingStore fails every read and counts them.
+type failingStore struct {
+	storage.Store
+	gets int
+}
+
+func (s *failingStore) Get(bucket, key string, v interface{}) error {
+	s.gets++
+	return errors.New("store is down")
+}
+
+func TestEnabled(t *testing.T) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	available := modules("mod", "remind", "todo:mod,remind", "echo")
+	available = append(available, &testModule{name: "admin", global: true})
+
+	update := func(b *bot.Bot) *bot.Context {
+		return &bot.Context{Bot: b, Update: tgbotapi.Update{Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: -1}}}}
+	}
+
+	b := bot.New(&tgbotapi.BotAPI{}, storage.NewMemory(), bundle)
+	h, err := New(b, available, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Settings.Update(-1, 1, "test", func(cfg *settings.Chat) error {
+		cfg.Modules = map[string]bool{"remind": false, "admin": false}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := update(b)
+	for name, want := range map[string]bool{"mod": true, "remind": false, "todo": false, "echo": true, "admin": true} {
+		if got := h.Enabled(c, name); got != want {
+			t.Errorf("Enabled(%s) = %t, want %t", name, got, want)
+		}
+	}
+
+	// Without the settings the modules are off, and the store is read once per update.
+	store := &failingStore{Store: storage.NewMemory()}
+	b = bot.New(&tgbotapi.BotAPI{}, store, bundle)
+	if h, err = New(b, available, nil); err != nil {
+		t.Fatal(err)
+	}
+	c = update(b)
+	for name, want := range map[string]bool{"mod": false, "echo": false, "admin": true} {
+		if got := h.Enabled(c, name); got != want {
+			t.Errorf("Enabled(%s) with a failing store = %t, want %t", name, got, want)
+		}
+	}
+	if store.gets != 1 {
+		t.Errorf("the settings were read %d times, want once", store.gets)
+	}
+}
@@ -0,0 +1,88 @@
+// Package module splits the bot into modules: features like moderation,
+// reminders or echo, enabled per bot with MODULES and switched off per chat
+// with /modules.
+package module
+
+import (
+	"context"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/cron"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// Module is a feature of the bot.
+type Module interface {
+	// Name identifies the module in MODULES, in /modules and in the
+	// requirements of other modules.
+	Name() string
+	// Requires returns the names of the modules the module uses. They are
+	// initialized before it and shut down after it, and switching one of
+	// them off in a chat switches the module off too.
+	Requires() []string
+	// Init creates the parts of the module with the bot and the modules it
+	// requires.
+	Init(d *Deps) error
+	// Register adds the commands, handlers and middleware of the module to
+	// the router and its jobs to the scheduler.
+	Register(r *bot.Router, jobs *Jobs) error
+	// Migrations returns the storage migrations of the module. They are
+	// applied before Init and versioned apart from the ones of other modules.
+	Migrations() []storage.Migration
+	// Shutdown releases the module once the bot stopped handling updates and
+	// the background work returned.
+	Shutdown() error
+}
+
+// Runner is implemented by modules working in the background, such as
+// sending the due reminders. Run returns once the context is done.
+type Runner interface {
+	Run(ctx context.Context)
+}
+
+// Global is implemented by modules working for the whole bot, such as the
+// admin commands, which can't be switched off in a chat.
+type Global interface {
+	Global() bool
+}
+
+// Base implements the optional parts of Module: no requirements, no
+// migrations and nothing to shut down. Embed it in the modules.
+type Base struct{}
+
+// Requires implements Module.
+func (Base) Requires() []string { return nil }
+
+// Migrations implements Module.
+func (Base) Migrations() []storage.Migration { return nil }
+
+// Shutdown implements Module.
+func (Base) Shutdown() error { return nil }
+
+// Deps are the dependencies of a module.
+type Deps struct {
+	Bot    *bot.Bot
+	Config config.Config
+
+	modules map[string]Module
+}
+
+// Module returns the module of the name, which must be one of the modules
+// the module requires. It is initialized already.
+func (d *Deps) Module(name string) Module {
+	return d.modules[name]
+}
+
+// Jobs adds the jobs of the modules of a bot to the scheduler shared by the
+// bots of the process, prefixing their names with the name of the bot.
+type Jobs struct {
+	Scheduler *cron.Scheduler
+	Prefix    string
+}
+
+// Add adds the job, see cron.Scheduler.Add.
+func (j *Jobs) Add(name, spec string, jitter time.Duration, fn cron.Func) error {
+	return j.Scheduler.Add(j.Prefix+name, spec, jitter, fn)
+}
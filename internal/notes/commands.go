@@ -0,0 +1,276 @@
+package notes
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/media"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	// pageSize is the number of notes per /notes page.
+	pageSize = 20
+	// callbackPrefix starts the data of the /notes page buttons.
+	callbackPrefix = "notes:"
+)
+
+// Keeper saves and sends the notes.
+type Keeper struct {
+	bot   *bot.Bot
+	mod   *moderation.Moderator
+	notes notes
+}
+
+// New creates a note keeper. Group admins verified by the moderator can
+// change and delete the notes of other users.
+func New(b *bot.Bot, mod *moderation.Moderator) *Keeper {
+	return &Keeper{bot: b, mod: mod, notes: notes{store: b.Store}}
+}
+
+// Register adds the note commands, the #name middleware and the handler of
+// the page buttons to the router.
+func (k *Keeper) Register(r *bot.Router) {
+	r.Use(k.hashtag)
+	r.Command("save", k.save)
+	r.Command("get", k.get)
+	r.Command("notes", k.list)
+	r.Command("clear", k.clear)
+	r.Handle(bot.IsCallback(callbackPrefix), k.page)
+}
+
+// hashtag sends the note when a message is just #name of an existing note.
+// Other messages with hashtags are handled as usual.
+func (k *Keeper) hashtag(next bot.HandlerFunc) bot.HandlerFunc {
+	return func(c *bot.Context) error {
+		m := c.Update.Message
+		if m == nil || !strings.HasPrefix(m.Text, "#") || strings.ContainsAny(strings.TrimSpace(m.Text), " \n") {
+			return next(c)
+		}
+
+		name, err := normalize(strings.TrimSpace(m.Text))
+		if err != nil {
+			return next(c)
+		}
+		n, err := k.notes.get(m.Chat.ID, name)
+		if err == storage.ErrNotFound {
+			return next(c)
+		}
+		if err != nil {
+			return err
+		}
+
+		return k.send(c, n)
+	}
+}
+
+// save handles /save name <text>, or /save name in reply to a text or a media message.
+func (k *Keeper) save(c *bot.Context) error {
+	m := c.Update.Message
+
+	args := strings.TrimSpace(m.CommandArguments())
+	nameArg, text := args, ""
+	if i := strings.IndexAny(args, " \n"); i >= 0 {
+		nameArg, text = args[:i], strings.TrimSpace(args[i+1:])
+	}
+	name, err := normalize(nameArg)
+	if err != nil {
+		return c.Reply(c.T("notes.save_usage"))
+	}
+
+	n := &Note{ChatID: m.Chat.ID, Name: name, Text: text, CreatedBy: m.From.ID, Created: time.Now()}
+	if r := m.ReplyToMessage; r != nil && text == "" {
+		n.MediaType, n.FileID = media.Of(r)
+		if n.FileID != "" {
+			n.Text = r.Caption
+		} else {
+			n.Text = r.Text
+		}
+	}
+	if n.Text == "" && n.FileID == "" {
+		return c.Reply(c.T("notes.save_usage"))
+	}
+
+	old, err := k.notes.get(m.Chat.ID, name)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	if old != nil && old.CreatedBy != m.From.ID {
+		ok, err := k.isAdmin(m)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return c.Reply(c.T("notes.taken", "name", name))
+		}
+	}
+
+	if err := k.notes.put(n); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("notes.saved", "name", name))
+}
+
+// get handles /get name.
+func (k *Keeper) get(c *bot.Context) error {
+	m := c.Update.Message
+
+	name, err := normalize(strings.TrimSpace(m.CommandArguments()))
+	if err != nil {
+		return c.Reply(c.T("notes.get_usage"))
+	}
+
+	n, err := k.notes.get(m.Chat.ID, name)
+	if err == storage.ErrNotFound {
+		return c.Reply(c.T("notes.not_found", "name", name))
+	}
+	if err != nil {
+		return err
+	}
+
+	return k.send(c, n)
+}
+
+// clear handles /clear name. In groups only the admins can delete notes.
+func (k *Keeper) clear(c *bot.Context) error {
+	m := c.Update.Message
+
+	name, err := normalize(strings.TrimSpace(m.CommandArguments()))
+	if err != nil {
+		return c.Reply(c.T("notes.clear_usage"))
+	}
+
+	ok, err := k.isAdmin(m)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return c.Reply(c.T("moderation.forbidden"))
+	}
+
+	if _, err := k.notes.get(m.Chat.ID, name); err == storage.ErrNotFound {
+		return c.Reply(c.T("notes.not_found", "name", name))
+	} else if err != nil {
+		return err
+	}
+	if err := k.notes.delete(m.Chat.ID, name); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("notes.cleared", "name", name))
+}
+
+// list handles /notes, showing the first page of the notes of the chat.
+func (k *Keeper) list(c *bot.Context) error {
+	chatID := c.Update.Message.Chat.ID
+
+	text, keyboard, err := k.view(c, chatID, 0)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	_, err = c.Send(msg)
+
+	return err
+}
+
+// page handles the page buttons of /notes.
+func (k *Keeper) page(c *bot.Context) error {
+	q := c.Update.CallbackQuery
+	page, err := strconv.Atoi(strings.TrimPrefix(q.Data, callbackPrefix))
+	if q.Message == nil || err != nil {
+		return c.Answer("", false)
+	}
+
+	text, keyboard, err := k.view(c, q.Message.Chat.ID, page)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(q.Message.Chat.ID, q.Message.MessageID, text)
+	edit.ReplyMarkup = keyboard
+	if _, err := c.Send(edit); err != nil {
+		return err
+	}
+
+	return c.Answer("", false)
+}
+
+// view returns the text of the page of notes and its navigation buttons, or
+// a nil keyboard if all the notes fit one page.
+func (k *Keeper) view(c *bot.Context, chatID int64, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	list, err := k.notes.list(chatID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(list) == 0 {
+		return c.T("notes.none"), nil, nil
+	}
+
+	pages := (len(list) + pageSize - 1) / pageSize
+	if page < 0 || page >= pages {
+		page = 0
+	}
+
+	lines := []string{c.TN("notes.list", len(list), "page", page+1, "pages", pages)}
+	end := page*pageSize + pageSize
+	if end > len(list) {
+		end = len(list)
+	}
+	for _, n := range list[page*pageSize : end] {
+		line := "#" + n.Name
+		if n.MediaType != "" {
+			line += " (" + n.MediaType + ")"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, "", c.T("notes.hint"))
+
+	if pages == 1 {
+		return strings.Join(lines, "\n"), nil, nil
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀", callbackPrefix+strconv.Itoa(page-1)))
+	}
+	if page < pages-1 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶", callbackPrefix+strconv.Itoa(page+1)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+
+	return strings.Join(lines, "\n"), &keyboard, nil
+}
+
+// send sends the note, text notes in reply to the message asking for them.
+func (k *Keeper) send(c *bot.Context, n *Note) error {
+	m := c.Update.Message
+
+	msg := n.Chattable(m.Chat.ID)
+	if cfg, ok := msg.(tgbotapi.MessageConfig); ok {
+		cfg.ReplyToMessageID = m.MessageID
+		msg = cfg
+	}
+	_, err := c.Send(msg)
+
+	return err
+}
+
+// isAdmin reports whether the sender can manage the notes of other users:
+// everyone in private chats, the admins in groups.
+func (k *Keeper) isAdmin(m *tgbotapi.Message) (bool, error) {
+	if m.Chat.IsPrivate() {
+		return true, nil
+	}
+	return k.mod.IsAdmin(m.Chat.ID, m.From.ID)
+}
@@ -0,0 +1,187 @@
+package notes
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/media"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+type recorder struct {
+	sent []tgbotapi.Chattable
+}
+
+func (r *recorder) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	r.sent = append(r.sent, c)
+	return tgbotapi.Message{MessageID: len(r.sent)}, nil
+}
+
+// reply returns the text of the last message sent.
+func (r *recorder) reply() string {
+	if len(r.sent) == 0 {
+		return ""
+	}
+	msg, _ := r.sent[len(r.sent)-1].(tgbotapi.MessageConfig)
+	return msg.Text
+}
+
+// newKeeper returns a keeper in whose groups user 1 is the only admin.
+func newKeeper(t *testing.T) (*Keeper, *recorder) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := &tgbotapi.BotAPI{Token: "token", Client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"ok":true,"result":[{"user":{"id":1},"status":"creator"}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})}}
+
+	b := bot.New(api, storage.NewMemory(), bundle)
+	r := &recorder{}
+	b.Sender = r
+	return New(b, moderation.New(b, moderation.Policy{})), r
+}
+
+func command(k *Keeper, chatType string, userID int, text string) *bot.Context {
+	name := strings.Fields(text)[0]
+	return &bot.Context{Bot: k.bot, Update: tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: 10,
+		From:      &tgbotapi.User{ID: userID},
+		Chat:      &tgbotapi.Chat{ID: -1, Type: chatType},
+		Text:      text,
+		Entities:  &[]tgbotapi.MessageEntity{{Type: "bot_command", Length: len(name)}},
+	}}}
+}
+
+func TestSave(t *testing.T) {
+	k, r := newKeeper(t)
+
+	tests := []struct {
+		chat   string
+		userID int
+		text   string
+		reply  string
+		note   string
+	}{
+		{chat: "supergroup", userID: 2, text: "/save #Rules Be nice", reply: "Note #rules is saved", note: "Be nice"},
+		{chat: "supergroup", userID: 2, text: "/save rules Be very nice", reply: "Note #rules is saved", note: "Be very nice"},
+		{chat: "supergroup", userID: 3, text: "/save rules Be rude", reply: "Note #rules was saved by another user", note: "Be very nice"},
+		{chat: "supergroup", userID: 1, text: "/save rules Be kind", reply: "Note #rules is saved", note: "Be kind"},
+		{chat: "private", userID: 3, text: "/save rules Be calm", reply: "Note #rules is saved", note: "Be calm"},
+		{chat: "supergroup", userID: 2, text: "/save rules", reply: "Usage:", note: "Be calm"},
+		{chat: "supergroup", userID: 2, text: "/save bad-name text", reply: "Usage:", note: "Be calm"},
+	}
+	for _, tt := range tests {
+		if err := k.save(command(k, tt.chat, tt.userID, tt.text)); err != nil {
+			t.Fatalf("%s: %v", tt.text, err)
+		}
+		if !strings.HasPrefix(r.reply(), tt.reply) {
+			t.Errorf("%s by %d: reply %q, want %q", tt.text, tt.userID, r.reply(), tt.reply)
+		}
+		n, err := k.notes.get(-1, "rules")
+		if err != nil || n.Text != tt.note {
+			t.Errorf("%s by %d: note %+v, %v; want %q", tt.text, tt.userID, n, err, tt.note)
+		}
+	}
+}
+
+func TestSaveReply(t *testing.T) {
+	k, _ := newKeeper(t)
+
+	c := command(k, "private", 2, "/save photo")
+	c.Update.Message.ReplyToMessage = &tgbotapi.Message{
+		Text:    "ignored",
+		Caption: "A cat",
+		Photo:   &[]tgbotapi.PhotoSize{{FileID: "small"}, {FileID: "large"}},
+	}
+	if err := k.save(c); err != nil {
+		t.Fatal(err)
+	}
+	n, err := k.notes.get(-1, "photo")
+	if err != nil || n.MediaType != media.Photo || n.FileID != "large" || n.Text != "A cat" {
+		t.Errorf("note %+v, %v; want the largest photo with the caption", n, err)
+	}
+}
+
+func TestView(t *testing.T) {
+	k, _ := newKeeper(t)
+	c := command(k, "private", 1, "/notes")
+
+	if text, keyboard, err := k.view(c, -1, 0); err != nil || !strings.HasPrefix(text, "There are no notes") || keyboard != nil {
+		t.Errorf("view() of no notes = %q, %v, %v", text, keyboard, err)
+	}
+
+	for i := 0; i < 2*pageSize+1; i++ {
+		if err := k.notes.put(&Note{ChatID: -1, Name: fmt.Sprintf("n%02d", i), Text: "x"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		page        int
+		shownPage   int
+		first, last string
+		prev, next  bool
+	}{
+		{page: 0, shownPage: 1, first: "#n00", last: "#n19", next: true},
+		{page: 1, shownPage: 2, first: "#n20", last: "#n39", prev: true, next: true},
+		{page: 2, shownPage: 3, first: "#n40", last: "#n40", prev: true},
+		{page: 3, shownPage: 1, first: "#n00", last: "#n19", next: true},
+		{page: -1, shownPage: 1, first: "#n00", last: "#n19", next: true},
+	}
+	for _, tt := range tests {
+		text, keyboard, err := k.view(c, -1, tt.page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(text, "\n")
+		header := fmt.Sprintf("41 notes in this chat, page %d of 3:", tt.shownPage)
+		// The header, the notes, a blank line and the hint.
+		notes := lines[1 : len(lines)-2]
+		if lines[0] != header || notes[0] != tt.first || notes[len(notes)-1] != tt.last {
+			t.Errorf("view(%d) = %q", tt.page, text)
+		}
+
+		if keyboard == nil || len(keyboard.InlineKeyboard) != 1 {
+			t.Fatalf("view(%d) keyboard = %v", tt.page, keyboard)
+		}
+		var prev, next bool
+		for _, b := range keyboard.InlineKeyboard[0] {
+			switch *b.CallbackData {
+			case callbackPrefix + fmt.Sprint(tt.shownPage-2):
+				prev = true
+			case callbackPrefix + fmt.Sprint(tt.shownPage):
+				next = true
+			default:
+				t.Errorf("view(%d) has button %q", tt.page, *b.CallbackData)
+			}
+		}
+		if prev != tt.prev || next != tt.next {
+			t.Errorf("view(%d) buttons: back %v, forward %v; want %v, %v", tt.page, prev, next, tt.prev, tt.next)
+		}
+	}
+
+	if err := k.notes.delete(-1, "n40"); err != nil {
+		t.Fatal(err)
+	}
+	if _, keyboard, err := k.view(c, -1, 2); err != nil || keyboard == nil || len(keyboard.InlineKeyboard[0]) != 1 {
+		t.Errorf("view() of the removed last page = %v, %v; want the first page", keyboard, err)
+	}
+}
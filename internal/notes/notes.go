@@ -0,0 +1,111 @@
+// Package notes keeps named notes per chat, retrieved with /get name or #name.
+package notes
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/media"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	// notesBucket stores the notes by chat and name.
+	notesBucket = "notes"
+	// maxNameLength is the length limit of note names.
+	maxNameLength = 64
+)
+
+var errInvalidName = errors.New("invalid note name")
+
+// Note is a text or a media file saved in a chat.
+type Note struct {
+	ChatID int64  `json:"chat_id"`
+	Name   string `json:"name"`
+	// Text is the text of text notes and the caption of media notes.
+	Text string `json:"text,omitempty"`
+
+	// MediaType is one of the types of the media package, empty for text notes.
+	MediaType string `json:"media_type,omitempty"`
+	FileID    string `json:"file_id,omitempty"`
+
+	CreatedBy int       `json:"created_by"`
+	Created   time.Time `json:"created"`
+}
+
+// Chattable returns the request sending the note to the chat.
+func (n *Note) Chattable(chatID int64) tgbotapi.Chattable {
+	if n.FileID != "" {
+		return media.Share(chatID, n.MediaType, n.FileID, n.Text, "")
+	}
+	return tgbotapi.NewMessage(chatID, n.Text)
+}
+
+// normalize returns the canonical form of the note name, which is matched
+// case-insensitively and may start with #.
+func normalize(name string) (string, error) {
+	name = strings.ToLower(strings.TrimPrefix(name, "#"))
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return "", errInvalidName
+	}
+	// The same characters as in Telegram hashtags.
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return "", errInvalidName
+		}
+	}
+	return name, nil
+}
+
+type notes struct {
+	store storage.Store
+}
+
+func (s notes) get(chatID int64, name string) (*Note, error) {
+	var n Note
+	if err := s.store.Get(notesBucket, noteKey(chatID, name), &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (s notes) put(n *Note) error {
+	return s.store.Put(notesBucket, noteKey(n.ChatID, n.Name), n)
+}
+
+func (s notes) delete(chatID int64, name string) error {
+	return s.store.Delete(notesBucket, noteKey(chatID, name))
+}
+
+// list returns the notes of the chat ordered by name.
+func (s notes) list(chatID int64) ([]*Note, error) {
+	keys, err := s.store.Keys(notesBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strconv.FormatInt(chatID, 10) + "/"
+	var list []*Note
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		n, err := s.get(chatID, strings.TrimPrefix(k, prefix))
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, n)
+	}
+
+	return list, nil
+}
+
+func noteKey(chatID int64, name string) string {
+	return strconv.FormatInt(chatID, 10) + "/" + name
+}
@@ -0,0 +1,34 @@
+package notes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+		err  bool
+	}{
+		{name: "rules", want: "rules"},
+		{name: "#Rules", want: "rules"},
+		{name: "FAQ_2", want: "faq_2"},
+		{name: "#Правила", want: "правила"},
+		{name: "##rules", err: true},
+		{name: "#", err: true},
+		{name: "", err: true},
+		{name: "rules!", err: true},
+		{name: "the-rules", err: true},
+		{name: "the rules", err: true},
+		{name: strings.Repeat("я", maxNameLength), want: strings.Repeat("я", maxNameLength)},
+		{name: "#" + strings.Repeat("x", maxNameLength), want: strings.Repeat("x", maxNameLength)},
+		{name: strings.Repeat("x", maxNameLength+1), err: true},
+	}
+	for _, tt := range tests {
+		got, err := normalize(tt.name)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("normalize(%q) = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.err)
+		}
+	}
+}
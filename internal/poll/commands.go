@@ -0,0 +1,309 @@
+package poll
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// callbackPrefix starts the data of the vote buttons.
+const (
+	callbackPrefix = "poll:"
+	// maxShownVoters is the number of voter names shown per option. The
+	// other voters are only counted.
+	maxShownVoters = 10
+)
+
+// Pollster posts the polls and counts the votes.
+type Pollster struct {
+	bot   *bot.Bot
+	mod   *moderation.Moderator
+	polls polls
+
+	// locks holds a lock per poll in use, so concurrent votes are not lost
+	// while the polls of other chats go on.
+	locksMu sync.Mutex
+	locks   map[pollID]*pollLock
+}
+
+// New creates a pollster. Group admins verified by the moderator can close
+// the polls of other users.
+func New(b *bot.Bot, mod *moderation.Moderator) *Pollster {
+	return &Pollster{bot: b, mod: mod, polls: polls{store: b.Store}, locks: make(map[pollID]*pollLock)}
+}
+
+// Register adds the poll commands and the handler of the vote buttons to the router.
+func (p *Pollster) Register(r *bot.Router) {
+	r.Command("poll", p.poll)
+	r.Command("closepoll", p.closePoll)
+	r.Handle(bot.IsCallback(callbackPrefix), p.vote)
+}
+
+// poll handles /poll [--multiple] [--public] "Question?" "A" "B" ...
+func (p *Pollster) poll(c *bot.Context) error {
+	m := c.Update.Message
+
+	question, options, flags, err := parseArgs(m.CommandArguments())
+	if err != nil {
+		return c.Reply(c.T("poll.invalid", "error", err) + "\n\n" + c.T("poll.usage"))
+	}
+
+	poll := &Poll{
+		ChatID:   m.Chat.ID,
+		AuthorID: m.From.ID,
+		Lang:     c.Lang(),
+		Question: question,
+		Options:  options,
+		Votes:    make(map[string][]int),
+		Names:    make(map[string]string),
+		Created:  time.Now(),
+	}
+	for _, f := range flags {
+		switch f {
+		case "--multiple":
+			poll.Multiple = true
+		case "--public":
+			poll.Public = true
+		default:
+			return c.Reply(c.T("poll.unknown_flag", "flag", f) + "\n\n" + c.T("poll.usage"))
+		}
+	}
+
+	text, err := p.text(poll)
+	if err != nil {
+		return err
+	}
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = keyboard(poll)
+
+	// The poll is stored as soon as its message is sent. Votes for it before
+	// that are answered as for a deleted poll.
+	sent, err := c.Send(msg)
+	if err != nil {
+		return err
+	}
+	poll.MessageID = sent.MessageID
+
+	return p.polls.put(poll)
+}
+
+func (p *Pollster) vote(c *bot.Context) error {
+	q := c.Update.CallbackQuery
+	m := q.Message
+	option, err := strconv.Atoi(strings.TrimPrefix(q.Data, callbackPrefix))
+	if m == nil || err != nil {
+		return c.Answer("", false)
+	}
+
+	k := pollID{chatID: m.Chat.ID, messageID: m.MessageID}
+	l := p.lock(k)
+	poll, err := p.polls.get(k.chatID, k.messageID)
+	switch {
+	case err == storage.ErrNotFound:
+		p.unlock(k, l)
+		return c.Answer(c.T("poll.gone"), false)
+	case err != nil:
+		p.unlock(k, l)
+		return err
+	case poll.Closed:
+		p.unlock(k, l)
+		return c.Answer(c.T("poll.closed"), false)
+	case option < 0 || option >= len(poll.Options):
+		p.unlock(k, l)
+		return c.Answer("", false)
+	}
+
+	voted := poll.Vote(q.From, option)
+	if err := p.polls.put(poll); err != nil {
+		p.unlock(k, l)
+		return err
+	}
+	edit := p.changed(l)
+	p.unlock(k, l)
+
+	key := "poll.unvoted"
+	if voted {
+		key = "poll.voted"
+	}
+	if err := c.Answer(c.T(key, "option", poll.Options[option]), false); err != nil {
+		return err
+	}
+	if edit {
+		return p.edit(k, l)
+	}
+	return nil
+}
+
+// closePoll handles /closepoll in reply to a poll, or closes the latest open
+// poll of the sender. Group admins can close the polls of other users.
+func (p *Pollster) closePoll(c *bot.Context) error {
+	m := c.Update.Message
+
+	var poll *Poll
+	var err error
+	if r := m.ReplyToMessage; r != nil {
+		poll, err = p.polls.get(m.Chat.ID, r.MessageID)
+		if err == storage.ErrNotFound {
+			return c.Reply(c.T("poll.not_poll"))
+		}
+	} else {
+		poll, err = p.polls.latest(m.Chat.ID, m.From.ID)
+		if err == nil && poll == nil {
+			return c.Reply(c.T("poll.close_usage"))
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// The author doesn't change, so the admins are checked before locking
+	// the poll.
+	if poll.AuthorID != m.From.ID {
+		admin := false
+		if !m.Chat.IsPrivate() {
+			if admin, err = p.mod.IsAdmin(m.Chat.ID, m.From.ID); err != nil {
+				return err
+			}
+		}
+		if !admin {
+			return c.Reply(c.T("poll.not_author"))
+		}
+	}
+
+	k := pollID{chatID: poll.ChatID, messageID: poll.MessageID}
+	l := p.lock(k)
+	if poll, err = p.polls.get(k.chatID, k.messageID); err != nil {
+		p.unlock(k, l)
+		return err
+	}
+	if poll.Closed {
+		p.unlock(k, l)
+		return c.Reply(c.T("poll.closed"))
+	}
+	poll.Closed = true
+	if err := p.polls.put(poll); err != nil {
+		p.unlock(k, l)
+		return err
+	}
+	edit := p.changed(l)
+	p.unlock(k, l)
+
+	if edit {
+		if err := p.edit(k, l); err != nil {
+			return err
+		}
+	}
+	return c.Reply(c.T("poll.closed_now"))
+}
+
+// update edits the poll message to show the current results.
+func (p *Pollster) update(poll *Poll) error {
+	text, err := p.text(poll)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(poll.ChatID, poll.MessageID, text)
+	edit.ParseMode = tgbotapi.ModeHTML
+	if !poll.Closed {
+		markup := keyboard(poll)
+		edit.ReplyMarkup = &markup
+	}
+	_, err = p.bot.Sender.Send(edit)
+
+	return err
+}
+
+// option is an answer shown by the poll message.
+type option struct {
+	Text    string
+	Count   int
+	Percent int
+	Bar     string
+	// Voters are the names of the voters of public polls.
+	Voters string
+}
+
+// text renders the poll message with the results. Public polls show up to
+// maxShownVoters names per option, and fewer if the message would be too
+// long for Telegram.
+func (p *Pollster) text(poll *Poll) (string, error) {
+	for shown := maxShownVoters; ; shown /= 2 {
+		text, err := p.render(poll, shown)
+		if err != nil || render.Length(text) <= render.MaxMessageLength || shown == 0 {
+			return text, err
+		}
+	}
+}
+
+// render renders the poll message with up to shown voter names per option.
+func (p *Pollster) render(poll *Poll, shown int) (string, error) {
+	tr := func(key string, args ...interface{}) string { return p.bot.I18n.Translate(poll.Lang, key, args...) }
+
+	counts, total := poll.Counts()
+	options := make([]option, len(poll.Options))
+	for i, text := range poll.Options {
+		o := option{Text: text, Count: counts[i], Bar: bar(counts[i], total)}
+		if total > 0 {
+			o.Percent = (counts[i]*100 + total/2) / total
+		}
+		if poll.Public {
+			o.Voters = p.voters(poll, i, shown)
+		}
+		options[i] = o
+	}
+
+	footer := []string{p.bot.I18n.Plural(poll.Lang, "poll.voters", total)}
+	if poll.Public {
+		footer = append(footer, tr("poll.public"))
+	} else {
+		footer = append(footer, tr("poll.anonymous"))
+	}
+	if poll.Multiple {
+		footer = append(footer, tr("poll.multiple"))
+	}
+	if poll.Closed {
+		footer = append(footer, tr("poll.final"))
+	}
+
+	return render.Render(tgbotapi.ModeHTML, tr("poll.message"), map[string]interface{}{
+		"Question": poll.Question,
+		"Options":  options,
+		"Footer":   strings.Join(footer, " · "),
+	})
+}
+
+// voters lists up to shown names of the voters for the option followed by
+// the number of the others, or nothing if no names are shown.
+func (p *Pollster) voters(poll *Poll, option, shown int) string {
+	names := poll.voters(option)
+	switch {
+	case len(names) <= shown:
+		return strings.Join(names, ", ")
+	case shown == 0:
+		// The number of voters is shown anyway.
+		return ""
+	}
+	more := p.bot.I18n.Plural(poll.Lang, "poll.more_voters", len(names)-shown)
+	return strings.Join(names[:shown], ", ") + " " + more
+}
+
+func keyboard(poll *Poll) tgbotapi.InlineKeyboardMarkup {
+	counts, _ := poll.Counts()
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, len(poll.Options))
+	for i, text := range poll.Options {
+		label := text + " (" + strconv.Itoa(counts[i]) + ")"
+		rows[i] = tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(label, callbackPrefix+strconv.Itoa(i)))
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,98 @@
+package poll
+
+import "sync"
+
+// pollID identifies a poll by its message.
+type pollID struct {
+	chatID    int64
+	messageID int
+}
+
+// pollLock serializes the changes of a poll and the edits of its message.
+type pollLock struct {
+	// mu guards the stored poll and the fields below.
+	mu sync.Mutex
+	// editing is set while an edit of the poll message is being sent, and
+	// stale once the poll changed after that edit was rendered.
+	editing, stale bool
+
+	// refs counts the users of the lock, guarded by Pollster.locksMu.
+	refs int
+}
+
+// lock locks the poll.
+func (p *Pollster) lock(k pollID) *pollLock {
+	p.locksMu.Lock()
+	l := p.locks[k]
+	if l == nil {
+		l = &pollLock{}
+		p.locks[k] = l
+	}
+	l.refs++
+	p.locksMu.Unlock()
+
+	l.mu.Lock()
+	return l
+}
+
+// unlock unlocks the poll.
+func (p *Pollster) unlock(k pollID, l *pollLock) {
+	l.mu.Unlock()
+	p.release(k, l)
+}
+
+// release drops a reference to the lock, forgetting it once it is unused.
+func (p *Pollster) release(k pollID, l *pollLock) {
+	p.locksMu.Lock()
+	l.refs--
+	if l.refs == 0 {
+		delete(p.locks, k)
+	}
+	p.locksMu.Unlock()
+}
+
+// changed records a change of the locked poll. It reports whether the caller
+// must edit the poll message with edit once it unlocked the poll, otherwise
+// the edit being sent is followed by another one.
+func (p *Pollster) changed(l *pollLock) bool {
+	if l.editing {
+		l.stale = true
+		return false
+	}
+	l.editing = true
+
+	// The editor keeps the lock known until it is done.
+	p.locksMu.Lock()
+	l.refs++
+	p.locksMu.Unlock()
+	return true
+}
+
+// edit edits the poll message until it shows the latest state of the poll.
+// Only one edit of a poll is sent at a time, outside of the lock, so votes
+// don't wait for the flood limits of the chat.
+func (p *Pollster) edit(k pollID, l *pollLock) error {
+	defer p.release(k, l)
+
+	for {
+		l.mu.Lock()
+		poll, err := p.polls.get(k.chatID, k.messageID)
+		l.stale = false
+		if err != nil {
+			l.editing = false
+			l.mu.Unlock()
+			return err
+		}
+		l.mu.Unlock()
+
+		err = p.update(poll)
+
+		l.mu.Lock()
+		if err != nil || !l.stale {
+			l.editing = false
+			l.mu.Unlock()
+			return err
+		}
+		l.mu.Unlock()
+	}
+}
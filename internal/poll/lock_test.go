@@ -0,0 +1,109 @@
+package poll
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// gatedSender holds every edit until the gate lets it through.
+type gatedSender struct {
+	gate  chan struct{}
+	mu    sync.Mutex
+	edits []string
+}
+
+func (s *gatedSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	<-s.gate
+	s.mu.Lock()
+	s.edits = append(s.edits, c.(tgbotapi.EditMessageTextConfig).Text)
+	s.mu.Unlock()
+	return tgbotapi.Message{}, nil
+}
+
+// vote votes for the first option of the poll like the vote handler, and
+// reports whether the caller must edit the message.
+func vote(t *testing.T, p *Pollster, k pollID, userID int) (*pollLock, bool) {
+	l := p.lock(k)
+	defer p.unlock(k, l)
+
+	poll, err := p.polls.get(k.chatID, k.messageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	poll.Vote(&tgbotapi.User{ID: userID, FirstName: "u"}, 0)
+	if err := p.polls.put(poll); err != nil {
+		t.Fatal(err)
+	}
+	return l, p.changed(l)
+}
+
+// TestEditOutsideLock checks that votes aren't held up by the edit being
+// sent, and that the last edit shows every vote.
+func TestEditOutsideLock(t *testing.T) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := bot.New(&tgbotapi.BotAPI{}, storage.NewMemory(), bundle)
+	s := &gatedSender{gate: make(chan struct{})}
+	b.Sender = s
+	p := New(b, nil)
+
+	a, other := pollID{chatID: 1, messageID: 10}, pollID{chatID: 2, messageID: 20}
+	for _, k := range []pollID{a, other} {
+		poll := &Poll{ChatID: k.chatID, MessageID: k.messageID, Lang: "en", Question: "Q?", Options: []string{"yes", "no"}}
+		if err := p.polls.put(poll); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, edit := vote(t, p, a, 1)
+	if !edit {
+		t.Fatal("the first vote doesn't edit the message")
+	}
+	done := make(chan error)
+	go func() { done <- p.edit(a, l) }()
+
+	// While the edit waits for the flood limits, the poll and other polls
+	// take votes, and the edit being sent is followed by another one.
+	voted := make(chan struct{})
+	go func() {
+		if _, edit := vote(t, p, a, 2); edit {
+			t.Error("a vote during the edit edits the message too")
+		}
+		if _, edit := vote(t, p, other, 3); !edit {
+			t.Error("the vote for another poll doesn't edit its message")
+		}
+		close(voted)
+	}()
+	select {
+	case <-voted:
+	case <-time.After(time.Second):
+		t.Fatal("votes wait for the edit")
+	}
+
+	close(s.gate)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	// The first edit may have been rendered after the second vote already.
+	if n := len(s.edits); n == 0 || n > 2 || !strings.Contains(s.edits[n-1], "2 voters") {
+		t.Errorf("edits = %q, want the last one with both votes", s.edits)
+	}
+
+	// The edit of the other poll isn't sent here, so its editor reference
+	// is dropped by hand.
+	p.release(other, p.locks[other])
+	if len(p.locks) != 0 {
+		t.Errorf("%d locks are left", len(p.locks))
+	}
+}
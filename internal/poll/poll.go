@@ -0,0 +1,279 @@
+// Package poll implements polls with inline keyboard buttons, since the
+// vendored Bot API version predates native polls.
+package poll
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	// pollsBucket stores the polls by chat and message ID.
+	pollsBucket = "polls"
+
+	maxOptions        = 10
+	maxQuestionLength = 255
+	maxOptionLength   = 100
+	// barWidth is the number of characters of the result bars.
+	barWidth = 10
+)
+
+var (
+	errTooFew   = errors.New("a poll needs a question and at least 2 options")
+	errTooMany  = errors.New("a poll can have at most 10 options")
+	errTooLong  = errors.New("the question or an option is too long")
+	errUnclosed = errors.New("a quote is not closed")
+)
+
+// Poll is a poll posted to a chat.
+type Poll struct {
+	ChatID    int64 `json:"chat_id"`
+	MessageID int   `json:"message_id"`
+	AuthorID  int   `json:"author_id"`
+	// Lang is the language of the poll message.
+	Lang string `json:"lang"`
+
+	Question string   `json:"question"`
+	Options  []string `json:"options"`
+	// Multiple allows voting for several options.
+	Multiple bool `json:"multiple,omitempty"`
+	// Public shows the names of the voters.
+	Public bool `json:"public,omitempty"`
+
+	// Votes maps the user IDs to the indexes of the options they voted for.
+	Votes map[string][]int `json:"votes"`
+	// Names are the names of the voters of public polls.
+	Names map[string]string `json:"names,omitempty"`
+
+	Created time.Time `json:"created"`
+	Closed  bool      `json:"closed,omitempty"`
+}
+
+// Vote toggles the vote of the user for the option and reports whether the
+// user votes for it now. In single choice polls the other vote of the user is
+// replaced.
+func (p *Poll) Vote(u *tgbotapi.User, option int) bool {
+	if p.Votes == nil {
+		p.Votes = make(map[string][]int)
+	}
+	if p.Names == nil {
+		p.Names = make(map[string]string)
+	}
+
+	id := strconv.Itoa(u.ID)
+	votes := p.Votes[id]
+
+	voted := false
+	kept := votes[:0]
+	for _, o := range votes {
+		if o == option {
+			voted = true
+			continue
+		}
+		if p.Multiple {
+			kept = append(kept, o)
+		}
+	}
+	if !voted {
+		kept = append(kept, option)
+	}
+
+	if len(kept) == 0 {
+		delete(p.Votes, id)
+		delete(p.Names, id)
+	} else {
+		p.Votes[id] = kept
+		if p.Public {
+			p.Names[id] = name(u)
+		}
+	}
+
+	return !voted
+}
+
+// Counts returns the number of votes per option and the number of voters.
+func (p *Poll) Counts() ([]int, int) {
+	counts := make([]int, len(p.Options))
+	for _, votes := range p.Votes {
+		for _, o := range votes {
+			if o >= 0 && o < len(counts) {
+				counts[o]++
+			}
+		}
+	}
+	return counts, len(p.Votes)
+}
+
+// voters returns the names of the users who voted for the option.
+func (p *Poll) voters(option int) []string {
+	var names []string
+	for id, votes := range p.Votes {
+		for _, o := range votes {
+			if o == option {
+				names = append(names, p.Names[id])
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// bar draws the share of the voters as a bar of barWidth characters.
+func bar(count, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = (count*barWidth + total/2) / total
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", barWidth-filled)
+}
+
+func name(u *tgbotapi.User) string {
+	if u.LastName == "" {
+		return u.FirstName
+	}
+	return u.FirstName + " " + u.LastName
+}
+
+// parseArgs parses the arguments of /poll: options starting with --, then the
+// question and the answers, each quoted or on its own line.
+func parseArgs(args string) (question string, options []string, flags []string, err error) {
+	var words []string
+	if strings.ContainsAny(args, "\"“«") {
+		if words, err = splitQuoted(args); err != nil {
+			return "", nil, nil, err
+		}
+	} else {
+		// The first line may hold the options, so they are split off first.
+		for _, line := range strings.Split(args, "\n") {
+			line = strings.TrimSpace(line)
+			for strings.HasPrefix(line, "--") {
+				i := strings.IndexAny(line, " \t")
+				if i < 0 {
+					i = len(line)
+				}
+				words = append(words, line[:i])
+				line = strings.TrimSpace(line[i:])
+			}
+			if line != "" {
+				words = append(words, line)
+			}
+		}
+	}
+
+	for len(words) > 0 && strings.HasPrefix(words[0], "--") {
+		flags = append(flags, strings.ToLower(words[0]))
+		words = words[1:]
+	}
+
+	switch {
+	case len(words) < 3:
+		return "", nil, nil, errTooFew
+	case len(words)-1 > maxOptions:
+		return "", nil, nil, errTooMany
+	}
+	for i, w := range words {
+		limit := maxOptionLength
+		if i == 0 {
+			limit = maxQuestionLength
+		}
+		if utf8.RuneCountInString(w) > limit {
+			return "", nil, nil, errTooLong
+		}
+	}
+
+	return words[0], words[1:], flags, nil
+}
+
+// closing maps the opening quotes to the closing ones.
+var closing = map[rune]rune{'"': '"', '“': '”', '«': '»'}
+
+// splitQuoted splits the text into words and quoted strings.
+func splitQuoted(s string) ([]string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		quote rune
+	)
+	flush := func() {
+		if w := strings.TrimSpace(word.String()); w != "" {
+			words = append(words, w)
+		}
+		word.Reset()
+	}
+
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			flush()
+			quote = 0
+		case quote != 0:
+			word.WriteRune(r)
+		case closing[r] != 0:
+			flush()
+			quote = closing[r]
+		case r == ' ' || r == '\t' || r == '\n':
+			flush()
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		return nil, errUnclosed
+	}
+	flush()
+
+	return words, nil
+}
+
+type polls struct {
+	store storage.Store
+}
+
+func (s polls) get(chatID int64, messageID int) (*Poll, error) {
+	var p Poll
+	if err := s.store.Get(pollsBucket, pollKey(chatID, messageID), &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s polls) put(p *Poll) error {
+	return s.store.Put(pollsBucket, pollKey(p.ChatID, p.MessageID), p)
+}
+
+// latest returns the latest open poll of the user in the chat, or nil.
+func (s polls) latest(chatID int64, userID int) (*Poll, error) {
+	keys, err := s.store.Keys(pollsBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := strconv.FormatInt(chatID, 10) + "/"
+	var latest *Poll
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		var p Poll
+		if err := s.store.Get(pollsBucket, k, &p); err != nil {
+			return nil, err
+		}
+		if !p.Closed && p.AuthorID == userID && (latest == nil || p.Created.After(latest.Created)) {
+			latest = &p
+		}
+	}
+
+	return latest, nil
+}
+
+func pollKey(chatID int64, messageID int) string {
+	return strconv.FormatInt(chatID, 10) + "/" + strconv.Itoa(messageID)
+}
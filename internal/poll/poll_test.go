@@ -0,0 +1,67 @@
+package poll
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+func TestTextVoters(t *testing.T) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := New(bot.New(&tgbotapi.BotAPI{}, storage.NewMemory(), bundle), nil)
+
+	tests := []struct {
+		name    string
+		voters  int
+		options int
+		// nameLength is the length of the voter names.
+		nameLength int
+		want       []string
+		notWant    []string
+	}{
+		{name: "all", voters: 3, options: 1, nameLength: 1, want: []string{"<i>u1, u2, u3</i>"}},
+		{name: "more", voters: 15, options: 1, nameLength: 1, want: []string{"u4 and 5 more"}, notWant: []string{"u5"}},
+		{name: "long names", voters: 100, options: 10, nameLength: 64, want: []string{"100 voters", "and 95 more"}},
+	}
+	for _, tt := range tests {
+		poll := &Poll{Lang: "en", Question: "Q?", Public: true, Multiple: true}
+		for i := 0; i < tt.options; i++ {
+			poll.Options = append(poll.Options, "option "+strconv.Itoa(i))
+		}
+		for id := 1; id <= tt.voters; id++ {
+			u := &tgbotapi.User{ID: id, FirstName: "u" + strconv.Itoa(id) + strings.Repeat("x", tt.nameLength-1)}
+			for i := range poll.Options {
+				poll.Vote(u, i)
+			}
+		}
+
+		text, err := p.text(poll)
+		if err != nil {
+			t.Fatalf("%s: %s", tt.name, err)
+		}
+		if n := render.Length(text); n > render.MaxMessageLength {
+			t.Errorf("%s: text length = %d, want at most %d", tt.name, n, render.MaxMessageLength)
+		}
+		for _, s := range tt.want {
+			if !strings.Contains(text, s) {
+				t.Errorf("%s: text %q doesn't contain %q", tt.name, text, s)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(text, s) {
+				t.Errorf("%s: text %q contains %q", tt.name, text, s)
+			}
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package poller receives updates with getUpdates, backing off on errors.
+//
+// Unlike tgbotapi.BotAPI.GetUpdatesChan, which retries every 3 seconds
+// forever, the poller waits exponentially longer after every failure, stops
+// when the token is rejected and explains conflicts with other pollers and
+// webhooks.
+package poller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/tgerr"
+)
+
+// State is what the poller is doing.
+type State string
+
+// Poller states.
+const (
+	// Starting means no request succeeded yet.
+	Starting State = "starting"
+	// Polling means the last request succeeded.
+	Polling State = "polling"
+	// Backoff means the poller waits after a failed request.
+	Backoff State = "backoff"
+	// Conflict means another instance polls or a webhook is set.
+	Conflict State = "conflict"
+	// Stopped means the poller gave up, see Run.
+	Stopped State = "stopped"
+)
+
+// ErrUnauthorized is returned by Run when Telegram rejects the token.
+var ErrUnauthorized = errors.New("poller: the bot token was rejected, check TELEGRAM_APITOKEN")
+
+// Poller fetches updates with long polling.
+type Poller struct {
+	API *tgbotapi.BotAPI
+
+	// Timeout is the long polling timeout in seconds.
+	Timeout int
+	// MinBackoff and MaxBackoff bound the wait after a failed request, which
+	// doubles with every failure in a row.
+	MinBackoff, MaxBackoff time.Duration
+	// DeleteWebhook removes the webhook when it keeps getUpdates from working.
+	// Otherwise the poller waits for somebody to remove it.
+	DeleteWebhook bool
+	// OnState, if not nil, is called when the state changes and after every
+	// failed request with its error.
+	OnState func(state State, err error)
+
+	offset int
+	state  State
+}
+
+// New creates a poller with a 60 second long polling timeout and backoff
+// between 1 second and 1 minute.
+func New(api *tgbotapi.BotAPI) *Poller {
+	return &Poller{
+		API:        api,
+		Timeout:    60,
+		MinBackoff: time.Second,
+		MaxBackoff: time.Minute,
+		state:      Starting,
+	}
+}
+
+// Run sends updates to the channel until the context is done or the token is
+// rejected. Updates of a request completing after the context is done are
+// dropped, Telegram delivers them again on the next start.
+//
+// A request in progress is not interrupted, so Run may return up to the
+// polling timeout after the context is done.
+func (p *Poller) Run(ctx context.Context, updates chan<- tgbotapi.Update) error {
+	failures := 0
+	for ctx.Err() == nil {
+		config := tgbotapi.NewUpdate(p.offset)
+		config.Timeout = p.Timeout
+
+		batch, err := p.API.GetUpdates(config)
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			failures++
+			wait, err := p.failed(err, failures)
+			if err != nil {
+				p.setState(Stopped, err)
+				return err
+			}
+			if !sleep(ctx, wait) {
+				break
+			}
+			continue
+		}
+
+		failures = 0
+		p.setState(Polling, nil)
+
+		for _, u := range batch {
+			if u.UpdateID < p.offset {
+				continue
+			}
+			select {
+			case updates <- u:
+				p.offset = u.UpdateID + 1
+			case <-ctx.Done():
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// failed handles the error of a request and returns how long to wait before
+// the next one, or an error if polling can't go on.
+func (p *Poller) failed(err error, failures int) (time.Duration, error) {
+	wait := p.backoff(failures)
+
+	switch tgerr.Code(err) {
+	case tgerr.Unauthorized:
+		return 0, ErrUnauthorized
+
+	case tgerr.Conflict:
+		if strings.Contains(err.Error(), "webhook") {
+			if p.DeleteWebhook {
+				log.Printf("A webhook is set, removing it to poll for updates")
+				if _, err := p.API.RemoveWebhook(); err != nil {
+					log.Printf("Failed to remove the webhook: %s", err)
+					p.setState(Conflict, err)
+					return wait, nil
+				}
+				return 0, nil
+			}
+			log.Printf("A webhook is set, so updates can't be polled. Remove it or run the bot in webhook mode. Retrying in %s", wait)
+		} else {
+			log.Printf("Another instance of the bot polls for updates with the same token, only one may run. Retrying in %s", wait)
+		}
+		p.setState(Conflict, err)
+		return wait, nil
+	}
+
+	if retry := tgerr.RetryAfter(err); retry > wait {
+		wait = retry
+	}
+	log.Printf("Failed to get updates, retrying in %s: %s", wait, err)
+	p.setState(Backoff, fmt.Errorf("retrying in %s: %s", wait, err))
+	return wait, nil
+}
+
+// backoff returns the wait after the number of failures in a row: it doubles
+// every time up to MaxBackoff, and a random half of it is jitter, so that
+// instances failing together don't retry together.
+func (p *Poller) backoff(failures int) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < failures && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (p *Poller) setState(s State, err error) {
+	if s == p.state && err == nil {
+		return
+	}
+	if s != p.state && s == Polling {
+		log.Printf("Polling for updates")
+	}
+	p.state = s
+
+	if p.OnState != nil {
+		p.OnState(s, err)
+	}
+}
+
+// sleep waits for the duration and reports whether the context is still not done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,140 @@
+package poller
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// stub returns an API answering getUpdates with the response body and every
+// other method with success. It records the called methods.
+func stub(getUpdates string) (*tgbotapi.BotAPI, *[]string) {
+	var methods []string
+	api := &tgbotapi.BotAPI{Token: "token", Client: &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		method := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+		methods = append(methods, method)
+		body := `{"ok":true,"result":true}`
+		if method == "getUpdates" {
+			body = getUpdates
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Header: http.Header{}}, nil
+	})}}
+	return api, &methods
+}
+
+func TestBackoff(t *testing.T) {
+	p := New(nil)
+
+	tests := []struct {
+		failures int
+		max      time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+	for _, tt := range tests {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			d := p.backoff(tt.failures)
+			if d < tt.max/2 || d > tt.max {
+				t.Fatalf("backoff(%d) = %s, want between %s and %s", tt.failures, d, tt.max/2, tt.max)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("backoff(%d) has no jitter", tt.failures)
+		}
+	}
+
+	p.MinBackoff, p.MaxBackoff = 0, 0
+	if d := p.backoff(3); d != 0 {
+		t.Errorf("backoff without bounds = %s, want 0", d)
+	}
+}
+
+func TestFailed(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		deleteWebhook bool
+		state         State
+		err           error
+		// minWait is the least wait, and no wait is expected if it is zero.
+		minWait time.Duration
+		methods string
+	}{
+		{
+			name:  "unauthorized",
+			body:  `{"ok":false,"error_code":401,"description":"Unauthorized"}`,
+			state: Starting,
+			err:   ErrUnauthorized,
+		},
+		{
+			name:    "other poller",
+			body:    `{"ok":false,"error_code":409,"description":"Conflict: terminated by other getUpdates request; make sure that only one bot instance is running"}`,
+			state:   Conflict,
+			minWait: 500 * time.Millisecond,
+		},
+		{
+			name:    "webhook kept",
+			body:    `{"ok":false,"error_code":409,"description":"Conflict: can't use getUpdates method while webhook is active; use deleteWebhook to delete the webhook first"}`,
+			state:   Conflict,
+			minWait: 500 * time.Millisecond,
+		},
+		{
+			name:          "webhook removed",
+			body:          `{"ok":false,"error_code":409,"description":"Conflict: can't use getUpdates method while webhook is active; use deleteWebhook to delete the webhook first"}`,
+			deleteWebhook: true,
+			state:         Starting,
+			methods:       "setWebhook",
+		},
+		{
+			name:    "flood control",
+			body:    `{"ok":false,"error_code":429,"description":"Too Many Requests: retry after 30","parameters":{"retry_after":30}}`,
+			state:   Backoff,
+			minWait: 30 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		api, methods := stub(tt.body)
+		_, err := api.GetUpdates(tgbotapi.NewUpdate(0))
+		if err == nil {
+			t.Fatalf("%s: getUpdates succeeded", tt.name)
+		}
+		*methods = nil
+
+		p := New(api)
+		p.DeleteWebhook = tt.deleteWebhook
+		wait, err := p.failed(err, 1)
+
+		if err != tt.err {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.err)
+		}
+		switch {
+		case tt.minWait == 0 && wait != 0:
+			t.Errorf("%s: wait = %s, want none", tt.name, wait)
+		case wait < tt.minWait:
+			t.Errorf("%s: wait = %s, want at least %s", tt.name, wait, tt.minWait)
+		}
+		if p.state != tt.state {
+			t.Errorf("%s: state = %s, want %s", tt.name, p.state, tt.state)
+		}
+		if got := strings.Join(*methods, " "); got != tt.methods {
+			t.Errorf("%s: called %q, want %q", tt.name, got, tt.methods)
+		}
+	}
+}
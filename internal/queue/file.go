@@ -0,0 +1,301 @@
+package queue
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// compactSize is the log size above which a partition log is rewritten with
+// only the messages not committed yet, once at most half of its messages are.
+const compactSize = 1 << 20
+
+// File is a durable Queue stored in a directory. Every partition has a log of
+// JSON lines with the published messages and a file with the committed
+// offset. A message is synced to the log before Publish returns. Messages not
+// committed when the process stops are delivered again when the queue is
+// reopened, so an update may be handled twice but is never lost.
+type File struct {
+	*Memory
+
+	dir  string
+	logs []*os.File
+	// size and lines are the size of every log and the number of messages
+	// in it, committed or not.
+	size  []int64
+	lines []int
+}
+
+// OpenFile opens or creates the queue in dir. The number of partitions of an
+// existing queue can't change, since that would reorder the updates of chats.
+func OpenFile(dir string, partitions int) (*File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	if err := checkPartitions(dir, partitions); err != nil {
+		return nil, err
+	}
+
+	q := &File{
+		Memory: NewMemory(partitions),
+		dir:    dir,
+		logs:   make([]*os.File, partitions),
+		size:   make([]int64, partitions),
+		lines:  make([]int, partitions),
+	}
+	for i := range q.logs {
+		if err := q.load(i); err != nil {
+			q.closeLogs()
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// checkPartitions records the number of partitions of a new queue and
+// compares it with that of an existing one.
+func checkPartitions(dir string, partitions int) error {
+	path := filepath.Join(dir, "partitions")
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ioutil.WriteFile(path, []byte(strconv.Itoa(partitions)+"\n"), 0644)
+	}
+	if err != nil {
+		return err
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return fmt.Errorf("queue: %s: %s", path, err)
+	}
+	if n != partitions {
+		return fmt.Errorf("queue: %s has %d partitions, not %d", dir, n, partitions)
+	}
+	return nil
+}
+
+func (q *File) logPath(i int) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%d.log", i))
+}
+
+func (q *File) commitPath(i int) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%d.commit", i))
+}
+
+// load reads the committed offset and the messages after it and opens the
+// log for appending. A torn last line, left by a crash while publishing, is
+// cut off the log.
+func (q *File) load(i int) error {
+	p := q.parts[i]
+
+	data, err := ioutil.ReadFile(q.commitPath(i))
+	switch {
+	case os.IsNotExist(err):
+	case err != nil:
+		return err
+	default:
+		if p.committed, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return fmt.Errorf("queue: %s: %s", q.commitPath(i), err)
+		}
+	}
+	p.fetched = p.committed
+	p.next = p.committed + 1
+
+	f, err := os.OpenFile(q.logPath(i), os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	q.logs[i] = f
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			if len(data) > 0 {
+				log.Printf("Dropping the torn last line %d of %s", line, q.logPath(i))
+				if err := f.Truncate(q.size[i]); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			// A complete line is never torn, so this is corruption.
+			return fmt.Errorf("queue: %s:%d: %s", q.logPath(i), line, err)
+		}
+		q.size[i] += int64(len(data))
+		q.lines[i]++
+		if m.Offset <= p.committed {
+			continue
+		}
+		m.Partition = i
+		p.messages = append(p.messages, m)
+		p.next = m.Offset + 1
+	}
+
+	if len(p.messages) > 0 {
+		p.ready <- struct{}{}
+	}
+	return nil
+}
+
+// Publish implements Queue. The message is synced to the log before it can
+// be fetched.
+func (q *File) Publish(u tgbotapi.Update) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, err := q.publish(u, func(m Message) error {
+		data, err := encode(m)
+		if err != nil {
+			return err
+		}
+		f := q.logs[m.Partition]
+		if _, err := f.Write(data); err != nil {
+			return err
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+		q.size[m.Partition] += int64(len(data))
+		q.lines[m.Partition]++
+		return nil
+	})
+	return err
+}
+
+// Commit implements Queue. A large log is compacted once at most half of its
+// messages are not committed.
+func (q *File) Commit(partition int, offset int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return ErrClosed
+	}
+	if partition < 0 || partition >= len(q.parts) {
+		return fmt.Errorf("queue: no partition %d", partition)
+	}
+	if offset <= q.parts[partition].committed {
+		return nil
+	}
+
+	// The offset is written to a temporary file first, so a crash leaves
+	// either the old or the new one.
+	path := q.commitPath(partition)
+	if err := ioutil.WriteFile(path+".tmp", []byte(strconv.FormatInt(offset, 10)+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	if err := q.commit(partition, offset); err != nil {
+		return err
+	}
+
+	if q.size[partition] > compactSize && 2*len(q.parts[partition].messages) <= q.lines[partition] {
+		return q.compact(partition)
+	}
+	return nil
+}
+
+// compact rewrites the log of the partition with the messages not committed
+// yet. The new log replaces the old one only once it is synced, so a crash
+// leaves either of them, and both hold every message not committed.
+func (q *File) compact(i int) error {
+	path := q.logPath(i)
+	tmp, err := os.OpenFile(path+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	var size int64
+	for _, m := range q.parts[i].messages {
+		data, err := encode(m)
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			tmp.Close()
+			return err
+		}
+		size += int64(len(data))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	q.logs[i].Close()
+	q.logs[i] = f
+	q.size[i] = size
+	q.lines[i] = len(q.parts[i].messages)
+	return nil
+}
+
+// encode returns the log line of the message.
+func encode(m Message) ([]byte, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Close implements Queue.
+func (q *File) Close() error {
+	if err := q.Memory.Close(); err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.closeLogs()
+}
+
+func (q *File) closeLogs() error {
+	var first error
+	for _, f := range q.logs {
+		if f == nil {
+			continue
+		}
+		if err := f.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
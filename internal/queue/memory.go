@@ -0,0 +1,165 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// partition holds the messages of a partition not committed yet.
+type partition struct {
+	// next is the offset of the next published message.
+	next int64
+	// fetched is the offset of the last fetched message.
+	fetched int64
+	// committed is the offset of the last committed message.
+	committed int64
+	messages  []Message
+	ready     chan struct{}
+}
+
+// Memory is an in-memory Queue. Messages not committed are lost when the
+// process stops. The zero value is not usable, use NewMemory.
+type Memory struct {
+	mu     sync.Mutex
+	parts  []*partition
+	closed bool
+}
+
+// NewMemory creates a queue with the number of partitions.
+func NewMemory(partitions int) *Memory {
+	q := &Memory{parts: make([]*partition, partitions)}
+	for i := range q.parts {
+		q.parts[i] = &partition{next: 1, ready: make(chan struct{}, 1)}
+	}
+	return q
+}
+
+// Publish implements Queue.
+func (q *Memory) Publish(u tgbotapi.Update) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	_, err := q.publish(u, nil)
+	return err
+}
+
+// publish appends the update, calling write with the message first unless
+// it is nil. It must be called with mu held.
+func (q *Memory) publish(u tgbotapi.Update, write func(Message) error) (Message, error) {
+	if q.closed {
+		return Message{}, ErrClosed
+	}
+
+	i := Partition(u, len(q.parts))
+	p := q.parts[i]
+	m := Message{Partition: i, Offset: p.next, Update: u}
+	if write != nil {
+		if err := write(m); err != nil {
+			return Message{}, err
+		}
+	}
+
+	p.next++
+	p.messages = append(p.messages, m)
+	select {
+	case p.ready <- struct{}{}:
+	default:
+	}
+	return m, nil
+}
+
+// Fetch implements Queue.
+func (q *Memory) Fetch(ctx context.Context, partition int) (Message, error) {
+	if partition < 0 || partition >= len(q.parts) {
+		return Message{}, fmt.Errorf("queue: no partition %d", partition)
+	}
+	p := q.parts[partition]
+
+	for {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return Message{}, ErrClosed
+		}
+		for _, m := range p.messages {
+			if m.Offset > p.fetched {
+				p.fetched = m.Offset
+				q.mu.Unlock()
+				return m, nil
+			}
+		}
+		q.mu.Unlock()
+
+		select {
+		case <-p.ready:
+		case <-ctx.Done():
+			return Message{}, ctx.Err()
+		}
+	}
+}
+
+// Commit implements Queue.
+func (q *Memory) Commit(partition int, offset int64) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.commit(partition, offset)
+}
+
+// commit drops the committed messages. It must be called with mu held.
+func (q *Memory) commit(partition int, offset int64) error {
+	if q.closed {
+		return ErrClosed
+	}
+	if partition < 0 || partition >= len(q.parts) {
+		return fmt.Errorf("queue: no partition %d", partition)
+	}
+
+	p := q.parts[partition]
+	if offset <= p.committed {
+		return nil
+	}
+	p.committed = offset
+
+	n := 0
+	for n < len(p.messages) && p.messages[n].Offset <= offset {
+		n++
+	}
+	p.messages = append(p.messages[:0], p.messages[n:]...)
+	return nil
+}
+
+// Partitions implements Queue.
+func (q *Memory) Partitions() int {
+	return len(q.parts)
+}
+
+// Pending implements Queue.
+func (q *Memory) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	n := 0
+	for _, p := range q.parts {
+		n += len(p.messages)
+	}
+	return n
+}
+
+// Close implements Queue. Fetch calls waiting for messages return ErrClosed.
+func (q *Memory) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	for _, p := range q.parts {
+		select {
+		case p.ready <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+// Package queue decouples receiving updates from handling them.
+//
+// The ingestor publishes every update to the partition of its chat, and a
+// worker per partition handles the updates of the partition one at a time,
+// so the updates of a chat are handled in order while different chats are
+// handled in parallel. A worker commits the offset of an update once it is
+// handled, and a durable queue delivers the updates after the committed
+// offset again when it is reopened.
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// ErrClosed is returned by the operations of a closed queue.
+var ErrClosed = errors.New("queue: closed")
+
+// Message is an update published to a partition.
+type Message struct {
+	Partition int             `json:"-"`
+	Offset    int64           `json:"offset"`
+	Update    tgbotapi.Update `json:"update"`
+}
+
+// Queue holds published updates until they are committed.
+type Queue interface {
+	// Publish appends the update to the partition of its chat.
+	Publish(u tgbotapi.Update) error
+	// Fetch returns the next message of the partition, waiting until there
+	// is one or the context is done.
+	Fetch(ctx context.Context, partition int) (Message, error)
+	// Commit marks the messages of the partition up to the offset as handled.
+	Commit(partition int, offset int64) error
+	// Partitions returns the number of partitions.
+	Partitions() int
+	// Pending returns the number of published messages not committed yet.
+	Pending() int
+	// Close closes the queue.
+	Close() error
+}
+
+// Partition returns the partition of the update: the updates of a chat, or
+// of a user outside of chats, always go to the same one.
+func Partition(u tgbotapi.Update, partitions int) int {
+	key := Key(u)
+	if key < 0 {
+		key = -key
+	}
+	return int(key % int64(partitions))
+}
+
+// Key returns the chat ID of the update, or the user ID for updates without
+// a chat such as inline queries.
+func Key(u tgbotapi.Update) int64 {
+	var m *tgbotapi.Message
+	switch {
+	case u.Message != nil:
+		m = u.Message
+	case u.EditedMessage != nil:
+		m = u.EditedMessage
+	case u.ChannelPost != nil:
+		m = u.ChannelPost
+	case u.EditedChannelPost != nil:
+		m = u.EditedChannelPost
+	case u.CallbackQuery != nil:
+		if u.CallbackQuery.Message != nil {
+			m = u.CallbackQuery.Message
+		} else if u.CallbackQuery.From != nil {
+			return int64(u.CallbackQuery.From.ID)
+		}
+	case u.InlineQuery != nil && u.InlineQuery.From != nil:
+		return int64(u.InlineQuery.From.ID)
+	case u.ChosenInlineResult != nil && u.ChosenInlineResult.From != nil:
+		return int64(u.ChosenInlineResult.From.ID)
+	case u.ShippingQuery != nil && u.ShippingQuery.From != nil:
+		return int64(u.ShippingQuery.From.ID)
+	case u.PreCheckoutQuery != nil && u.PreCheckoutQuery.From != nil:
+		return int64(u.PreCheckoutQuery.From.ID)
+	}
+
+	if m != nil && m.Chat != nil {
+		return m.Chat.ID
+	}
+	return 0
+}
+
+// Ingest publishes the updates until the channel is closed or the context is
+// done. Telegram considers the updates in the channel delivered already, since
+// the poller asks for the following ones and the webhook answers once an
+// update is in the channel, so when the context is done the updates left in
+// the channel are published before Ingest returns.
+func Ingest(ctx context.Context, q Queue, updates <-chan tgbotapi.Update) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return drain(q, updates)
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := q.Publish(u); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// drain publishes the updates waiting in the channel.
+func drain(q Queue, updates <-chan tgbotapi.Update) error {
+	for {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			if err := q.Publish(u); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+// Work handles the messages of the partition until the context is done,
+// committing every message after it is handled. A message being handled when
+// the context is done is finished first.
+func Work(ctx context.Context, q Queue, partition int, handle func(tgbotapi.Update)) {
+	for {
+		m, err := q.Fetch(ctx, partition)
+		if err != nil {
+			if err != context.Canceled && err != ErrClosed {
+				log.Printf("Failed to fetch from partition %d: %s", partition, err)
+			}
+			return
+		}
+
+		handle(m.Update)
+
+		if err := q.Commit(partition, m.Offset); err != nil {
+			log.Printf("Failed to commit offset %d of partition %d: %s", m.Offset, partition, err)
+		}
+	}
+}
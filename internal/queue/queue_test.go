@@ -0,0 +1,388 @@
+package queue
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func message(id int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{UpdateID: id, Message: &tgbotapi.Message{Chat: &tgbotapi.Chat{ID: chatID}}}
+}
+
+func TestKey(t *testing.T) {
+	user := &tgbotapi.User{ID: 7}
+	chat := &tgbotapi.Chat{ID: -100}
+	tests := []struct {
+		name string
+		u    tgbotapi.Update
+		want int64
+	}{
+		{"message", tgbotapi.Update{Message: &tgbotapi.Message{Chat: chat}}, -100},
+		{"edited message", tgbotapi.Update{EditedMessage: &tgbotapi.Message{Chat: chat}}, -100},
+		{"channel post", tgbotapi.Update{ChannelPost: &tgbotapi.Message{Chat: chat}}, -100},
+		{"callback on a message", tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: user, Message: &tgbotapi.Message{Chat: chat}}}, -100},
+		{"inline callback", tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{From: user}}, 7},
+		{"inline query", tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{From: user}}, 7},
+		{"chosen inline result", tgbotapi.Update{ChosenInlineResult: &tgbotapi.ChosenInlineResult{From: user}}, 7},
+		{"empty", tgbotapi.Update{}, 0},
+	}
+	for _, tt := range tests {
+		if got := Key(tt.u); got != tt.want {
+			t.Errorf("%s: Key() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPartition(t *testing.T) {
+	tests := []struct {
+		chatID int64
+		want   int
+	}{
+		{0, 0},
+		{4, 0},
+		{5, 1},
+		{-5, 1},
+		{-100123, 3},
+	}
+	for _, tt := range tests {
+		if got := Partition(message(1, tt.chatID), 4); got != tt.want {
+			t.Errorf("Partition(chat %d, 4) = %d, want %d", tt.chatID, got, tt.want)
+		}
+	}
+}
+
+// fetchAll fetches the messages of the partition until none is left.
+func fetchAll(t *testing.T, q Queue, partition int) []Message {
+	t.Helper()
+	var got []Message
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		m, err := q.Fetch(ctx, partition)
+		cancel()
+		if err == context.DeadlineExceeded {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, m)
+	}
+}
+
+func ids(messages []Message) []int {
+	ids := []int{}
+	for _, m := range messages {
+		ids = append(ids, m.Update.UpdateID)
+	}
+	return ids
+}
+
+func openFile(t *testing.T, dir string, partitions int) *File {
+	t.Helper()
+	q, err := OpenFile(dir, partitions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "queue")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+// TestOrder checks that every partition delivers the updates of its chats in
+// the order they were published, with increasing offsets.
+func TestOrder(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	queues := map[string]Queue{"memory": NewMemory(2), "file": openFile(t, dir, 2)}
+	for name, q := range queues {
+		t.Run(name, func(t *testing.T) {
+			defer q.Close()
+			for i, chatID := range []int64{1, 2, 3, 1, 4, 3, 2} {
+				if err := q.Publish(message(i+1, chatID)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := q.Pending(); got != 7 {
+				t.Errorf("Pending() = %d, want 7", got)
+			}
+
+			tests := []struct {
+				partition int
+				ids       []int
+			}{
+				{0, []int{2, 5, 7}},
+				{1, []int{1, 3, 4, 6}},
+			}
+			for _, tt := range tests {
+				got := fetchAll(t, q, tt.partition)
+				if !reflect.DeepEqual(ids(got), tt.ids) {
+					t.Errorf("partition %d delivered %v, want %v", tt.partition, ids(got), tt.ids)
+				}
+				for i, m := range got {
+					if m.Partition != tt.partition || m.Offset != int64(i+1) {
+						t.Errorf("message %d has partition %d and offset %d", m.Update.UpdateID, m.Partition, m.Offset)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestCommit checks the commit contract: committed messages are dropped,
+// committing again or backwards changes nothing, and a fetched message is not
+// fetched again even if it isn't committed.
+func TestCommit(t *testing.T) {
+	q := NewMemory(1)
+	for i := 1; i <= 4; i++ {
+		if err := q.Publish(message(i, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	steps := []struct {
+		commit  int64
+		pending int
+	}{
+		{0, 4},
+		{2, 2},
+		{1, 2},
+		{2, 2},
+		{4, 0},
+	}
+	for _, s := range steps {
+		if err := q.Commit(0, s.commit); err != nil {
+			t.Fatal(err)
+		}
+		if got := q.Pending(); got != s.pending {
+			t.Errorf("Pending() after Commit(0, %d) = %d, want %d", s.commit, got, s.pending)
+		}
+	}
+
+	if err := q.Publish(message(5, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(fetchAll(t, q, 0)); !reflect.DeepEqual(got, []int{5}) {
+		t.Errorf("fetched %v after committing, want [5]", got)
+	}
+	if got := fetchAll(t, q, 0); len(got) != 0 {
+		t.Errorf("fetched %v again", ids(got))
+	}
+
+	if err := q.Commit(1, 1); err == nil {
+		t.Error("Commit() of a missing partition succeeded")
+	}
+	q.Close()
+	if err := q.Publish(message(6, 1)); err != ErrClosed {
+		t.Errorf("Publish() after Close() = %v, want ErrClosed", err)
+	}
+	if _, err := q.Fetch(context.Background(), 0); err != ErrClosed {
+		t.Errorf("Fetch() after Close() = %v, want ErrClosed", err)
+	}
+}
+
+// TestFileReopen checks that the updates after the committed offset are
+// delivered again when the queue is reopened, and the others are not.
+func TestFileReopen(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	q := openFile(t, dir, 1)
+	for i := 1; i <= 5; i++ {
+		if err := q.Publish(message(i, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fetchAll(t, q, 0)
+	if err := q.Commit(0, 2); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q = openFile(t, dir, 1)
+	if got := ids(fetchAll(t, q, 0)); !reflect.DeepEqual(got, []int{3, 4, 5}) {
+		t.Errorf("reopened queue delivered %v, want [3 4 5]", got)
+	}
+	if err := q.Publish(message(6, 1)); err != nil {
+		t.Fatal(err)
+	}
+	got := fetchAll(t, q, 0)
+	if len(got) != 1 || got[0].Offset != 6 {
+		t.Errorf("published after reopening %+v, want offset 6", got)
+	}
+	q.Close()
+
+	if _, err := OpenFile(dir, 2); err == nil {
+		t.Error("OpenFile() with another number of partitions succeeded")
+	}
+}
+
+func TestFileTornLine(t *testing.T) {
+	tests := []struct {
+		name string
+		tail string
+		ids  []int
+		err  string
+	}{
+		{"torn json", `{"offset":3,"upd`, []int{1, 2}, ""},
+		{"missing newline", `{"offset":3,"update":{"update_id":3}}`, []int{1, 2}, ""},
+		{"corrupt complete line", "garbage\n", nil, "0.log:3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+
+			q := openFile(t, dir, 1)
+			for i := 1; i <= 2; i++ {
+				if err := q.Publish(message(i, 1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			q.Close()
+			path := filepath.Join(dir, "0.log")
+			good, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, append(good, tt.tail...), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			q, err = OpenFile(dir, 1)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("OpenFile() = %v, want an error with %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer q.Close()
+
+			if got := ids(fetchAll(t, q, 0)); !reflect.DeepEqual(got, tt.ids) {
+				t.Errorf("delivered %v, want %v", got, tt.ids)
+			}
+			// The torn line is cut off, so the next message starts a line.
+			if err := q.Publish(message(3, 1)); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(data), string(good)) || strings.Count(string(data), "\n") != 3 {
+				t.Errorf("log after publishing is %q", data)
+			}
+		})
+	}
+}
+
+// TestFileCompact checks that a large log is rewritten with the pending
+// messages while others stay uncommitted.
+func TestFileCompact(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	q := openFile(t, dir, 1)
+	text := strings.Repeat("x", 16*1024)
+	n := compactSize/len(text) + 10
+	for i := 1; i <= n; i++ {
+		u := message(i, 1)
+		u.Message.Text = text
+		if err := q.Publish(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Committing all but the last three compacts the log, although the
+	// queue is never empty.
+	if err := q.Commit(0, int64(n-3)); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "0.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 4*int64(len(text)) {
+		t.Errorf("log has %d bytes after the commit, want 3 messages", info.Size())
+	}
+	if err := q.Publish(message(n+1, 1)); err != nil {
+		t.Fatal(err)
+	}
+	q.Close()
+
+	q = openFile(t, dir, 1)
+	defer q.Close()
+	got := fetchAll(t, q, 0)
+	if want := []int{n - 2, n - 1, n, n + 1}; !reflect.DeepEqual(ids(got), want) {
+		t.Errorf("reopened queue delivered %v, want %v", ids(got), want)
+	}
+	if got[len(got)-1].Offset != int64(n+1) {
+		t.Errorf("last offset is %d, want %d", got[len(got)-1].Offset, n+1)
+	}
+}
+
+// TestIngestDrains checks that the updates waiting in the channel when the
+// context is done are published.
+func TestIngestDrains(t *testing.T) {
+	q := NewMemory(1)
+	updates := make(chan tgbotapi.Update, 3)
+	for i := 1; i <= 3; i++ {
+		updates <- message(i, 1)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Ingest(ctx, q, updates); err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Pending(); got != 3 {
+		t.Errorf("Pending() = %d, want the 3 buffered updates", got)
+	}
+}
+
+func TestWork(t *testing.T) {
+	q := NewMemory(1)
+	for i := 1; i <= 3; i++ {
+		if err := q.Publish(message(i, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var handled []int
+	done := make(chan struct{})
+	go func() {
+		Work(ctx, q, 0, func(u tgbotapi.Update) {
+			handled = append(handled, u.UpdateID)
+			if len(handled) == 3 {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+	<-done
+
+	if !reflect.DeepEqual(handled, []int{1, 2, 3}) {
+		t.Errorf("handled %v, want [1 2 3]", handled)
+	}
+	if got := q.Pending(); got != 0 {
+		t.Errorf("Pending() = %d, want every handled update committed", got)
+	}
+}
@@ -0,0 +1,223 @@
+// Package record records the updates the bot receives and the Bot API calls
+// it makes, and replays recordings against an offline stand-in of the API.
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+)
+
+// Entry types.
+const (
+	TypeUpdate = "update"
+	TypeCall   = "call"
+)
+
+// Entry is a line of a recording: an update or an API call.
+type Entry struct {
+	Time time.Time `json:"time"`
+	Type string    `json:"type"`
+
+	// Update is the raw update as Telegram sent it.
+	Update json.RawMessage `json:"update,omitempty"`
+
+	// Method and Params are the API method and its parameters. Uploaded
+	// files are described by their name and size.
+	Method string            `json:"method,omitempty"`
+	Params map[string]string `json:"params,omitempty"`
+	// Status and Response are the HTTP status and the body of the response.
+	Status   int             `json:"status,omitempty"`
+	Response json.RawMessage `json:"response,omitempty"`
+	// Error is the error of a request that got no response.
+	Error string `json:"error,omitempty"`
+}
+
+// Recorder appends entries to a JSON lines file.
+type Recorder struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+}
+
+// Create opens the recording at path for appending.
+func Create(path string) (*Recorder, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+func (r *Recorder) write(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.enc.Encode(e); err != nil {
+		log.Printf("Failed to record a %s: %s", e.Type, err)
+	}
+}
+
+// Close closes the recording.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.f.Close()
+}
+
+// Transport returns an http.RoundTripper recording the API calls made through
+// next. The updates returned by getUpdates are recorded as updates.
+func (r *Recorder) Transport(next http.RoundTripper) http.RoundTripper {
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		method := bot.APIMethod(req.URL.Path)
+		params, err := readParams(req)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := next.RoundTrip(req)
+		now := time.Now()
+		if err != nil {
+			r.write(Entry{Time: now, Type: TypeCall, Method: method, Params: params, Error: err.Error()})
+			return resp, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if method == "getUpdates" && resp.StatusCode == http.StatusOK {
+			var result struct {
+				Result []json.RawMessage `json:"result"`
+			}
+			if err := json.Unmarshal(body, &result); err == nil {
+				for _, u := range result.Result {
+					r.write(Entry{Time: now, Type: TypeUpdate, Update: u})
+				}
+				return resp, nil
+			}
+		}
+
+		e := Entry{Time: now, Type: TypeCall, Method: method, Params: params, Status: resp.StatusCode}
+		if json.Valid(body) {
+			e.Response = body
+		} else {
+			e.Response, _ = json.Marshal(string(body))
+		}
+		r.write(e)
+		return resp, nil
+	})
+}
+
+// Handler records the updates posted to the webhook before next handles them.
+func (r *Recorder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == http.MethodPost {
+			body, err := ioutil.ReadAll(req.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			if json.Valid(body) {
+				r.write(Entry{Time: time.Now(), Type: TypeUpdate, Update: body})
+			}
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// readParams returns the parameters of an API request, leaving the body to
+// be sent.
+func readParams(req *http.Request) (map[string]string, error) {
+	if req.Body == nil {
+		return nil, nil
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+
+	params := make(map[string]string)
+	mediaType, mediaParams, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		for k := range values {
+			params[k] = values.Get(k)
+		}
+		return params, nil
+	}
+
+	mr := multipart.NewReader(bytes.NewReader(body), mediaParams["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err != nil {
+			break
+		}
+		data, _ := ioutil.ReadAll(part)
+		if part.FileName() != "" {
+			params[part.FormName()] = fmt.Sprintf("file:%s (%d bytes)", part.FileName(), len(data))
+		} else {
+			params[part.FormName()] = string(data)
+		}
+	}
+	return params, nil
+}
+
+// Read reads a recording.
+func Read(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		if len(bytes.TrimSpace(sc.Bytes())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("record: %s:%d: %s", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, sc.Err()
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
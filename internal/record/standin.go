@@ -0,0 +1,242 @@
+package record
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+)
+
+// ignored are the methods left out of the comparison, since they depend on
+// how the bot receives updates rather than on the handlers.
+var ignored = map[string]bool{
+	"getUpdates":     true,
+	"getMe":          true,
+	"getWebhookInfo": true,
+	"setWebhook":     true,
+	"deleteWebhook":  true,
+}
+
+// Call is an API call with its parameters.
+type Call struct {
+	Method string
+	Params map[string]string
+}
+
+// String formats the call with the parameters sorted by name.
+func (c Call) String() string {
+	keys := make([]string, 0, len(c.Params))
+	for k := range c.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(c.Method)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%q", k, c.Params[k])
+	}
+	return b.String()
+}
+
+// Calls returns the calls of the recording made by the handlers.
+func Calls(entries []Entry) []Call {
+	var calls []Call
+	for _, e := range entries {
+		if e.Type == TypeCall && !ignored[e.Method] {
+			calls = append(calls, Call{Method: e.Method, Params: e.Params})
+		}
+	}
+	return calls
+}
+
+// StandIn is an offline http.RoundTripper answering Bot API requests. A
+// request gets the recorded response of the next recorded call of the same
+// method, or a made up successful one.
+type StandIn struct {
+	mu        sync.Mutex
+	responses map[string][]json.RawMessage
+	calls     []Call
+	messageID int
+}
+
+// NewStandIn creates a stand-in answering with the responses of the recording.
+func NewStandIn(entries []Entry) *StandIn {
+	s := &StandIn{responses: make(map[string][]json.RawMessage)}
+	for _, e := range entries {
+		if e.Type == TypeCall && e.Status == http.StatusOK && len(e.Response) > 0 {
+			s.responses[e.Method] = append(s.responses[e.Method], e.Response)
+		}
+	}
+	return s
+}
+
+// RoundTrip implements http.RoundTripper.
+func (s *StandIn) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := bot.APIMethod(req.URL.Path)
+	params, err := readParams(req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !ignored[method] {
+		s.calls = append(s.calls, Call{Method: method, Params: params})
+	}
+
+	body := s.madeUp(method, params)
+	if recorded := s.responses[method]; len(recorded) > 0 {
+		body, s.responses[method] = recorded[0], recorded[1:]
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+		Request:    req,
+	}, nil
+}
+
+// Calls returns the calls made so far, except the ignored methods.
+func (s *StandIn) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Call(nil), s.calls...)
+}
+
+// madeUp returns a successful response of the method: the bot for getMe,
+// a message for the send and edit methods and true otherwise.
+func (s *StandIn) madeUp(method string, params map[string]string) []byte {
+	var result interface{} = true
+	switch {
+	case method == "getMe":
+		result = map[string]interface{}{"id": 1, "is_bot": true, "first_name": "Bot", "username": "offline_bot"}
+	case method == "getUpdates", method == "getChatAdministrators":
+		result = []interface{}{}
+	case strings.HasPrefix(method, "send"), strings.HasPrefix(method, "edit"), method == "forwardMessage":
+		chatID, _ := strconv.ParseInt(params["chat_id"], 10, 64)
+		s.messageID++
+		result = map[string]interface{}{
+			"message_id": s.messageID,
+			"date":       time.Now().Unix(),
+			"chat":       map[string]interface{}{"id": chatID},
+			"text":       params["text"],
+		}
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"ok": true, "result": result})
+	return body
+}
+
+// Diff compares the recorded calls with the replayed ones. It returns the
+// recorded calls missing from the replay prefixed with "-" and the new ones
+// prefixed with "+", in order, or nothing if they are the same.
+//
+// The calls are aligned on a longest common subsequence found with
+// Hirschberg's algorithm, which needs memory linear in the number of calls.
+func Diff(recorded, replayed []Call) []string {
+	a := make([]string, len(recorded))
+	for i, c := range recorded {
+		a[i] = c.String()
+	}
+	b := make([]string, len(replayed))
+	for i, c := range replayed {
+		b[i] = c.String()
+	}
+
+	var d []string
+	diff(a, b, &d)
+	return d
+}
+
+// diff appends the difference of a and b to d. Missing calls come before new
+// ones where both keep the common subsequence equally long.
+func diff(a, b []string, d *[]string) {
+	// The common prefix and suffix are part of the subsequence.
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	switch {
+	case len(a) == 0:
+		for _, s := range b {
+			*d = append(*d, "+ "+s)
+		}
+		return
+	case len(b) == 0:
+		for _, s := range a {
+			*d = append(*d, "- "+s)
+		}
+		return
+	case len(a) == 1:
+		for j, s := range b {
+			if s == a[0] {
+				diff(nil, b[:j], d)
+				diff(nil, b[j+1:], d)
+				return
+			}
+		}
+		diff(a, nil, d)
+		diff(nil, b, d)
+		return
+	}
+
+	// Split b where the halves of a keep the longest common subsequence,
+	// leaving the first half of a as little of b as possible.
+	mid := len(a) / 2
+	head := lcsLengths(a[:mid], b)
+	tail := lcsLengths(reversed(a[mid:]), reversed(b))
+	split, best := 0, -1
+	for j := 0; j <= len(b); j++ {
+		if n := head[j] + tail[len(b)-j]; n > best {
+			split, best = j, n
+		}
+	}
+
+	diff(a[:mid], b[:split], d)
+	diff(a[mid:], b[split:], d)
+}
+
+// lcsLengths returns the lengths of the longest common subsequences of a and
+// every prefix of b, indexed by the prefix length.
+func lcsLengths(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for _, s := range a {
+		for j, t := range b {
+			switch {
+			case s == t:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] >= cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+func reversed(list []string) []string {
+	r := make([]string, len(list))
+	for i, s := range list {
+		r[len(list)-1-i] = s
+	}
+	return r
+}
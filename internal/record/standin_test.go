@@ -0,0 +1,74 @@
+package record
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func calls(methods ...string) []Call {
+	var list []Call
+	for _, m := range methods {
+		list = append(list, Call{Method: m})
+	}
+	return list
+}
+
+func TestCallString(t *testing.T) {
+	c := Call{Method: "sendMessage", Params: map[string]string{"text": "hi \"you\"", "chat_id": "1"}}
+	if got, want := c.String(), `sendMessage chat_id="1" text="hi \"you\""`; got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	send := func(text string) Call {
+		return Call{Method: "sendMessage", Params: map[string]string{"chat_id": "1", "text": text}}
+	}
+
+	tests := []struct {
+		name               string
+		recorded, replayed []Call
+		want               []string
+	}{
+		{name: "empty"},
+		{name: "same", recorded: calls("a", "b"), replayed: calls("a", "b")},
+		{name: "missing", recorded: calls("a", "b", "c"), replayed: calls("a", "c"), want: []string{"- b"}},
+		{name: "new", recorded: calls("a", "c"), replayed: calls("a", "b", "c"), want: []string{"+ b"}},
+		{name: "changed", recorded: calls("a", "b"), replayed: calls("a", "c"), want: []string{"- b", "+ c"}},
+		{name: "all new", replayed: calls("a"), want: []string{"+ a"}},
+		{name: "all missing", recorded: calls("a"), want: []string{"- a"}},
+		{name: "reversed", recorded: calls("a", "b", "c"), replayed: calls("c", "b", "a"), want: []string{"- a", "- b", "+ b", "+ a"}},
+		{
+			name:     "params",
+			recorded: []Call{send("hi"), send("bye")},
+			replayed: []Call{send("hi"), send("see you")},
+			want:     []string{`- sendMessage chat_id="1" text="bye"`, `+ sendMessage chat_id="1" text="see you"`},
+		},
+	}
+	for _, tt := range tests {
+		if got := Diff(tt.recorded, tt.replayed); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Diff() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDiffLong(t *testing.T) {
+	// Every hundredth of 5000 calls is replaced.
+	var recorded, replayed []Call
+	var want []string
+	for i := 0; i < 5000; i++ {
+		c := Call{Method: "m" + strconv.Itoa(i)}
+		recorded = append(recorded, c)
+		if i%100 != 50 {
+			replayed = append(replayed, c)
+			continue
+		}
+		n := Call{Method: "n" + strconv.Itoa(i)}
+		replayed = append(replayed, n)
+		want = append(want, "- "+c.Method, "+ "+n.Method)
+	}
+	if got := Diff(recorded, replayed); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() has %d lines, want %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,150 @@
+package remind
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// remind handles /remind <when> <text>, see Parse.
+func (s *Scheduler) remind(c *bot.Context) error {
+	m := c.Update.Message
+	args := strings.TrimSpace(m.CommandArguments())
+	if args == "" {
+		return c.Reply(c.T("remind.usage"))
+	}
+
+	zone, loc, err := s.Zone(m.From.ID)
+	if err != nil {
+		return err
+	}
+
+	schedule, text, err := Parse(args, time.Now(), loc)
+	switch err {
+	case nil:
+	case errPast:
+		return c.Reply(c.T("remind.past"))
+	default:
+		return c.Reply(c.T("remind.invalid", "error", err) + "\n\n" + c.T("remind.usage"))
+	}
+
+	r := &Reminder{
+		ChatID:   m.Chat.ID,
+		UserID:   m.From.ID,
+		Name:     m.From.FirstName,
+		Text:     text,
+		Lang:     c.Lang(),
+		Zone:     zone,
+		Schedule: schedule,
+	}
+
+	if err := s.Add(r); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("remind.set", "time", r.At.In(loc).Format(timeLayout), "every", every(c, r), "id", r.ID))
+}
+
+// list handles /reminders, listing the pending reminders of the user in the chat.
+func (s *Scheduler) list(c *bot.Context) error {
+	m := c.Update.Message
+
+	list, err := s.reminders.list(func(r *Reminder) bool {
+		return r.ChatID == m.Chat.ID && r.UserID == m.From.ID && !r.Sent
+	})
+	if err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return c.Reply(c.T("remind.none"))
+	}
+
+	lines := []string{c.T("remind.list")}
+	for _, r := range list {
+		lines = append(lines, c.T("remind.item",
+			"id", r.ID, "time", r.At.In(r.Location()).Format(timeLayout), "every", every(c, r), "text", r.Text))
+	}
+
+	return c.Reply(strings.Join(lines, "\n"))
+}
+
+// unremind handles /unremind <id>.
+func (s *Scheduler) unremind(c *bot.Context) error {
+	m := c.Update.Message
+	id := strings.TrimSpace(m.CommandArguments())
+	if id == "" {
+		return c.Reply(c.T("remind.unremind_usage"))
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, err := s.reminders.get(id)
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	if r == nil || r.ChatID != m.Chat.ID || r.UserID != m.From.ID {
+		return c.Reply(c.T("remind.gone"))
+	}
+	if err := s.reminders.delete(r); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("remind.deleted"))
+}
+
+// timezone handles /timezone [zone]. Reminders created later use the zone.
+func (s *Scheduler) timezone(c *bot.Context) error {
+	m := c.Update.Message
+	arg := strings.TrimSpace(m.CommandArguments())
+
+	if arg == "" {
+		zone, loc, err := s.Zone(m.From.ID)
+		if err != nil {
+			return err
+		}
+		if zone == "" {
+			zone = "UTC"
+		}
+		return c.Reply(c.T("remind.timezone", "zone", zone, "time", time.Now().In(loc).Format(timeLayout)))
+	}
+
+	loc, err := LoadZone(arg)
+	if err != nil {
+		return c.Reply(c.T("remind.timezone_invalid", "zone", arg))
+	}
+	if err := s.bot.Store.Put(zonesBucket, strconv.Itoa(m.From.ID), loc.String()); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("remind.timezone_set", "zone", loc.String(), "time", time.Now().In(loc).Format(timeLayout)))
+}
+
+// Zone returns the name and the location of the time zone chosen by the user,
+// UTC by default.
+func (s *Scheduler) Zone(userID int) (string, *time.Location, error) {
+	var zone string
+	err := s.bot.Store.Get(zonesBucket, strconv.Itoa(userID), &zone)
+	if err != nil && err != storage.ErrNotFound {
+		return "", nil, err
+	}
+
+	loc, err := LoadZone(zone)
+	if err != nil {
+		// The zone database of the host may have changed.
+		return "", time.UTC, nil
+	}
+
+	return zone, loc, nil
+}
+
+// every describes the repetition of the reminder, or returns "" for one-off reminders.
+func every(c *bot.Context, r *Reminder) string {
+	if r.Every == "" {
+		return ""
+	}
+	return c.T("remind.every." + r.Every)
+}
@@ -0,0 +1,254 @@
+package remind
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+)
+
+// Daily is the Every value of reminders repeated every day.
+const Daily = "day"
+
+// defaultHour is the hour of reminders given a day without a time.
+const defaultHour = 9
+
+var (
+	errNoText = errors.New("the reminder text is empty")
+	errPast   = errors.New("the time has already passed")
+	errNoDay  = errors.New("the day after every is missing")
+)
+
+// Schedule is the time of a reminder.
+type Schedule struct {
+	// At is the first delivery time.
+	At time.Time
+	// Every is Daily or the lowercase English name of a weekday for
+	// repeated reminders, and empty for one-off ones.
+	Every string
+	// Hour and Minute are the wall clock time of repeated reminders.
+	Hour, Minute int
+}
+
+// days maps the day words to the weekdays. Today and tomorrow are relative
+// to the current weekday and are handled separately.
+var days = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday,
+	"wednesday": time.Wednesday, "thursday": time.Thursday, "friday": time.Friday,
+	"saturday": time.Saturday,
+	"sun":      time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+	"воскресенье": time.Sunday, "понедельник": time.Monday, "вторник": time.Tuesday,
+	"среда": time.Wednesday, "среду": time.Wednesday, "четверг": time.Thursday,
+	"пятница": time.Friday, "пятницу": time.Friday, "суббота": time.Saturday,
+	"субботу": time.Saturday,
+}
+
+// words maps the other keywords in every language to the English ones.
+var words = map[string]string{
+	"in": "in", "через": "in",
+	"every": "every", "каждый": "every", "каждую": "every", "каждое": "every",
+	"day": Daily, "день": Daily,
+	"today": "today", "сегодня": "today",
+	"tomorrow": "tomorrow", "завтра": "tomorrow",
+	"at": "at", "в": "at",
+}
+
+// Parse splits the arguments of /remind into the schedule and the reminder
+// text. Times are in the location loc. Supported forms are:
+//
+//	[in] 2h text            a duration, see moderation.ParseDuration
+//	15:30 text              the next occurrence of the time
+//	tomorrow [9:00] text    today, tomorrow or a weekday, at 9:00 by default
+//	2024-03-01 [9:00] text  a date
+//	every day|monday 10:00 text
+func Parse(args string, now time.Time, loc *time.Location) (Schedule, string, error) {
+	tokens := strings.Fields(args)
+	s, i, err := parse(tokens, now, loc)
+	if err != nil {
+		return s, "", err
+	}
+	if i >= len(tokens) {
+		return s, "", errNoText
+	}
+	return s, strings.Join(tokens[i:], " "), nil
+}
+
+// ParseTime parses a time in one of the forms of Parse without a text.
+func ParseTime(when string, now time.Time, loc *time.Location) (Schedule, error) {
+	tokens := strings.Fields(when)
+	s, i, err := parse(tokens, now, loc)
+	if err == nil && i < len(tokens) {
+		err = fmt.Errorf("unexpected %q", strings.Join(tokens[i:], " "))
+	}
+	return s, err
+}
+
+// parse parses the schedule at the start of the tokens and returns the index
+// of the first token after it.
+func parse(tokens []string, now time.Time, loc *time.Location) (Schedule, int, error) {
+	word := func(i int) string {
+		if i >= len(tokens) {
+			return ""
+		}
+		t := strings.ToLower(tokens[i])
+		if w, ok := words[t]; ok {
+			return w
+		}
+		return t
+	}
+
+	now = now.In(loc)
+	var s Schedule
+	i := 0
+
+	w := word(0)
+	_, weekday := days[w]
+
+	switch {
+	case w == "every":
+		every := word(1)
+		day, ok := days[every]
+		switch {
+		case ok:
+			every = strings.ToLower(day.String())
+		case every == "":
+			return s, 0, errNoDay
+		case every != Daily:
+			return s, 0, fmt.Errorf("unknown day %q", every)
+		}
+		i = 2
+		if word(i) == "at" {
+			i++
+		}
+		hour, minute, ok := parseClock(word(i))
+		if !ok {
+			return s, 0, fmt.Errorf("invalid time %q", word(i))
+		}
+		i++
+		s = Schedule{Every: every, Hour: hour, Minute: minute}
+		s.At = s.Next(now, loc)
+
+	case w == "today" || w == "tomorrow" || weekday:
+		day := date(now, 0)
+		switch {
+		case w == "tomorrow":
+			day = date(now, 1)
+		case w != "today":
+			// The next such weekday, a week later if it is today.
+			ahead := (int(days[w]) - int(now.Weekday()) + 6) % 7
+			day = date(now, ahead+1)
+		}
+		i = 1
+		s.At, i = withClock(day, tokens, word, i)
+
+	case isDate(w):
+		day, err := time.ParseInLocation("2006-01-02", w, loc)
+		if err != nil {
+			return s, 0, fmt.Errorf("invalid date %q", w)
+		}
+		s.At, i = withClock(day, tokens, word, 1)
+
+	default:
+		if w == "in" || w == "at" {
+			i = 1
+		}
+		if hour, minute, ok := parseClock(word(i)); ok {
+			s.At = time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, loc)
+			if !s.At.After(now) {
+				s.At = s.At.AddDate(0, 0, 1)
+			}
+		} else {
+			d, err := moderation.ParseDuration(word(i))
+			if err != nil {
+				return s, 0, err
+			}
+			s.At = now.Add(d)
+		}
+		i++
+	}
+
+	if !s.At.After(now) {
+		return s, 0, errPast
+	}
+
+	return s, i, nil
+}
+
+// Next returns the first occurrence of the repeated schedule after the time.
+func (s Schedule) Next(after time.Time, loc *time.Location) time.Time {
+	after = after.In(loc)
+	for days := 0; ; days++ {
+		t := time.Date(after.Year(), after.Month(), after.Day()+days, s.Hour, s.Minute, 0, 0, loc)
+		if !t.After(after) {
+			continue
+		}
+		if s.Every == Daily || strings.ToLower(t.Weekday().String()) == s.Every {
+			return t
+		}
+	}
+}
+
+// withClock returns the day at the time of the token i if it is one, or at
+// the default hour, and the index of the first token of the text.
+func withClock(day time.Time, tokens []string, word func(int) string, i int) (time.Time, int) {
+	if word(i) == "at" && i+1 < len(tokens) {
+		if _, _, ok := parseClock(word(i + 1)); ok {
+			i++
+		}
+	}
+	hour, minute, ok := parseClock(word(i))
+	if !ok {
+		return time.Date(day.Year(), day.Month(), day.Day(), defaultHour, 0, 0, 0, day.Location()), i
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, day.Location()), i + 1
+}
+
+// date returns the midnight days after the day of t.
+func date(t time.Time, days int) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+days, 0, 0, 0, 0, t.Location())
+}
+
+// parseClock parses times like 9:00, 21:30 and 9am.
+func parseClock(s string) (hour, minute int, ok bool) {
+	suffix := ""
+	if strings.HasSuffix(s, "am") || strings.HasSuffix(s, "pm") {
+		s, suffix = s[:len(s)-2], s[len(s)-2:]
+		if !strings.Contains(s, ":") {
+			s += ":00"
+		}
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[1]) != 2 {
+		return 0, 0, false
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minute, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if suffix != "" {
+		if hour < 1 || hour > 12 {
+			return 0, 0, false
+		}
+		// 12am is midnight and 12pm is noon.
+		hour %= 12
+		if suffix == "pm" {
+			hour += 12
+		}
+	}
+
+	return hour, minute, hour >= 0 && hour < 24 && minute >= 0 && minute < 60
+}
+
+func isDate(s string) bool {
+	return len(s) == len("2006-01-02") && s[4] == '-' && s[7] == '-'
+}
@@ -0,0 +1,103 @@
+package remind
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	// A Wednesday.
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 3, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		args  string
+		at    time.Time
+		every string
+		text  string
+		err   bool
+	}{
+		{args: "2h call mom", at: now.Add(2 * time.Hour), text: "call mom"},
+		{args: "in 30m tea", at: now.Add(30 * time.Minute), text: "tea"},
+		{args: "через 1d отчёт", at: now.Add(24 * time.Hour), text: "отчёт"},
+		{args: "15:30 meeting", at: at(6, 15, 30), text: "meeting"},
+		{args: "at 9:00 standup", at: at(7, 9, 0), text: "standup"},
+		{args: "9am run", at: at(7, 9, 0), text: "run"},
+		{args: "12pm lunch", at: at(7, 12, 0), text: "lunch"},
+		{args: "12am sleep", at: at(7, 0, 0), text: "sleep"},
+		{args: "tomorrow call", at: at(7, defaultHour, 0), text: "call"},
+		{args: "Tomorrow 18:00 call", at: at(7, 18, 0), text: "call"},
+		{args: "завтра в 10:00 позвонить", at: at(7, 10, 0), text: "позвонить"},
+		{args: "today 18:00 gym", at: at(6, 18, 0), text: "gym"},
+		{args: "friday report", at: at(8, defaultHour, 0), text: "report"},
+		{args: "fri at 17:00 report", at: at(8, 17, 0), text: "report"},
+		{args: "wednesday review", at: at(13, defaultHour, 0), text: "review"},
+		{args: "2024-03-10 10:00 trip", at: at(10, 10, 0), text: "trip"},
+		{args: "2024-03-10 trip", at: at(10, defaultHour, 0), text: "trip"},
+		{args: "every day 10:00 pills", at: at(7, 10, 0), every: Daily, text: "pills"},
+		{args: "every monday at 8:00 plan", at: at(11, 8, 0), every: "monday", text: "plan"},
+		{args: "каждую пятницу 18:00 пятница!", at: at(8, 18, 0), every: "friday", text: "пятница!"},
+
+		{args: "", err: true},
+		{args: "2h", err: true},
+		{args: "today 8:00 missed", err: true},
+		{args: "2024-03-01 past", err: true},
+		{args: "2024-13-01 x", err: true},
+		{args: "every", err: true},
+		{args: "every day", err: true},
+		{args: "every day noon x", err: true},
+		{args: "every someday 10:00 x", err: true},
+		{args: "soon x", err: true},
+		{args: "25:00 x", err: true},
+		{args: "13pm x", err: true},
+		{args: "9:7 x", err: true},
+		{args: "0s x", err: true},
+	}
+	for _, tt := range tests {
+		s, text, err := Parse(tt.args, now, time.UTC)
+		if tt.err {
+			if err == nil {
+				t.Errorf("Parse(%q) = %+v, %q, want an error", tt.args, s, text)
+			}
+			continue
+		}
+		if err != nil || !s.At.Equal(tt.at) || s.Every != tt.every || text != tt.text {
+			t.Errorf("Parse(%q) = %v every %q, %q, %v; want %v every %q, %q", tt.args, s.At, s.Every, text, err, tt.at, tt.every, tt.text)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 6, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		when string
+		err  bool
+	}{
+		{when: "tomorrow 10:00"},
+		{when: "2h"},
+		{when: "every day 9:00"},
+		{when: "every", err: true},
+		{when: "tomorrow 10:00 extra", err: true},
+		{when: "", err: true},
+	}
+	for _, tt := range tests {
+		if _, err := ParseTime(tt.when, now, time.UTC); (err != nil) != tt.err {
+			t.Errorf("ParseTime(%q) error = %v, want error %t", tt.when, err, tt.err)
+		}
+	}
+}
+
+func TestNextAcrossDST(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip(err)
+	}
+	// Clocks go forward on 31 March 2024.
+	s := Schedule{Every: Daily, Hour: 9}
+	got := s.Next(time.Date(2024, 3, 30, 10, 0, 0, 0, loc), loc)
+	if want := time.Date(2024, 3, 31, 9, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+}
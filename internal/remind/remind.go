@@ -0,0 +1,312 @@
+// Package remind implements reminders: messages the bot sends at the time
+// the user asked for with /remind, once or repeatedly.
+package remind
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+	"github.com/nskondratev/go-telegram-bot-example/internal/tgerr"
+)
+
+const (
+	// checkInterval is how often due reminders are looked up.
+	checkInterval = 15 * time.Second
+	// lateAfter is the delay after which a reminder is marked late, for
+	// example when the bot was down at the scheduled time.
+	lateAfter = 2 * time.Minute
+	// sentTTL is how long delivered reminders can be snoozed.
+	sentTTL = 7 * 24 * time.Hour
+	// callbackPrefix starts the data of the reminder buttons.
+	callbackPrefix = "remind:"
+	// timeLayout formats the reminder times.
+	timeLayout = "Mon, 02 Jan 15:04 MST"
+)
+
+// snoozes are the durations of the snooze buttons.
+var snoozes = []string{"10m", "1h", "1d"}
+
+// Scheduler stores the reminders and sends them when they are due.
+type Scheduler struct {
+	bot       *bot.Bot
+	reminders reminders
+
+	// mu serializes the changes of the reminders. Messages are sent outside
+	// of it, so button presses don't wait for the flood limits.
+	mu sync.Mutex
+}
+
+// New creates a reminder scheduler.
+func New(b *bot.Bot) *Scheduler {
+	return &Scheduler{bot: b, reminders: reminders{store: b.Store}}
+}
+
+// Register adds the reminder commands and the handler of the reminder buttons to the router.
+func (s *Scheduler) Register(r *bot.Router) {
+	r.Command("remind", s.remind)
+	r.Command("reminders", s.list)
+	r.Command("unremind", s.unremind)
+	r.Command("timezone", s.timezone)
+	r.Handle(bot.IsCallback(callbackPrefix), s.press)
+}
+
+// Add schedules the reminder, assigning it an ID.
+func (s *Scheduler) Add(r *Reminder) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r.ID = newReminderID(time.Now())
+	return s.reminders.put(r)
+}
+
+// Cancel deletes the reminder with the ID. Cancelling a missing reminder is not an error.
+func (s *Scheduler) Cancel(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reminders.delete(&Reminder{ID: id})
+}
+
+// Run sends the due reminders until the context is cancelled. Reminders
+// missed while the bot was stopped are sent on start.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	for {
+		s.deliver(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) deliver(now time.Time) {
+	s.mu.Lock()
+	list, err := s.reminders.list(func(r *Reminder) bool { return !r.Sent || now.Sub(r.At) > sentTTL })
+	s.mu.Unlock()
+	if err != nil {
+		log.Printf("Failed to load reminders: %s", err)
+		return
+	}
+
+	for _, r := range list {
+		if r.At.After(now) {
+			break
+		}
+		if r.Sent {
+			s.mu.Lock()
+			err := s.reminders.delete(r)
+			s.mu.Unlock()
+			if err != nil {
+				log.Printf("Failed to delete reminder %s: %s", r.ID, err)
+			}
+			continue
+		}
+		if err := s.send(r, now); err != nil {
+			log.Printf("Failed to send reminder %s to chat %d: %s", r.ID, r.ChatID, err)
+		}
+	}
+}
+
+// send delivers the reminder and schedules its next occurrence. Reminders
+// the API rejects for good, for example because the chat is gone, are
+// dropped, and those of a group upgraded to a supergroup follow it.
+func (s *Scheduler) send(r *Reminder, now time.Time) error {
+	msg, err := s.message(r, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.bot.Sender.Send(msg)
+	if to := tgerr.MigratedTo(err); to != 0 {
+		if err := s.migrate(r.ChatID, to); err != nil {
+			return err
+		}
+		msg.ChatID, r.ChatID = to, to
+		_, err = s.bot.Sender.Send(msg)
+	}
+
+	return s.sent(r, now, err)
+}
+
+// sent stores the outcome of sending the reminder, unless it was changed or
+// cancelled while it was being sent.
+func (s *Scheduler) sent(r *Reminder, now time.Time, err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cur, getErr := s.reminders.get(r.ID)
+	if getErr == storage.ErrNotFound || (getErr == nil && (!cur.At.Equal(r.At) || cur.Sent)) {
+		return err
+	}
+	if getErr != nil {
+		return getErr
+	}
+
+	switch {
+	case tgerr.IsPermanent(err):
+		log.Printf("Dropping reminder %s: %s", r.ID, err)
+		return s.reminders.delete(cur)
+	case err != nil:
+		// Retried with the next check.
+		return err
+	case cur.Every != "":
+		// Missed occurrences are sent once.
+		cur.At = cur.Next(now, cur.Location())
+	default:
+		cur.Sent = true
+	}
+
+	return s.reminders.put(cur)
+}
+
+// migrate moves the reminders of a group upgraded to a supergroup to its new ID.
+func (s *Scheduler) migrate(from, to int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	list, err := s.reminders.list(func(r *Reminder) bool { return r.ChatID == from })
+	if err != nil {
+		return err
+	}
+	for _, r := range list {
+		r.ChatID = to
+		if err := s.reminders.put(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Scheduler) message(r *Reminder, now time.Time) (tgbotapi.MessageConfig, error) {
+	key := "remind.due"
+	if now.Sub(r.At) > lateAfter {
+		key = "remind.due_late"
+	}
+
+	text, err := render.Render(tgbotapi.ModeHTML, s.bot.I18n.Translate(r.Lang, key), map[string]interface{}{
+		"User":  &tgbotapi.User{ID: r.UserID, FirstName: r.Name},
+		"Text":  r.Text,
+		"Time":  r.At.In(r.Location()).Format(timeLayout),
+		"Group": r.ChatID < 0,
+	})
+	if err != nil {
+		return tgbotapi.MessageConfig{}, err
+	}
+
+	msg := tgbotapi.NewMessage(r.ChatID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	msg.ReplyMarkup = s.keyboard(r)
+
+	return msg, nil
+}
+
+func (s *Scheduler) keyboard(r *Reminder) tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	for _, d := range snoozes {
+		label := s.bot.I18n.Translate(r.Lang, "remind.snooze", "duration", d)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, callbackPrefix+"snooze:"+d+":"+r.ID))
+	}
+	done := s.bot.I18n.Translate(r.Lang, "remind.done_button")
+	row = append(row, tgbotapi.NewInlineKeyboardButtonData(done, callbackPrefix+"done::"+r.ID))
+
+	return tgbotapi.NewInlineKeyboardMarkup(row)
+}
+
+// press handles the snooze and done buttons. Only the author of the reminder can press them.
+func (s *Scheduler) press(c *bot.Context) error {
+	q := c.Update.CallbackQuery
+	m := q.Message
+	parts := strings.SplitN(strings.TrimPrefix(q.Data, callbackPrefix), ":", 3)
+	if m == nil || len(parts) != 3 {
+		return c.Answer("", false)
+	}
+	action, arg, id := parts[0], parts[1], parts[2]
+
+	var (
+		answer        string
+		alert, remove bool
+	)
+	err := func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		r, err := s.reminders.get(id)
+		if err == storage.ErrNotFound {
+			answer, remove = c.T("remind.gone"), true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if q.From.ID != r.UserID {
+			answer, alert = c.T("remind.not_yours"), true
+			return nil
+		}
+
+		switch action {
+		case "snooze":
+			d, err := moderation.ParseDuration(arg)
+			if err != nil {
+				return nil
+			}
+			if r.Every != "" {
+				// The repeated reminder keeps its schedule, a one-off copy is snoozed.
+				snoozed := *r
+				snoozed.ID = newReminderID(time.Now())
+				snoozed.Schedule = Schedule{}
+				r = &snoozed
+			}
+			r.At, r.Sent = time.Now().Add(d), false
+			if err := s.reminders.put(r); err != nil {
+				return err
+			}
+			answer = c.T("remind.snoozed", "time", r.At.In(r.Location()).Format(timeLayout))
+		case "done":
+			if r.Every == "" {
+				if err := s.reminders.delete(r); err != nil {
+					return err
+				}
+			}
+			answer = c.T("remind.done")
+		default:
+			return nil
+		}
+		remove = true
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	// The buttons are removed outside of the lock, since the edit waits for
+	// the flood limits of the chat.
+	if remove {
+		s.closeButtons(m)
+	}
+
+	return c.Answer(answer, alert)
+}
+
+// closeButtons removes the buttons from the reminder message.
+func (s *Scheduler) closeButtons(m *tgbotapi.Message) {
+	edit := tgbotapi.NewEditMessageReplyMarkup(m.Chat.ID, m.MessageID, tgbotapi.InlineKeyboardMarkup{
+		InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{},
+	})
+	if _, err := s.bot.Sender.Send(edit); err != nil {
+		log.Printf("Failed to remove the buttons of message %d in chat %d: %s", m.MessageID, m.Chat.ID, err)
+	}
+}
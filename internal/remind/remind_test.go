@@ -0,0 +1,99 @@
+package remind
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// chatSender fails the messages to the chats with errors.
+type chatSender struct {
+	errs  map[int64]error
+	chats []int64
+}
+
+func (s *chatSender) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	chatID := c.(tgbotapi.MessageConfig).ChatID
+	s.chats = append(s.chats, chatID)
+	return tgbotapi.Message{}, s.errs[chatID]
+}
+
+func TestDeliverErrors(t *testing.T) {
+	migrated := tgbotapi.Error{
+		Message:            "Bad Request: group chat was upgraded to a supergroup chat",
+		ResponseParameters: tgbotapi.ResponseParameters{MigrateToChatID: -1002},
+	}
+	tests := []struct {
+		name   string
+		err    error
+		chats  []int64
+		kept   bool
+		sent   bool
+		chatID int64
+	}{
+		{"sent", nil, []int64{-1}, true, true, -1},
+		{"chat not found", tgbotapi.Error{Message: "Bad Request: chat not found"}, []int64{-1}, false, false, 0},
+		{"blocked", tgbotapi.Error{Message: "Forbidden: bot was kicked from the group chat"}, []int64{-1}, false, false, 0},
+		{"flood control", tgbotapi.Error{Message: "Too Many Requests: retry after 5", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}}, []int64{-1}, true, false, -1},
+		{"network", errors.New("connection reset"), []int64{-1}, true, false, -1},
+		{"upgraded group", migrated, []int64{-1, -1002}, true, true, -1002},
+	}
+
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := bot.New(&tgbotapi.BotAPI{}, storage.NewMemory(), bundle)
+			sender := &chatSender{errs: map[int64]error{-1: tt.err}}
+			b.Sender = sender
+			s := New(b)
+
+			now := time.Now()
+			due := &Reminder{ChatID: -1, UserID: 7, Text: "standup", Lang: "en", Schedule: Schedule{At: now.Add(-time.Second)}}
+			other := &Reminder{ChatID: -1, UserID: 7, Text: "later", Lang: "en", Schedule: Schedule{At: now.Add(time.Hour)}}
+			for _, r := range []*Reminder{due, other} {
+				if err := s.Add(r); err != nil {
+					t.Fatal(err)
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			s.deliver(now)
+
+			if len(sender.chats) != len(tt.chats) || (len(tt.chats) > 1 && sender.chats[1] != tt.chats[1]) {
+				t.Errorf("sent to %v, want %v", sender.chats, tt.chats)
+			}
+			r, err := s.reminders.get(due.ID)
+			if !tt.kept {
+				if err != storage.ErrNotFound {
+					t.Errorf("reminder kept after %v", tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Sent != tt.sent || r.ChatID != tt.chatID {
+				t.Errorf("reminder is sent %v to chat %d, want %v to %d", r.Sent, r.ChatID, tt.sent, tt.chatID)
+			}
+
+			// The other reminders of an upgraded group follow it.
+			o, err := s.reminders.get(other.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o.ChatID != tt.chatID {
+				t.Errorf("other reminder is in chat %d, want %d", o.ChatID, tt.chatID)
+			}
+		})
+	}
+}
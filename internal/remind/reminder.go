@@ -0,0 +1,118 @@
+package remind
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	// remindersBucket stores the reminders by ID.
+	remindersBucket = "reminders"
+	// zonesBucket stores the time zones chosen by the users.
+	zonesBucket = "timezones"
+)
+
+// Reminder is a message the bot sends to a chat at the scheduled time.
+type Reminder struct {
+	ID     string `json:"id"`
+	ChatID int64  `json:"chat_id"`
+	UserID int    `json:"user_id"`
+	// Name is the first name of the user, used to mention them in groups.
+	Name string `json:"name"`
+	Text string `json:"text"`
+	// Lang is the language of the messages around the text.
+	Lang string `json:"lang"`
+	// Zone is the time zone of the user when the reminder was created.
+	Zone string `json:"zone"`
+
+	Schedule
+	// Sent is set once a one-off reminder is delivered. It is kept for the
+	// snooze button until it is done or stale.
+	Sent bool `json:"sent,omitempty"`
+}
+
+// Location returns the time zone of the reminder.
+func (r *Reminder) Location() *time.Location {
+	loc, err := LoadZone(r.Zone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+type reminders struct {
+	store storage.Store
+}
+
+func (s reminders) get(id string) (*Reminder, error) {
+	var r Reminder
+	if err := s.store.Get(remindersBucket, id, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s reminders) put(r *Reminder) error {
+	return s.store.Put(remindersBucket, r.ID, r)
+}
+
+func (s reminders) delete(r *Reminder) error {
+	return s.store.Delete(remindersBucket, r.ID)
+}
+
+// list returns the reminders matching the filter ordered by time.
+func (s reminders) list(match func(r *Reminder) bool) ([]*Reminder, error) {
+	keys, err := s.store.Keys(remindersBucket)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []*Reminder
+	for _, k := range keys {
+		r, err := s.get(k)
+		if err != nil {
+			return nil, err
+		}
+		if match(r) {
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].At.Before(list[j].At) })
+
+	return list, nil
+}
+
+func newReminderID(now time.Time) string {
+	return strconv.FormatInt(now.UnixNano()/int64(time.Microsecond), 36)
+}
+
+// LoadZone returns the time zone with the IANA name, like Europe/Berlin, or
+// the UTC offset, like +3, UTC-5 or +05:30. An empty name is UTC.
+func LoadZone(name string) (*time.Location, error) {
+	offset := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(name), "UTC"), "GMT")
+	if offset == "" || offset[0] != '+' && offset[0] != '-' {
+		return time.LoadLocation(name)
+	}
+
+	sign := 1
+	if offset[0] == '-' {
+		sign = -1
+	}
+	parts := strings.SplitN(offset[1:], ":", 2)
+	hours, err := strconv.Atoi(parts[0])
+	minutes := 0
+	if err == nil && len(parts) == 2 {
+		minutes, err = strconv.Atoi(parts[1])
+	}
+	if err != nil || hours < 0 || hours > 14 || minutes < 0 || minutes >= 60 {
+		return nil, fmt.Errorf("invalid UTC offset %q", name)
+	}
+
+	sec := sign * (hours*3600 + minutes*60)
+	return time.FixedZone(fmt.Sprintf("UTC%c%02d:%02d", offset[0], hours, minutes), sec), nil
+}
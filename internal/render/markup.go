@@ -0,0 +1,336 @@
+package render
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// htmlTags lists the tags supported by the HTML parse mode.
+var htmlTags = map[string]bool{
+	"a": true, "b": true, "strong": true, "i": true, "em": true, "u": true, "ins": true,
+	"s": true, "strike": true, "del": true, "code": true, "pre": true,
+}
+
+// markup is an entity open at some position of the text.
+type markup struct {
+	name  string
+	open  string
+	close string
+}
+
+// state is the stack of open entities, outermost first. It is never modified
+// in place, so boundaries can share it.
+type state []markup
+
+func (s state) push(m markup) state {
+	next := make(state, len(s), len(s)+1)
+	copy(next, s)
+	return append(next, m)
+}
+
+func (s state) pop() state {
+	return s[: len(s)-1 : len(s)-1]
+}
+
+func (s state) top() *markup {
+	if len(s) == 0 {
+		return nil
+	}
+	return &s[len(s)-1]
+}
+
+// opening returns the markup reopening all entities.
+func (s state) opening() string {
+	var sb strings.Builder
+	for _, m := range s {
+		sb.WriteString(m.open)
+	}
+	return sb.String()
+}
+
+// closing returns the markup closing all entities.
+func (s state) closing() string {
+	var sb strings.Builder
+	for i := len(s) - 1; i >= 0; i-- {
+		sb.WriteString(s[i].close)
+	}
+	return sb.String()
+}
+
+// Break levels of a boundary, higher levels are preferred for splitting.
+const (
+	breakNone = iota
+	breakWord
+	breakLine
+	breakParagraph
+)
+
+// boundary is a position in the text between two characters. There are no
+// boundaries inside tags, links and escape sequences.
+type boundary struct {
+	pos   int  // byte offset
+	units int  // UTF-16 code units before pos
+	safe  bool // false between the characters of a grapheme cluster
+	level int
+	st    state
+}
+
+// MarkupError reports malformed markup.
+type MarkupError struct {
+	Mode   string
+	Offset int
+	Reason string
+}
+
+func (e *MarkupError) Error() string {
+	return fmt.Sprintf("render: invalid %s markup at byte %d: %s", e.Mode, e.Offset, e.Reason)
+}
+
+// Validate checks that every entity in text is well-formed and closed,
+// so Telegram will accept the text with the given parse mode.
+func Validate(text, mode string) error {
+	_, err := scan(text, mode)
+	return err
+}
+
+// Length returns the length of s as counted by Telegram, in UTF-16 code units.
+func Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Len(r)
+	}
+	return n
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// scan returns all boundaries of text including its end.
+func scan(text, mode string) ([]boundary, error) {
+	s := scanner{text: text, mode: mode}
+	var err error
+	switch mode {
+	case tgbotapi.ModeHTML:
+		err = s.html()
+	case tgbotapi.ModeMarkdown:
+		err = s.markdown()
+	default:
+		s.plain()
+	}
+	return s.bounds, err
+}
+
+type scanner struct {
+	text   string
+	mode   string
+	bounds []boundary
+
+	pos   int
+	units int
+	prev  rune
+	st    state
+}
+
+func (s *scanner) fail(reason string, args ...interface{}) error {
+	return &MarkupError{Mode: s.mode, Offset: s.pos, Reason: fmt.Sprintf(reason, args...)}
+}
+
+// mark records the boundary at the current position.
+func (s *scanner) mark() {
+	r, _ := utf8.DecodeRuneInString(s.text[s.pos:])
+	b := boundary{pos: s.pos, units: s.units, safe: !joins(s.prev, r), st: s.st}
+	switch rest := s.text[s.pos:]; {
+	case strings.HasPrefix(rest, "\n\n"):
+		b.level = breakParagraph
+	case strings.HasPrefix(rest, "\n"):
+		b.level = breakLine
+	case strings.HasPrefix(rest, " "), strings.HasPrefix(rest, "\t"):
+		b.level = breakWord
+	}
+	s.bounds = append(s.bounds, b)
+}
+
+// next advances over one character.
+func (s *scanner) next() {
+	r, size := utf8.DecodeRuneInString(s.text[s.pos:])
+	s.pos += size
+	s.units += utf16Len(r)
+	s.prev = r
+}
+
+// skip advances over n bytes without recording boundaries inside them.
+func (s *scanner) skip(n int) {
+	end := s.pos + n
+	for s.pos < end {
+		s.next()
+	}
+}
+
+func (s *scanner) plain() {
+	for s.pos < len(s.text) {
+		s.mark()
+		s.next()
+	}
+	s.mark()
+}
+
+func (s *scanner) html() error {
+	for s.pos < len(s.text) {
+		s.mark()
+
+		switch s.text[s.pos] {
+		case '<':
+			end := strings.IndexByte(s.text[s.pos:], '>')
+			if end == -1 {
+				return s.fail("unclosed tag")
+			}
+			if err := s.tag(s.text[s.pos+1 : s.pos+end]); err != nil {
+				return err
+			}
+			s.skip(end + 1)
+		case '&':
+			end := strings.IndexByte(s.text[s.pos:], ';')
+			if end == -1 || !validEntity(s.text[s.pos+1:s.pos+end]) {
+				return s.fail("unescaped &")
+			}
+			s.skip(end + 1)
+		case '>':
+			return s.fail("unescaped >")
+		default:
+			s.next()
+		}
+	}
+
+	s.mark()
+	if top := s.st.top(); top != nil {
+		return s.fail("unclosed <%s>", top.name)
+	}
+
+	return nil
+}
+
+func (s *scanner) tag(tag string) error {
+	if strings.HasPrefix(tag, "/") {
+		name := strings.ToLower(strings.TrimSpace(tag[1:]))
+		top := s.st.top()
+		if top == nil || top.name != name {
+			return s.fail("unexpected </%s>", name)
+		}
+		s.st = s.st.pop()
+		return nil
+	}
+
+	name := strings.ToLower(tag)
+	if i := strings.IndexFunc(tag, unicode.IsSpace); i != -1 {
+		name = strings.ToLower(tag[:i])
+	}
+	if !htmlTags[name] {
+		return s.fail("unsupported tag <%s>", name)
+	}
+	if name == "a" && !strings.Contains(tag, "href=") {
+		return s.fail("<a> without href")
+	}
+
+	s.st = s.st.push(markup{name: name, open: "<" + tag + ">", close: "</" + name + ">"})
+
+	return nil
+}
+
+// validEntity reports whether name is an HTML entity supported by Telegram.
+func validEntity(name string) bool {
+	switch name {
+	case "lt", "gt", "amp", "quot":
+		return true
+	}
+	if len(name) < 2 || name[0] != '#' {
+		return false
+	}
+
+	digits := name[1:]
+	isDigit := func(r rune) bool { return r >= '0' && r <= '9' }
+	if digits[0] == 'x' || digits[0] == 'X' {
+		digits = digits[1:]
+		isDigit = func(r rune) bool { return unicode.Is(unicode.ASCII_Hex_Digit, r) }
+	}
+
+	return digits != "" && strings.IndexFunc(digits, func(r rune) bool { return !isDigit(r) }) == -1
+}
+
+// markdown scans the legacy Markdown syntax, where entities cannot be nested.
+func (s *scanner) markdown() error {
+	for s.pos < len(s.text) {
+		rest := s.text[s.pos:]
+
+		if top := s.st.top(); top != nil {
+			s.mark()
+			if strings.HasPrefix(rest, top.close) {
+				s.st = s.st.pop()
+				s.skip(len(top.close))
+				continue
+			}
+			s.next()
+			continue
+		}
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte("_*`[", rest[1]) != -1:
+			s.mark()
+			s.skip(2)
+		case strings.HasPrefix(rest, "```"):
+			s.mark()
+			s.st = s.st.push(markup{name: "pre", open: "```\n", close: "```"})
+			s.skip(3)
+		case rest[0] == '`', rest[0] == '*', rest[0] == '_':
+			s.mark()
+			d := rest[:1]
+			s.st = s.st.push(markup{name: d, open: d, close: d})
+			s.skip(1)
+		case rest[0] == '[':
+			s.mark()
+			text := strings.Index(rest, "](")
+			if text == -1 {
+				return s.fail("unclosed link")
+			}
+			end := strings.IndexByte(rest[text:], ')')
+			if end == -1 {
+				return s.fail("unclosed link URL")
+			}
+			s.skip(text + end + 1)
+		default:
+			s.mark()
+			s.next()
+		}
+	}
+
+	s.mark()
+	if top := s.st.top(); top != nil {
+		return s.fail("unclosed %s", top.name)
+	}
+
+	return nil
+}
+
+// joins reports whether the characters form a single grapheme together,
+// so the text must not be split between them.
+func joins(prev, r rune) bool {
+	const zwj = '\u200d'
+	switch {
+	case prev == zwj, r == zwj:
+		return true
+	case unicode.Is(unicode.Mn, r), unicode.Is(unicode.Me, r):
+		return true
+	case r >= 0xfe00 && r <= 0xfe0f: // variation selectors
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff: // emoji skin tone modifiers
+		return true
+	}
+	return false
+}
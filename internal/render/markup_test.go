@@ -0,0 +1,67 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		mode, text string
+		// offset is the byte offset of the error, -1 if the text is valid.
+		offset int
+	}{
+		{tgbotapi.ModeHTML, `<b>bold <i>both</i></b> <a href="https://e.com">link</a>`, -1},
+		{tgbotapi.ModeHTML, "&lt;&gt;&amp;&quot;&#39;&#x1F600;", -1},
+		{tgbotapi.ModeHTML, "<B>x</b>", -1},
+		{tgbotapi.ModeHTML, "<b>x", 4},
+		{tgbotapi.ModeHTML, "x</b>", 1},
+		{tgbotapi.ModeHTML, "<b><i>x</b></i>", 7},
+		{tgbotapi.ModeHTML, "<span>x</span>", 0},
+		{tgbotapi.ModeHTML, "<a>x</a>", 0},
+		{tgbotapi.ModeHTML, "a <b", 2},
+		{tgbotapi.ModeHTML, "a & b", 2},
+		{tgbotapi.ModeHTML, "&nbsp;", 0},
+		{tgbotapi.ModeHTML, "&#xZ;", 0},
+		{tgbotapi.ModeHTML, "a > b", 2},
+		{tgbotapi.ModeMarkdown, "*bold* _it_ `code` [link](https://e.com) \\*", -1},
+		{tgbotapi.ModeMarkdown, "```\npre *x*\n```", -1},
+		{tgbotapi.ModeMarkdown, "*bold", 5},
+		{tgbotapi.ModeMarkdown, "```pre", 6},
+		{tgbotapi.ModeMarkdown, "[link", 0},
+		{tgbotapi.ModeMarkdown, "[link](https://e.com", 0},
+		{ModePlain, "<b>*x", -1},
+	}
+	for _, tt := range tests {
+		err := Validate(tt.text, tt.mode)
+		if tt.offset == -1 {
+			if err != nil {
+				t.Errorf("Validate(%q, %q) = %v", tt.text, tt.mode, err)
+			}
+			continue
+		}
+		e, ok := err.(*MarkupError)
+		if !ok || e.Offset != tt.offset {
+			t.Errorf("Validate(%q, %q) = %v, want an error at byte %d", tt.text, tt.mode, err, tt.offset)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"привет", 6},
+		{"😀", 2},
+		{"👨‍👩‍👧", 8},
+	}
+	for _, tt := range tests {
+		if got := Length(tt.s); got != tt.want {
+			t.Errorf("Length(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
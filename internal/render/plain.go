@@ -0,0 +1,86 @@
+package render
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+var (
+	htmlLinkRe = regexp.MustCompile(`(?s)<a\s+href="([^"]*)"\s*>(.*?)</a>`)
+	htmlTagRe  = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Plain converts formatted text into the text Telegram would display,
+// e.g. to put it into a text file. Link URLs are kept in parentheses after
+// the link text. Invalid markup is returned unchanged.
+func Plain(text, mode string) string {
+	if Validate(text, mode) != nil {
+		return text
+	}
+
+	switch mode {
+	case tgbotapi.ModeHTML:
+		text = htmlLinkRe.ReplaceAllStringFunc(text, func(link string) string {
+			m := htmlLinkRe.FindStringSubmatch(link)
+			return linkText(html.UnescapeString(m[2]), html.UnescapeString(m[1]))
+		})
+		return html.UnescapeString(htmlTagRe.ReplaceAllString(text, ""))
+	case tgbotapi.ModeMarkdown:
+		return plainMarkdown(text)
+	}
+
+	return text
+}
+
+func plainMarkdown(text string) string {
+	var sb strings.Builder
+	var open string // delimiter of the open entity
+
+	for i := 0; i < len(text); {
+		rest := text[i:]
+
+		if open != "" {
+			if strings.HasPrefix(rest, open) {
+				i += len(open)
+				open = ""
+				continue
+			}
+			sb.WriteByte(text[i])
+			i++
+			continue
+		}
+
+		switch {
+		case rest[0] == '\\' && len(rest) > 1 && strings.IndexByte("_*`[", rest[1]) != -1:
+			sb.WriteByte(rest[1])
+			i += 2
+		case strings.HasPrefix(rest, "```"):
+			open = "```"
+			i += 3
+		case rest[0] == '`', rest[0] == '*', rest[0] == '_':
+			open = rest[:1]
+			i++
+		case rest[0] == '[':
+			// The link is complete, Validate checked it.
+			textEnd := strings.Index(rest, "](")
+			urlEnd := textEnd + strings.IndexByte(rest[textEnd:], ')')
+			sb.WriteString(linkText(rest[1:textEnd], rest[textEnd+2:urlEnd]))
+			i += urlEnd + 1
+		default:
+			sb.WriteByte(text[i])
+			i++
+		}
+	}
+
+	return sb.String()
+}
+
+func linkText(text, url string) string {
+	if text == url || strings.HasPrefix(url, "tg://") {
+		return text
+	}
+	return text + " (" + url + ")"
+}
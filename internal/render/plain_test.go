@@ -0,0 +1,29 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestPlain(t *testing.T) {
+	tests := []struct {
+		mode, text, want string
+	}{
+		{tgbotapi.ModeHTML, "<b>bold</b> &lt;x&gt; &amp; <code>a&quot;b</code>", `bold <x> & a"b`},
+		{tgbotapi.ModeHTML, `<a href="https://e.com/?a=1&amp;b=2">site</a>`, "site (https://e.com/?a=1&b=2)"},
+		{tgbotapi.ModeHTML, `<a href="https://e.com">https://e.com</a>`, "https://e.com"},
+		{tgbotapi.ModeHTML, `<a href="tg://user?id=1">Ann</a>`, "Ann"},
+		{tgbotapi.ModeHTML, "<b>unclosed", "<b>unclosed"},
+		{tgbotapi.ModeMarkdown, "*bold* _it_ `co_de` \\*x\\_", "bold it co_de *x_"},
+		{tgbotapi.ModeMarkdown, "```\npre *x*\n```", "\npre *x*\n"},
+		{tgbotapi.ModeMarkdown, "[site](https://e.com) [Ann](tg://user?id=1)", "site (https://e.com) Ann"},
+		{tgbotapi.ModeMarkdown, "*unclosed", "*unclosed"},
+		{ModePlain, "<b>*x*</b>", "<b>*x*</b>"},
+	}
+	for _, tt := range tests {
+		if got := Plain(tt.text, tt.mode); got != tt.want {
+			t.Errorf("Plain(%q, %q) = %q, want %q", tt.text, tt.mode, got, tt.want)
+		}
+	}
+}
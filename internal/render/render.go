@@ -0,0 +1,322 @@
+// Package render produces message text for the Telegram parse modes.
+//
+// Templates use the text/template syntax. The output of every action is escaped
+// for the parse mode of the template, so user input can be put into Markdown
+// or HTML messages without breaking the markup:
+//
+//	t, err := render.Parse(tgbotapi.ModeHTML, "welcome", "Hi, {{mention .User}}! Welcome to {{bold .Title}}.")
+//
+// Values produced by the formatting functions (bold, italic, code, pre, link,
+// mention) are already escaped and are not escaped again. Use raw to insert
+// markup verbatim.
+//
+// Templates written by users, which could loop or allocate without bounds
+// with the full syntax, are parsed with ParseUntrusted.
+package render
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Telegram message size limits in UTF-16 code units.
+const (
+	MaxMessageLength = 4096
+	MaxCaptionLength = 1024
+)
+
+// ModePlain is the parse mode of text without markup.
+const ModePlain = ""
+
+// Safe is text which is valid markup for the parse mode it was produced for.
+// It is not escaped by templates.
+type Safe string
+
+// Escape escapes s so it is displayed verbatim in the given parse mode.
+func Escape(mode, s string) string {
+	switch mode {
+	case tgbotapi.ModeMarkdown:
+		return markdownEscaper.Replace(s)
+	case tgbotapi.ModeHTML:
+		return htmlEscaper.Replace(s)
+	}
+	return s
+}
+
+var (
+	markdownEscaper = strings.NewReplacer(`_`, `\_`, `*`, `\*`, "`", "\\`", `[`, `\[`)
+	htmlEscaper     = strings.NewReplacer(`&`, `&amp;`, `<`, `&lt;`, `>`, `&gt;`, `"`, `&quot;`)
+)
+
+func escapeHTML(s string) string {
+	return htmlEscaper.Replace(s)
+}
+
+// maxOutput caps the output of templates in bytes, far above the length of
+// the messages they are meant for.
+const maxOutput = 1 << 20
+
+// errTooLong stops the execution of templates producing too much output.
+var errTooLong = errors.New("render: template output is too long")
+
+// Template is a message template bound to a parse mode.
+type Template struct {
+	mode string
+	t    *template.Template
+	// limit caps the output in bytes.
+	limit int
+}
+
+// Parse parses the template text for the given parse mode. Templates written
+// by users go through ParseUntrusted instead.
+func Parse(mode, name, text string) (*Template, error) {
+	return parseTemplate(mode, name, text, nil)
+}
+
+// parseTemplate parses the template and has check, if not nil, inspect every
+// parse tree before the actions are escaped.
+func parseTemplate(mode, name, text string, check func(*parse.Tree) error) (*Template, error) {
+	t, err := template.New(name).Funcs(Funcs(mode)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tt := range t.Templates() {
+		if tt.Tree == nil {
+			continue
+		}
+		if check != nil {
+			if err := check(tt.Tree); err != nil {
+				return nil, err
+			}
+		}
+		escapeActions(tt.Tree, tt.Tree.Root)
+	}
+
+	return &Template{mode: mode, t: t, limit: maxOutput}, nil
+}
+
+// Must panics if err is not nil. It simplifies the initialization of
+// templates stored in package variables.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Mode returns the parse mode the template renders for.
+func (t *Template) Mode() string {
+	return t.mode
+}
+
+// Execute renders the template with data. Output beyond the limit of the
+// template stops the execution with an error.
+func (t *Template) Execute(data interface{}) (string, error) {
+	w := &limitWriter{limit: t.limit}
+	if err := t.t.Execute(w, data); err != nil {
+		if w.full {
+			return "", errTooLong
+		}
+		return "", err
+	}
+	return w.sb.String(), nil
+}
+
+// limitWriter collects up to limit bytes and fails the writes beyond.
+type limitWriter struct {
+	sb    strings.Builder
+	limit int
+	full  bool
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.sb.Len()+len(p) > w.limit {
+		w.full = true
+		return 0, errTooLong
+	}
+	return w.sb.Write(p)
+}
+
+// Render parses and executes a one-off template.
+func Render(mode, text string, data interface{}) (string, error) {
+	t, err := Parse(mode, "message", text)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(data)
+}
+
+// Funcs returns the template functions for the parse mode.
+func Funcs(mode string) template.FuncMap {
+	f := formatter{mode: mode}
+	return template.FuncMap{
+		"escape":  f.escape,
+		"raw":     func(v interface{}) Safe { return Safe(fmt.Sprint(v)) },
+		"bold":    f.bold,
+		"italic":  f.italic,
+		"code":    f.code,
+		"pre":     f.pre,
+		"link":    f.link,
+		"mention": f.mention,
+	}
+}
+
+// unescaped lists the functions whose output is never escaped again.
+var unescaped = map[string]bool{"escape": true, "raw": true}
+
+// escapeActions appends the escape function to the pipeline of every action
+// printing a value, in the same way html/template rewrites the parse tree.
+func escapeActions(tree *parse.Tree, node parse.Node) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			escapeActions(tree, child)
+		}
+	case *parse.ActionNode:
+		escapePipe(tree, n.Pipe)
+	case *parse.IfNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.RangeNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	case *parse.WithNode:
+		escapeActions(tree, n.List)
+		escapeActions(tree, n.ElseList)
+	}
+}
+
+func escapePipe(tree *parse.Tree, p *parse.PipeNode) {
+	// Variable declarations do not print anything.
+	if p == nil || len(p.Decl) > 0 || len(p.Cmds) == 0 {
+		return
+	}
+
+	last := p.Cmds[len(p.Cmds)-1]
+	if id, ok := last.Args[0].(*parse.IdentifierNode); ok && unescaped[id.Ident] {
+		return
+	}
+
+	id := parse.NewIdentifier("escape").SetTree(tree).SetPos(last.Pos)
+	p.Cmds = append(p.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      last.Pos,
+		Args:     []parse.Node{id},
+	})
+}
+
+// formatter implements the template functions for a parse mode.
+type formatter struct {
+	mode string
+}
+
+func (f formatter) escape(v interface{}) Safe {
+	if s, ok := v.(Safe); ok {
+		return s
+	}
+	return Safe(Escape(f.mode, fmt.Sprint(v)))
+}
+
+func (f formatter) bold(v interface{}) Safe {
+	return f.entity("*", "b", fmt.Sprint(v))
+}
+
+func (f formatter) italic(v interface{}) Safe {
+	return f.entity("_", "i", fmt.Sprint(v))
+}
+
+func (f formatter) code(v interface{}) Safe {
+	s := fmt.Sprint(v)
+	switch f.mode {
+	case tgbotapi.ModeMarkdown:
+		// Backticks cannot be escaped inside a code entity.
+		return Safe("`" + strings.Replace(s, "`", "'", -1) + "`")
+	case tgbotapi.ModeHTML:
+		return Safe("<code>" + escapeHTML(s) + "</code>")
+	}
+	return Safe(s)
+}
+
+func (f formatter) pre(v interface{}) Safe {
+	s := fmt.Sprint(v)
+	switch f.mode {
+	case tgbotapi.ModeMarkdown:
+		return Safe("```\n" + strings.Replace(s, "```", "'''", -1) + "\n```")
+	case tgbotapi.ModeHTML:
+		return Safe("<pre>" + escapeHTML(s) + "</pre>")
+	}
+	return Safe(s)
+}
+
+func (f formatter) link(url, text interface{}) Safe {
+	u, s := fmt.Sprint(url), fmt.Sprint(text)
+	switch f.mode {
+	case tgbotapi.ModeMarkdown:
+		// Neither part of a Markdown link can be escaped.
+		s = strings.NewReplacer("[", "(", "]", ")").Replace(s)
+		u = strings.NewReplacer(")", "%29", " ", "%20").Replace(u)
+		return Safe("[" + s + "](" + u + ")")
+	case tgbotapi.ModeHTML:
+		return Safe(`<a href="` + escapeHTML(u) + `">` + escapeHTML(s) + "</a>")
+	}
+	return Safe(s + " (" + u + ")")
+}
+
+// mention links to a user by ID, which works for users without a username.
+func (f formatter) mention(v interface{}) Safe {
+	var u *tgbotapi.User
+	switch user := v.(type) {
+	case *tgbotapi.User:
+		u = user
+	case tgbotapi.User:
+		u = &user
+	}
+	if u == nil {
+		return f.escape(v)
+	}
+
+	name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+	if name == "" {
+		name = u.String()
+	}
+	if f.mode == ModePlain {
+		if u.UserName != "" {
+			return Safe("@" + u.UserName)
+		}
+		return Safe(name)
+	}
+
+	return f.link("tg://user?id="+strconv.Itoa(u.ID), name)
+}
+
+// entity wraps s in a bold or italic entity. Markdown entities cannot contain
+// escaped delimiters, so the entity is closed around every delimiter in s.
+func (f formatter) entity(delim, tag, s string) Safe {
+	switch f.mode {
+	case tgbotapi.ModeMarkdown:
+		parts := strings.Split(s, delim)
+		var sb strings.Builder
+		for i, part := range parts {
+			if i > 0 {
+				sb.WriteString(`\` + delim)
+			}
+			if part != "" {
+				sb.WriteString(delim + part + delim)
+			}
+		}
+		return Safe(sb.String())
+	case tgbotapi.ModeHTML:
+		return Safe("<" + tag + ">" + escapeHTML(s) + "</" + tag + ">")
+	}
+	return Safe(s)
+}
@@ -0,0 +1,57 @@
+package render
+
+import (
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestEscape(t *testing.T) {
+	tests := []struct {
+		mode, s, want string
+	}{
+		{tgbotapi.ModeHTML, `<a href="x">&</a>`, `&lt;a href=&quot;x&quot;&gt;&amp;&lt;/a&gt;`},
+		{tgbotapi.ModeMarkdown, "*bold* _it_ `code` [link]", "\\*bold\\* \\_it\\_ \\`code\\` \\[link]"},
+		{ModePlain, "<b>*x*</b>", "<b>*x*</b>"},
+	}
+	for _, tt := range tests {
+		if got := Escape(tt.mode, tt.s); got != tt.want {
+			t.Errorf("Escape(%q, %q) = %q, want %q", tt.mode, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	user := &tgbotapi.User{ID: 7, FirstName: "A<n>n", UserName: "ann"}
+	tests := []struct {
+		mode, text string
+		data       interface{}
+		want       string
+	}{
+		{tgbotapi.ModeHTML, "{{.}}", "<b>&", "&lt;b&gt;&amp;"},
+		{tgbotapi.ModeHTML, "{{bold .}}", "<x>", "<b>&lt;x&gt;</b>"},
+		{tgbotapi.ModeHTML, "{{. | italic}}", "<x>", "<i>&lt;x&gt;</i>"},
+		{tgbotapi.ModeHTML, "{{raw .}}", "<u>x</u>", "<u>x</u>"},
+		{tgbotapi.ModeHTML, "{{escape .}}", "<x>", "&lt;x&gt;"},
+		{tgbotapi.ModeHTML, "{{if .}}{{.}}{{else}}none{{end}}", "<x>", "&lt;x&gt;"},
+		{tgbotapi.ModeHTML, "{{range .}}{{.}};{{end}}", []string{"<a>", "&"}, "&lt;a&gt;;&amp;;"},
+		{tgbotapi.ModeHTML, "{{with .}}{{.}}{{end}}", "<x>", "&lt;x&gt;"},
+		{tgbotapi.ModeHTML, "{{$x := .}}{{$x}}", "<x>", "&lt;x&gt;"},
+		{tgbotapi.ModeHTML, "{{code .}} {{pre .}}", "a<b", "<code>a&lt;b</code> <pre>a&lt;b</pre>"},
+		{tgbotapi.ModeHTML, `{{link "https://e.com/?a=1&b=2" .}}`, "<x>", `<a href="https://e.com/?a=1&amp;b=2">&lt;x&gt;</a>`},
+		{tgbotapi.ModeHTML, "{{mention .}}", user, `<a href="tg://user?id=7">A&lt;n&gt;n</a>`},
+		{tgbotapi.ModeMarkdown, "{{.}}", "a_b*c", "a\\_b\\*c"},
+		{tgbotapi.ModeMarkdown, "{{bold .}}", "a*b", "*a*\\**b*"},
+		{tgbotapi.ModeMarkdown, "{{code .}}", "a`b", "`a'b`"},
+		{tgbotapi.ModeMarkdown, "{{pre .}}", "a```b", "```\na'''b\n```"},
+		{tgbotapi.ModeMarkdown, `{{link "https://e.com/a b)" .}}`, "[x]", "[(x)](https://e.com/a%20b%29)"},
+		{ModePlain, "{{bold .FirstName}} {{mention .}}", user, "A<n>n @ann"},
+		{ModePlain, "{{mention .}}", tgbotapi.User{ID: 8, FirstName: "Bo"}, "Bo"},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.mode, tt.text, tt.data)
+		if err != nil || got != tt.want {
+			t.Errorf("Render(%q, %q) = %q, %v, want %q", tt.mode, tt.text, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package render
+
+import (
+	"strings"
+)
+
+// Split cuts text into parts no longer than limit UTF-16 code units.
+//
+// Parts are cut at paragraph, line or word boundaries where possible and
+// never inside a tag, link, escape sequence or grapheme cluster. Entities
+// spanning a cut are closed at the end of a part and reopened at the start of
+// the next one, so every part is valid markup on its own.
+//
+// If text is not valid markup for mode, it is split as plain text.
+func Split(text, mode string, limit int) []string {
+	if Length(text) <= limit {
+		return []string{text}
+	}
+
+	bounds, err := scan(text, mode)
+	if err != nil {
+		bounds, _ = scan(text, ModePlain)
+	}
+
+	var parts []string
+	first := 0 // index of the boundary the current part starts at
+	for {
+		start := bounds[first]
+		prefix := start.st.opening()
+		budget := limit - Length(prefix)
+
+		end := len(bounds) - 1
+		if bounds[end].units-start.units > budget {
+			end = cut(bounds, first, budget)
+		}
+
+		b := bounds[end]
+		body := strings.TrimRight(text[start.pos:b.pos], " \t\n")
+		if part := prefix + body + b.st.closing(); strings.TrimSpace(body) != "" {
+			parts = append(parts, part)
+		}
+
+		if end == len(bounds)-1 {
+			return parts
+		}
+
+		// The whitespace the text is cut at is dropped.
+		first = end
+		for first < len(bounds)-1 && bounds[first].level > breakNone {
+			first++
+		}
+	}
+}
+
+// cut returns the index of the boundary to end the part starting at bounds[first].
+// It prefers the strongest break in the second half of the part, then the
+// strongest break anywhere in it, then the last boundary fitting the budget.
+func cut(bounds []boundary, first, budget int) int {
+	start := bounds[first].units
+	fits := func(b boundary) bool {
+		return b.units-start+Length(b.st.closing()) <= budget
+	}
+
+	last := first
+	for i := first + 1; i < len(bounds) && bounds[i].units-start <= budget; i++ {
+		if fits(bounds[i]) {
+			last = i
+		}
+	}
+
+	for _, half := range []int{budget / 2, 0} {
+		for level := breakParagraph; level > breakNone; level-- {
+			for i := last; i > first && bounds[i].units-start > half; i-- {
+				if bounds[i].level >= level && bounds[i].safe && fits(bounds[i]) {
+					return i
+				}
+			}
+		}
+	}
+
+	for i := last; i > first; i-- {
+		if bounds[i].safe {
+			return i
+		}
+	}
+
+	// Nothing fits: a single tag or link is longer than the limit.
+	// Cut right after it to make progress, Telegram will reject the part.
+	if last == first {
+		return first + 1
+	}
+
+	return last
+}
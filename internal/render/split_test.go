@@ -0,0 +1,108 @@
+package render
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// checkParts checks that every part fits the limit and is valid markup.
+func checkParts(t *testing.T, name string, parts []string, mode string, limit int) {
+	t.Helper()
+	for i, p := range parts {
+		if n := Length(p); n > limit {
+			t.Errorf("%s: part %d is %d long, want at most %d: %q", name, i, n, limit, p)
+		}
+		if err := Validate(p, mode); err != nil {
+			t.Errorf("%s: part %d %q: %v", name, i, p, err)
+		}
+		if !utf8.ValidString(p) {
+			t.Errorf("%s: part %d %q is not valid UTF-8", name, i, p)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	words := strings.TrimSpace(strings.Repeat("word ", 40))
+	tests := []struct {
+		name, mode, text string
+		limit            int
+		want             []string
+	}{
+		{
+			name: "short", mode: tgbotapi.ModeHTML, limit: 100,
+			text: "<b>short</b>",
+			want: []string{"<b>short</b>"},
+		},
+		{
+			name: "paragraphs", mode: ModePlain, limit: 30,
+			text: "first paragraph\n\nsecond one is here",
+			want: []string{"first paragraph", "second one is here"},
+		},
+		{
+			name: "lines before words", mode: ModePlain, limit: 20,
+			text: "one two three\nfour five six",
+			want: []string{"one two three", "four five six"},
+		},
+		{
+			name: "entity reopened", mode: tgbotapi.ModeHTML, limit: 20,
+			text: "<b>aaaa bbbb cccc dddd</b>",
+			want: []string{"<b>aaaa bbbb</b>", "<b>cccc dddd</b>"},
+		},
+		{
+			name: "nested entities", mode: tgbotapi.ModeHTML, limit: 45,
+			text: `<b>bold <a href="https://e.com">link text here</a> end</b>`,
+			want: []string{`<b>bold <a href="https://e.com">link</a></b>`, `<b><a href="https://e.com">text here</a></b>`, `<b>end</b>`},
+		},
+		{
+			name: "markdown pre", mode: tgbotapi.ModeMarkdown, limit: 20,
+			text: "```\nline one\nline two\n```",
+			want: []string{"```\nline one```", "```\nline two\n```"},
+		},
+		{
+			name: "escape not cut", mode: tgbotapi.ModeMarkdown, limit: 6,
+			text: "aaaa\\_b",
+			want: []string{"aaaa\\_", "b"},
+		},
+		{
+			name: "surrogate pairs", mode: ModePlain, limit: 5,
+			text: "😀😀😀😀😀",
+			want: []string{"😀😀", "😀😀", "😀"},
+		},
+		{
+			name: "graphemes", mode: ModePlain, limit: 10,
+			text: "👨‍👩‍👧👨‍👩‍👧",
+			want: []string{"👨‍👩‍👧", "👨‍👩‍👧"},
+		},
+		{
+			name: "invalid markup", mode: tgbotapi.ModeHTML, limit: 5,
+			text: "<b>a b",
+			want: []string{"<b>a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		got := Split(tt.text, tt.mode, tt.limit)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: Split() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	for _, mode := range []string{tgbotapi.ModeHTML, tgbotapi.ModeMarkdown} {
+		text := "<i>" + words + "</i>\n<pre>" + words + "</pre>"
+		if mode == tgbotapi.ModeMarkdown {
+			text = "_" + words + "_\n```\n" + words + "\n```"
+		}
+		parts := Split(text, mode, 50)
+		checkParts(t, mode, parts, mode, 50)
+
+		var plain []string
+		for _, p := range parts {
+			plain = append(plain, strings.Fields(Plain(p, mode))...)
+		}
+		if got, want := strings.Join(plain, " "), strings.Join(strings.Fields(Plain(text, mode)), " "); got != want {
+			t.Errorf("%s: the parts read %q, want %q", mode, got, want)
+		}
+	}
+}
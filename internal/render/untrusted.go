@@ -0,0 +1,86 @@
+package render
+
+import (
+	"fmt"
+	"text/template/parse"
+)
+
+// untrustedFuncs are the functions templates written by users may call. The
+// builtins of text/template, like printf, and raw are left out.
+var untrustedFuncs = map[string]bool{
+	"escape": true, "bold": true, "italic": true, "code": true, "pre": true, "link": true, "mention": true,
+}
+
+// untrustedOutput caps the output of templates written by users in bytes. It
+// leaves room for the markup of a message of the maximum length.
+const untrustedOutput = 8 * MaxMessageLength
+
+// ParseUntrusted parses a template written by a user, such as the welcome
+// message of a group. Only actions printing a field of the data, a string or
+// the result of a formatting function called with them are allowed, so the
+// template can't loop, allocate large values or define other templates.
+func ParseUntrusted(mode, name, text string) (*Template, error) {
+	t, err := parseTemplate(mode, name, text, func(tree *parse.Tree) error {
+		return checkUntrusted(tree, tree.Root)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(t.t.Templates()) > 1 {
+		return nil, fmt.Errorf("template: %s: defining templates is not allowed", name)
+	}
+	t.limit = untrustedOutput
+	return t, nil
+}
+
+// checkUntrusted returns an error for the first node of the tree not allowed
+// in templates written by users.
+func checkUntrusted(tree *parse.Tree, node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkUntrusted(tree, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.TextNode:
+		return nil
+	case *parse.ActionNode:
+		if len(n.Pipe.Decl) == 0 && untrustedPipe(n.Pipe) {
+			return nil
+		}
+	}
+
+	location, context := tree.ErrorContext(node)
+	return fmt.Errorf("template: %s: %s is not allowed, only fields like {{.User}} and formatting functions like {{bold .Title}} are", location, context)
+}
+
+func untrustedPipe(p *parse.PipeNode) bool {
+	for i, cmd := range p.Cmds {
+		switch first := cmd.Args[0].(type) {
+		case *parse.FieldNode, *parse.DotNode, *parse.StringNode:
+			// A value can only start the pipeline.
+			if i > 0 || len(cmd.Args) > 1 {
+				return false
+			}
+		case *parse.IdentifierNode:
+			if !untrustedFuncs[first.Ident] {
+				return false
+			}
+			for _, arg := range cmd.Args[1:] {
+				switch arg.(type) {
+				case *parse.FieldNode, *parse.DotNode, *parse.StringNode:
+				default:
+					return false
+				}
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
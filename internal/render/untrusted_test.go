@@ -0,0 +1,78 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestParseUntrusted(t *testing.T) {
+	data := map[string]interface{}{
+		"User":  &tgbotapi.User{ID: 1, FirstName: "Ann"},
+		"Title": "<Group>",
+		"Count": 3,
+	}
+	tests := []struct {
+		text string
+		want string
+		err  bool
+	}{
+		{text: "Hi!", want: "Hi!"},
+		{text: "Hi {{.Title}}, {{.Count}}", want: "Hi &lt;Group&gt;, 3"},
+		{text: "{{bold .Title}} {{mention .User}}", want: `<b>&lt;Group&gt;</b> <a href="tg://user?id=1">Ann</a>`},
+		{text: `{{link "https://example.com" .Title}}`, want: `<a href="https://example.com">&lt;Group&gt;</a>`},
+		{text: "{{.Title | italic}}", want: "<i>&lt;Group&gt;</i>"},
+		{text: "{{range 1000000000}}x{{end}}", err: true},
+		{text: `{{printf "%0999999999d" 1}}`, err: true},
+		{text: "{{.Title | printf}}", err: true},
+		{text: `{{raw "<b>"}}`, err: true},
+		{text: "{{if .Count}}x{{end}}", err: true},
+		{text: "{{with .Title}}{{.}}{{end}}", err: true},
+		{text: "{{$x := .Title}}", err: true},
+		{text: `{{define "t"}}x{{end}}`, err: true},
+		{text: `{{template "message"}}`, err: true},
+		{text: "{{len .Title}}", err: true},
+		{text: "{{bold (printf `%s` .Title)}}", err: true},
+		{text: "{{.Count .Title}}", err: true},
+		{text: "{{", err: true},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseUntrusted(tgbotapi.ModeHTML, "message", tt.text)
+		if err != nil {
+			if !tt.err {
+				t.Errorf("ParseUntrusted(%q) error = %v", tt.text, err)
+			}
+			continue
+		}
+		if tt.err {
+			t.Errorf("ParseUntrusted(%q) succeeded", tt.text)
+			continue
+		}
+		got, err := tmpl.Execute(data)
+		if err != nil || got != tt.want {
+			t.Errorf("Execute(%q) = %q, %v; want %q", tt.text, got, err, tt.want)
+		}
+	}
+}
+
+func TestExecuteLimit(t *testing.T) {
+	long := strings.Repeat("x", untrustedOutput/2+1)
+
+	tmpl, err := ParseUntrusted(tgbotapi.ModeHTML, "message", "{{.}}{{.}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.Execute(long); err != errTooLong {
+		t.Errorf("Execute of %d bytes: error = %v, want %v", 2*len(long), err, errTooLong)
+	}
+	if got, err := tmpl.Execute("ok"); err != nil || got != "okok" {
+		t.Errorf("Execute() = %q, %v", got, err)
+	}
+
+	// Trusted templates may loop, but not without bounds either.
+	loop := Must(Parse(ModePlain, "message", "{{range .}}xxxxxxxx{{end}}"))
+	if _, err := loop.Execute(make([]struct{}, maxOutput)); err != errTooLong {
+		t.Errorf("Execute of a long loop: error = %v, want %v", err, errTooLong)
+	}
+}
@@ -0,0 +1,236 @@
+// Package editor implements /settings, an inline keyboard editing the chat settings.
+package editor
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+)
+
+const (
+	// callbackPrefix starts the data of the editor buttons.
+	callbackPrefix = "settings:"
+	// historySize is the number of recent changes shown by the editor.
+	historySize = 3
+	// auto follows the language of each user.
+	auto = "auto"
+)
+
+// warnPresets are the moderation policies the editor cycles through. The
+// empty policy is the bot default.
+var warnPresets = []string{"", "3:mute:1d,5:ban", "3:mute:1h,5:kick,7:ban", "3:kick", "5:ban"}
+
+// Editor implements the /settings command of group admins.
+type Editor struct {
+	bot *bot.Bot
+	mod *moderation.Moderator
+}
+
+// New creates the settings editor for the group admins verified by the moderator.
+func New(b *bot.Bot, mod *moderation.Moderator) *Editor {
+	return &Editor{bot: b, mod: mod}
+}
+
+// Register adds the /settings command and the handler of its buttons to the router.
+func (e *Editor) Register(r *bot.Router) {
+	r.Command("settings", e.mod.OnlyAdmins(e.open))
+	r.Handle(bot.IsCallback(callbackPrefix), e.press)
+}
+
+func (e *Editor) open(c *bot.Context) error {
+	m := c.Update.Message
+
+	text, keyboard, err := e.view(c, m.Chat)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	msg.ReplyMarkup = keyboard
+	_, err = c.Send(msg)
+
+	return err
+}
+
+func (e *Editor) press(c *bot.Context) error {
+	q := c.Update.CallbackQuery
+	m := q.Message
+	if m == nil {
+		return c.Answer("", false)
+	}
+
+	// The buttons need the same rights as opening the editor.
+	allowed, err := e.mod.CanRestrict(m.Chat.ID, q.From.ID)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return c.Answer(c.T("settings.forbidden"), true)
+	}
+
+	button := strings.TrimPrefix(q.Data, callbackPrefix)
+	if !e.valid(button) {
+		return c.Answer("", false)
+	}
+	if button == "close" {
+		if _, err := e.bot.API.DeleteMessage(tgbotapi.NewDeleteMessage(m.Chat.ID, m.MessageID)); err != nil {
+			return err
+		}
+		return c.Answer("", false)
+	}
+
+	change, err := e.bot.Settings.Change(m.Chat.ID, q.From.ID, func(chat *settings.Chat) (string, error) {
+		return apply(chat, button), nil
+	})
+	if err != nil {
+		return err
+	}
+	// The language of the chat may have changed.
+	c.SetLang("")
+
+	text, keyboard, err := e.view(c, m.Chat)
+	if err != nil {
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(m.Chat.ID, m.MessageID, text)
+	edit.ReplyMarkup = &keyboard
+	if _, err := c.Send(edit); err != nil {
+		return err
+	}
+
+	return c.Answer(c.T("settings.saved", "change", change), false)
+}
+
+// valid reports whether the button is one of the editor, which for the
+// language buttons means a language of the bundle. Callback data can be
+// forged, so it is checked before anything is stored.
+func (e *Editor) valid(button string) bool {
+	switch button {
+	case "echo", "welcome", "goodbye", "antispam", "warn":
+		return true
+	}
+	if !strings.HasPrefix(button, "lang:") {
+		return false
+	}
+	lang := strings.TrimPrefix(button, "lang:")
+	if lang == auto {
+		return true
+	}
+	for _, l := range e.bot.I18n.Languages() {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// apply changes the setting of the button and describes the change.
+func apply(chat *settings.Chat, button string) string {
+	switch {
+	case button == "echo":
+		chat.Echo = !chat.Echo
+		return "echo=" + onOff(chat.Echo)
+	case button == "welcome":
+		chat.Welcome.WelcomeOn = !chat.Welcome.WelcomeOn
+		return "welcome=" + onOff(chat.Welcome.WelcomeOn)
+	case button == "goodbye":
+		chat.Welcome.GoodbyeOn = !chat.Welcome.GoodbyeOn
+		return "goodbye=" + onOff(chat.Welcome.GoodbyeOn)
+	case button == "antispam":
+		chat.Antispam.Enabled = !chat.Antispam.Enabled
+		return "antispam=" + onOff(chat.Antispam.Enabled)
+	case button == "warn":
+		chat.WarnActions = nextPreset(chat.WarnActions)
+		return "warn_actions=" + chat.WarnActions
+	case strings.HasPrefix(button, "lang:"):
+		chat.Language = strings.TrimPrefix(button, "lang:")
+		if chat.Language == auto {
+			chat.Language = ""
+		}
+		return "language=" + chat.Language
+	}
+	return ""
+}
+
+// view returns the text and the keyboard of the editor.
+func (e *Editor) view(c *bot.Context, ch *tgbotapi.Chat) (string, tgbotapi.InlineKeyboardMarkup, error) {
+	chat, err := e.bot.Settings.Get(ch.ID)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+
+	lines := []string{c.T("settings.title", "title", ch.Title), c.T("settings.hint")}
+
+	history, err := e.bot.Settings.History(ch.ID, historySize)
+	if err != nil {
+		return "", tgbotapi.InlineKeyboardMarkup{}, err
+	}
+	if len(history) > 0 {
+		lines = append(lines, "", c.T("settings.history"))
+		for _, h := range history {
+			lines = append(lines, c.T("settings.history_entry",
+				"time", h.Time.UTC().Format(time.RFC822), "user", h.UserID, "change", h.Args))
+		}
+	}
+
+	state := func(on bool) string { return c.T("settings." + onOff(on)) }
+	policy := chat.WarnActions
+	if policy == "" {
+		policy = c.T("settings.warn_default")
+	}
+
+	var languages []tgbotapi.InlineKeyboardButton
+	for _, lang := range append([]string{auto}, e.bot.I18n.Languages()...) {
+		label := lang
+		if lang == auto {
+			label = c.T("settings.language_auto")
+		}
+		if lang == chat.Language || lang == auto && chat.Language == "" {
+			label = "✓ " + label
+		}
+		languages = append(languages, button(label, "lang:"+lang))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(c.T("settings.echo", "state", state(chat.Echo)), "echo"),
+			button(c.T("settings.antispam", "state", state(chat.Antispam.Enabled)), "antispam"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(c.T("settings.welcome", "state", state(chat.Welcome.WelcomeOn)), "welcome"),
+			button(c.T("settings.goodbye", "state", state(chat.Welcome.GoodbyeOn)), "goodbye"),
+		),
+		tgbotapi.NewInlineKeyboardRow(button(c.T("settings.warn", "policy", policy), "warn")),
+		languages,
+		tgbotapi.NewInlineKeyboardRow(button(c.T("settings.close"), "close")),
+	)
+
+	return strings.Join(lines, "\n"), keyboard, nil
+}
+
+func button(label, data string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(label, callbackPrefix+data)
+}
+
+// nextPreset returns the warn preset following the policy.
+func nextPreset(policy string) string {
+	for i, p := range warnPresets {
+		if p == policy {
+			return warnPresets[(i+1)%len(warnPresets)]
+		}
+	}
+	return warnPresets[0]
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,69 @@
+package editor
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+func TestValid(t *testing.T) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New(bot.New(&tgbotapi.BotAPI{}, storage.NewMemory(), bundle), nil)
+
+	tests := []struct {
+		button string
+		want   bool
+	}{
+		{"echo", true},
+		{"warn", true},
+		{"lang:auto", true},
+		{"lang:en", true},
+		{"lang:ru", true},
+		{"lang:xx", false},
+		{"lang:", false},
+		{"lang:en:extra", false},
+		{"close!", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := e.valid(tt.button); got != tt.want {
+			t.Errorf("valid(%q) = %t, want %t", tt.button, got, tt.want)
+		}
+	}
+}
+
+func TestChangeDescribesAppliedSettings(t *testing.T) {
+	s := &settings.Store{Store: storage.NewMemory()}
+
+	// Two toggles in a row are described on the settings each one changes.
+	for _, want := range []string{"echo=off", "echo=on"} {
+		change, err := s.Change(-1, 1, func(chat *settings.Chat) (string, error) {
+			return apply(chat, "echo"), nil
+		})
+		if err != nil || change != want {
+			t.Errorf("Change() = %q, %v, want %q", change, err, want)
+		}
+	}
+
+	history, err := s.History(-1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range history {
+		got = append(got, e.Args)
+	}
+	if want := []string{"echo=on", "echo=off"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
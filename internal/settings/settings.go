@@ -0,0 +1,213 @@
+// Package settings keeps the configuration of every chat, which the chat
+// admins change with /settings and the feature commands.
+package settings
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/audit"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// bucket stores the settings per chat.
+const bucket = "chat_settings"
+
+// auditAction marks settings changes in the audit log.
+const auditAction = "settings"
+
+// Chat is the configuration of a chat.
+type Chat struct {
+	// Language of the replies in the chat. Empty means the language of each user.
+	Language string `json:"language,omitempty"`
+	// Echo enables echoing messages back.
+	Echo bool `json:"echo"`
+	// WarnActions overrides the automatic moderation actions of the bot, see
+	// moderation.ParsePolicy. Empty means the bot default.
+	WarnActions string `json:"warn_actions,omitempty"`
+
+	Welcome  Welcome  `json:"welcome"`
+	Antispam Antispam `json:"antispam"`
+
+	// Modules switches modules off, or back on, in the chat. Modules not
+	// listed are on. See the module package.
+	Modules map[string]bool `json:"modules,omitempty"`
+}
+
+// Welcome configures the welcome and goodbye messages.
+type Welcome struct {
+	WelcomeOn bool `json:"welcome_on"`
+	// Welcome is the template of the welcome message. Empty means the default one.
+	Welcome   string `json:"welcome,omitempty"`
+	GoodbyeOn bool   `json:"goodbye_on"`
+	// Goodbye is the template of the goodbye message. Empty means the default one.
+	Goodbye string `json:"goodbye,omitempty"`
+	// Rules are shown by the button under the welcome message. A URL opens
+	// the page, other text is shown as an alert.
+	Rules string `json:"rules,omitempty"`
+	// DeletePrevious deletes the previous welcome message when a new one is sent.
+	DeletePrevious bool `json:"delete_previous"`
+}
+
+// Antispam configures the spam protection.
+type Antispam struct {
+	Enabled bool `json:"enabled"`
+	// Actions maps the rules of the antispam package to the actions taken
+	// when they match. Rules not listed take their default action, see
+	// antispam.Action.
+	Actions map[string]string `json:"actions,omitempty"`
+
+	// More than FloodMessages messages from a user within FloodWindow are a flood.
+	FloodMessages int           `json:"flood_messages"`
+	FloodWindow   time.Duration `json:"flood_window"`
+	// Repeats identical messages in a row are spam.
+	Repeats int `json:"repeats"`
+	// MuteFor is the duration of the mute action. Zero mutes forever.
+	MuteFor time.Duration `json:"mute_for"`
+	// Blocklist holds regular expressions matched case-insensitively against
+	// the text and the caption of messages.
+	Blocklist []string `json:"blocklist,omitempty"`
+}
+
+// Default returns the settings of chats whose admins have not changed them.
+func Default() Chat {
+	return Chat{
+		Echo: true,
+		// Spam protection is disabled until the admins enable it.
+		Antispam: Antispam{
+			FloodMessages: 5,
+			FloodWindow:   10 * time.Second,
+			Repeats:       3,
+			MuteFor:       time.Hour,
+		},
+	}
+}
+
+// Store loads and changes the chat settings. Every change is recorded in the audit log.
+type Store struct {
+	Store storage.Store
+
+	// mu serializes the changes, so concurrent changes are not lost.
+	mu sync.Mutex
+}
+
+// Get returns the settings of the chat.
+func (s *Store) Get(chatID int64) (Chat, error) {
+	cfg := Default()
+
+	err := s.Store.Get(bucket, key(chatID), &cfg)
+	if err == storage.ErrNotFound {
+		return cfg, nil
+	}
+
+	return cfg, err
+}
+
+// Update changes the settings of the chat with fn on behalf of the user and
+// records the change described by change in the audit log. The change is not
+// stored if fn returns an error.
+func (s *Store) Update(chatID int64, userID int, change string, fn func(cfg *Chat) error) error {
+	_, err := s.Change(chatID, userID, func(cfg *Chat) (string, error) {
+		return change, fn(cfg)
+	})
+	return err
+}
+
+// Change is Update for changes described by fn itself, since the description
+// depends on the settings fn is given. It returns the description.
+func (s *Store) Change(chatID int64, userID int, fn func(cfg *Chat) (string, error)) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cfg, err := s.Get(chatID)
+	if err != nil {
+		return "", err
+	}
+	change, err := fn(&cfg)
+	if err != nil {
+		return "", err
+	}
+	if err := s.Store.Put(bucket, key(chatID), cfg); err != nil {
+		return "", err
+	}
+
+	return change, audit.Log{Store: s.Store}.Record(audit.Entry{
+		UserID: userID,
+		ChatID: chatID,
+		Action: auditAction,
+		Args:   change,
+		Result: audit.ResultOK,
+	})
+}
+
+// History returns up to n latest changes of the chat settings, newest first.
+func (s *Store) History(chatID int64, n int) ([]audit.Entry, error) {
+	// The audit log is shared by all chats, so more entries are scanned.
+	entries, err := audit.Log{Store: s.Store}.Last(100 * n)
+	if err != nil {
+		return nil, err
+	}
+
+	var history []audit.Entry
+	for _, e := range entries {
+		if e.Action == auditAction && e.ChatID == chatID {
+			history = append(history, e)
+			if len(history) == n {
+				break
+			}
+		}
+	}
+
+	return history, nil
+}
+
+// MigrateLegacy moves the welcome and antispam configuration the chats had
+// before the settings were kept together into the chat settings. Chats whose
+// settings were saved since already have it. It is a storage migration.
+func MigrateLegacy(s storage.Store) error {
+	legacy := map[string]func(cfg *Chat) interface{}{
+		"welcome":  func(cfg *Chat) interface{} { return &cfg.Welcome },
+		"antispam": func(cfg *Chat) interface{} { return &cfg.Antispam },
+	}
+
+	chats := make(map[string]bool)
+	for b := range legacy {
+		keys, err := s.Keys(b)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys {
+			chats[k] = true
+		}
+	}
+
+	for k := range chats {
+		cfg := Default()
+		err := s.Get(bucket, k, &cfg)
+		switch {
+		case err == storage.ErrNotFound:
+			for b, field := range legacy {
+				if err := s.Get(b, k, field(&cfg)); err != nil && err != storage.ErrNotFound {
+					return err
+				}
+			}
+			if err := s.Put(bucket, k, cfg); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		}
+
+		for b := range legacy {
+			if err := s.Delete(b, k); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func key(chatID int64) string {
+	return strconv.FormatInt(chatID, 10)
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// record is a single line of the file store journal.
+type record struct {
+	Op     string          `json:"op"`
+	Bucket string          `json:"b"`
+	Key    string          `json:"k"`
+	Value  json.RawMessage `json:"v,omitempty"`
+}
+
+const (
+	opPut    = "put"
+	opDelete = "del"
+)
+
+// File is a Store backed by an append-only journal of JSON lines.
+//
+// The whole data set is kept in memory. Every change is appended to the journal,
+// which is compacted into a snapshot when the store is opened and closed.
+type File struct {
+	*Memory
+
+	path string
+	f    *os.File
+}
+
+// OpenFile opens or creates the journal at path and loads its content.
+func OpenFile(path string) (*File, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &File{Memory: NewMemory(), path: path}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	s.f = f
+
+	return s, nil
+}
+
+// Put implements Store.
+func (s *File) Put(bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.append(record{Op: opPut, Bucket: bucket, Key: key, Value: data}); err != nil {
+		return err
+	}
+	s.set(bucket, key, data)
+
+	return nil
+}
+
+// Delete implements Store.
+func (s *File) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.buckets[bucket][key]; !ok {
+		return nil
+	}
+	if err := s.append(record{Op: opDelete, Bucket: bucket, Key: key}); err != nil {
+		return err
+	}
+	s.unset(bucket, key)
+
+	return nil
+}
+
+// Close compacts the journal and closes the underlying file.
+func (s *File) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+
+	return s.compact()
+}
+
+// Compact rewrites the journal so it contains a single put per stored key.
+// Call it periodically, the journal is otherwise compacted only on open and close.
+func (s *File) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.compact(); err != nil {
+		return err
+	}
+
+	// The journal was replaced, so the old file is closed and the new one opened.
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := s.f.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	s.f = f
+
+	return nil
+}
+
+func (s *File) append(r record) error {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.f.Write(append(line, '\n'))
+
+	return err
+}
+
+func (s *File) load() error {
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		var r record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// A torn last line is expected after a crash, anything else is corruption.
+			if !scanner.Scan() {
+				break
+			}
+			return fmt.Errorf("storage: %s:%d: %s", s.path, n, err)
+		}
+
+		switch r.Op {
+		case opPut:
+			s.set(r.Bucket, r.Key, []byte(r.Value))
+		case opDelete:
+			s.unset(r.Bucket, r.Key)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// compact rewrites the journal so it contains a single put per stored key.
+func (s *File) compact() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for bucket, keys := range s.buckets {
+		for key, value := range keys {
+			if err := enc.Encode(record{Op: opPut, Bucket: bucket, Key: key, Value: value}); err != nil {
+				tmp.Close()
+				return err
+			}
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func tempJournal(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "data", "journal"), func() { os.RemoveAll(dir) }
+}
+
+func get(t *testing.T, s Store, bucket, key string) string {
+	var v string
+	if err := s.Get(bucket, key, &v); err != nil {
+		if err == ErrNotFound {
+			return "<none>"
+		}
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestFileReplay(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the file is closed, Close would compact the journal.
+	defer s.f.Close()
+	for _, kv := range [][2]string{{"a", "1"}, {"b", "2"}, {"a", "3"}, {"c", "4"}} {
+		if err := s.Put("x", kv[0], kv[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Delete("x", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("y", "z", "5"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store is not closed, as after a crash, so the journal is replayed.
+	reopened, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reopened.Close()
+	for _, tt := range []struct{ bucket, key, want string }{
+		{"x", "a", "3"}, {"x", "b", "2"}, {"x", "c", "<none>"}, {"y", "z", "5"},
+	} {
+		if got := get(t, reopened, tt.bucket, tt.key); got != tt.want {
+			t.Errorf("Get(%s, %s) after reopening = %s, want %s", tt.bucket, tt.key, got, tt.want)
+		}
+	}
+
+	// The journal is compacted on open.
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "\n"); n != 3 {
+		t.Errorf("journal has %d lines after reopening, want 3:\n%s", n, data)
+	}
+}
+
+func TestFileTornLine(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	journal := `{"op":"put","b":"x","k":"a","v":"1"}` + "\n" +
+		`{"op":"put","b":"x","k":"b","v":"2"}` + "\n" +
+		`{"op":"put","b":"x","k":"a","v":"`
+	if err := ioutil.WriteFile(path, []byte(journal), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile() with a torn last line: %v", err)
+	}
+	if a, b := get(t, s, "x", "a"), get(t, s, "x", "b"); a != "1" || b != "2" {
+		t.Errorf("Get() = %s, %s; want the lines before the torn one", a, b)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A broken line followed by others is corruption.
+	journal = `{"op":"put","b":"x","k":"a","v":` + "\n" + `{"op":"put","b":"x","k":"b","v":"2"}` + "\n"
+	if err := ioutil.WriteFile(path, []byte(journal), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenFile(path); err == nil || !strings.Contains(err.Error(), path+":1:") {
+		t.Errorf("OpenFile() with a broken line = %v, want an error at line 1", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	path, cleanup := tempJournal(t)
+	defer cleanup()
+
+	file, err := OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	for name, s := range map[string]Store{"memory": NewMemory(), "file": file} {
+		for _, k := range []string{"b", "10", "a/2", "2", "a/10"} {
+			if err := s.Put("x", k, k); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Put("y", "0", "other bucket"); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Delete("x", "b"); err != nil {
+			t.Fatal(err)
+		}
+
+		keys, err := s.Keys("x")
+		if want := []string{"10", "2", "a/10", "a/2"}; err != nil || !reflect.DeepEqual(keys, want) {
+			t.Errorf("%s: Keys() = %q, %v; want %q", name, keys, err, want)
+		}
+		if keys, err := s.Keys("none"); err != nil || len(keys) != 0 {
+			t.Errorf("%s: Keys() of an empty bucket = %q, %v", name, keys, err)
+		}
+	}
+}
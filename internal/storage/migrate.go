@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// metaBucket holds data about the store itself.
+const metaBucket = "meta"
+
+// schemaKey is the key of the schema version in metaBucket.
+const schemaKey = "schema_version"
+
+// versionKey returns the key of the version of the named schema. The
+// migrations of the bot itself have the unnamed schema, the ones of every
+// module are versioned separately under the name of the module.
+func versionKey(schema string) string {
+	if schema == "" {
+		return schemaKey
+	}
+	return schemaKey + "/" + schema
+}
+
+// Migration changes the stored data from the previous schema version to Version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(s Store) error
+}
+
+// SchemaVersion returns the version of the last migration of the schema
+// applied to the store, 0 if none was applied.
+func SchemaVersion(s Store, schema string) (int, error) {
+	var v int
+	if err := s.Get(metaBucket, versionKey(schema), &v); err != nil && err != ErrNotFound {
+		return 0, err
+	}
+	return v, nil
+}
+
+// Pending returns the migrations of the schema not applied to the store yet.
+// It fails if the store has a schema version newer than the last migration,
+// since the data was written by a newer version of the bot.
+func Pending(s Store, schema string, migrations []Migration) ([]Migration, error) {
+	v, err := SchemaVersion(s, schema)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(migrations); n > 0 && v > migrations[n-1].Version || n == 0 && v > 0 {
+		return nil, fmt.Errorf("storage: schema version %d%s is newer than this version of the bot knows", v, schemaName(schema))
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > v {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations of the schema in order and returns
+// them. The schema version is stored after every migration, so a failed
+// migration is retried on the next call.
+func Migrate(s Store, schema string, migrations []Migration) ([]Migration, error) {
+	pending, err := Pending(s, schema, migrations)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, m := range pending {
+		if err := m.Up(s); err != nil {
+			return pending[:i], fmt.Errorf("storage: migration %d%s (%s): %s", m.Version, schemaName(schema), m.Name, err)
+		}
+		if err := s.Put(metaBucket, versionKey(schema), m.Version); err != nil {
+			return pending[:i], err
+		}
+	}
+	return pending, nil
+}
+
+// schemaName formats the schema name for the errors.
+func schemaName(schema string) string {
+	if schema == "" {
+		return ""
+	}
+	return " of " + schema
+}
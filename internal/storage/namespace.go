@@ -0,0 +1,39 @@
+package storage
+
+// Namespace is a Store keeping its buckets apart from the other namespaces of
+// a shared store by prefixing their names. It lets several bots of a process
+// share one journal.
+type Namespace struct {
+	Store  Store
+	Prefix string
+}
+
+// NewNamespace creates the namespace of the name in s.
+func NewNamespace(s Store, name string) *Namespace {
+	return &Namespace{Store: s, Prefix: name + "/"}
+}
+
+// Get implements Store.
+func (n *Namespace) Get(bucket, key string, v interface{}) error {
+	return n.Store.Get(n.Prefix+bucket, key, v)
+}
+
+// Put implements Store.
+func (n *Namespace) Put(bucket, key string, v interface{}) error {
+	return n.Store.Put(n.Prefix+bucket, key, v)
+}
+
+// Delete implements Store.
+func (n *Namespace) Delete(bucket, key string) error {
+	return n.Store.Delete(n.Prefix+bucket, key)
+}
+
+// Keys implements Store.
+func (n *Namespace) Keys(bucket string) ([]string, error) {
+	return n.Store.Keys(n.Prefix + bucket)
+}
+
+// Close does nothing: the shared store is closed by its owner.
+func (n *Namespace) Close() error {
+	return nil
+}
@@ -0,0 +1,151 @@
+// Package storage persists bot state as JSON documents grouped in buckets.
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned by Get when the key does not exist in the bucket.
+var ErrNotFound = errors.New("storage: not found")
+
+// Store is a simple bucketed key-value store. Values are encoded as JSON.
+type Store interface {
+	// Get decodes the value stored under key into v.
+	Get(bucket, key string, v interface{}) error
+	// Put stores v under key, replacing any previous value.
+	Put(bucket, key string, v interface{}) error
+	// Delete removes key from the bucket. Deleting a missing key is not an error.
+	Delete(bucket, key string) error
+	// Keys returns all keys of the bucket in lexical order.
+	Keys(bucket string) ([]string, error)
+	// Close releases resources held by the store.
+	Close() error
+}
+
+// checkKey is written and read back by Check, and read by Ping.
+const checkKey = "health"
+
+// Check writes a value to the store and reads it back, reporting whether the
+// store works.
+func Check(s Store) error {
+	now := time.Now().UnixNano()
+	if err := s.Put(checkKey, checkKey, now); err != nil {
+		return err
+	}
+
+	var got int64
+	if err := s.Get(checkKey, checkKey, &got); err != nil {
+		return err
+	}
+	if got != now {
+		return errors.New("storage: read a stale value")
+	}
+
+	return nil
+}
+
+// Ping reads a value from the store without writing, reporting whether the
+// store can be reached. A missing value is not an error.
+func Ping(s Store) error {
+	var got int64
+	if err := s.Get(checkKey, checkKey, &got); err != nil && err != ErrNotFound {
+		return err
+	}
+	return nil
+}
+
+// Compactor is implemented by stores whose size grows with every change until
+// they are compacted.
+type Compactor interface {
+	Compact() error
+}
+
+// Memory is an in-memory Store. The zero value is not usable, use NewMemory.
+type Memory struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+// NewMemory creates an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]map[string][]byte)}
+}
+
+// Get implements Store.
+func (m *Memory) Get(bucket, key string, v interface{}) error {
+	m.mu.RLock()
+	data, ok := m.buckets[bucket][key]
+	m.mu.RUnlock()
+
+	if !ok {
+		return ErrNotFound
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// Put implements Store.
+func (m *Memory) Put(bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.set(bucket, key, data)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Delete implements Store.
+func (m *Memory) Delete(bucket, key string) error {
+	m.mu.Lock()
+	m.unset(bucket, key)
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Keys implements Store.
+func (m *Memory) Keys(bucket string) ([]string, error) {
+	m.mu.RLock()
+	keys := make([]string, 0, len(m.buckets[bucket]))
+	for k := range m.buckets[bucket] {
+		keys = append(keys, k)
+	}
+	m.mu.RUnlock()
+
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// Close implements Store.
+func (m *Memory) Close() error {
+	return nil
+}
+
+func (m *Memory) set(bucket, key string, data []byte) {
+	b, ok := m.buckets[bucket]
+	if !ok {
+		b = make(map[string][]byte)
+		m.buckets[bucket] = b
+	}
+	b[key] = data
+}
+
+func (m *Memory) unset(bucket, key string) {
+	b, ok := m.buckets[bucket]
+	if !ok {
+		return
+	}
+	delete(b, key)
+	if len(b) == 0 {
+		delete(m.buckets, bucket)
+	}
+}
@@ -0,0 +1,92 @@
+// Package tgerr classifies errors returned by the Telegram Bot API.
+//
+// The API client reports failed requests as tgbotapi.Error carrying only the
+// error description. Telegram prefixes descriptions with the HTTP status text,
+// e.g. "Forbidden: bot was blocked by the user", which is used to recover the
+// error code.
+package tgerr
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Error codes returned by the Bot API.
+const (
+	BadRequest      = 400
+	Unauthorized    = 401
+	Forbidden       = 403
+	NotFound        = 404
+	Conflict        = 409
+	TooManyRequests = 429
+)
+
+var prefixes = []struct {
+	prefix string
+	code   int
+}{
+	{"Bad Request", BadRequest},
+	{"Unauthorized", Unauthorized},
+	{"Forbidden", Forbidden},
+	{"Not Found", NotFound},
+	{"Conflict", Conflict},
+	{"Too Many Requests", TooManyRequests},
+}
+
+// Code returns the Bot API error code of err, or 0 if err is not an API error
+// or the code is unknown.
+func Code(err error) int {
+	e, ok := err.(tgbotapi.Error)
+	if !ok {
+		return 0
+	}
+
+	for _, p := range prefixes {
+		if strings.HasPrefix(e.Message, p.prefix) {
+			return p.code
+		}
+	}
+
+	return 0
+}
+
+// IsForbidden reports whether the bot may not write to the chat anymore,
+// e.g. it was blocked by the user or kicked from the group.
+func IsForbidden(err error) bool {
+	return Code(err) == Forbidden
+}
+
+// IsPermanent reports whether repeating the request can't succeed: it was
+// rejected as invalid, for example because the chat doesn't exist, or the bot
+// may not write to the chat. Flood control and group upgrades are not
+// permanent, see RetryAfter and MigratedTo.
+func IsPermanent(err error) bool {
+	if RetryAfter(err) > 0 || MigratedTo(err) != 0 {
+		return false
+	}
+	switch Code(err) {
+	case BadRequest, Forbidden, NotFound:
+		return true
+	}
+	return false
+}
+
+// RetryAfter returns how long to wait before repeating a request rejected
+// because of flood control, or 0 if err is not a flood control error.
+func RetryAfter(err error) time.Duration {
+	if e, ok := err.(tgbotapi.Error); ok && e.RetryAfter > 0 {
+		return time.Duration(e.RetryAfter) * time.Second
+	}
+	return 0
+}
+
+// MigratedTo returns the ID of the supergroup a group was upgraded to, or 0
+// if err is not caused by such a migration.
+func MigratedTo(err error) int64 {
+	if e, ok := err.(tgbotapi.Error); ok {
+		return e.MigrateToChatID
+	}
+	return 0
+}
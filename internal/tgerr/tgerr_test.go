@@ -0,0 +1,30 @@
+package tgerr
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("connection reset"), false},
+		{tgbotapi.Error{Message: "Bad Request: chat not found"}, true},
+		{tgbotapi.Error{Message: "Forbidden: bot was blocked by the user"}, true},
+		{tgbotapi.Error{Message: "Not Found"}, true},
+		{tgbotapi.Error{Message: "Bad Request: group chat was upgraded to a supergroup chat", ResponseParameters: tgbotapi.ResponseParameters{MigrateToChatID: -100}}, false},
+		{tgbotapi.Error{Message: "Too Many Requests: retry after 5", ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: 5}}, false},
+		{tgbotapi.Error{Message: "Unauthorized"}, false},
+		{tgbotapi.Error{Message: "Internal Server Error"}, false},
+	}
+	for _, tt := range tests {
+		if got := IsPermanent(tt.err); got != tt.want {
+			t.Errorf("IsPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
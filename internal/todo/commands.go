@@ -0,0 +1,469 @@
+package todo
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/remind"
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+)
+
+const (
+	// callbackPrefix starts the data of the list buttons.
+	callbackPrefix = "todo:"
+	// dueWord separates the item text from its due date.
+	dueWord = "due"
+	// maxLabelLength is the length of the item texts on the buttons.
+	maxLabelLength = 40
+	// minShownLength is the shortest the item texts are cut to, so that a
+	// full list fits in a message.
+	minShownLength = 16
+)
+
+// Planner manages the todo lists.
+type Planner struct {
+	bot       *bot.Bot
+	mod       *moderation.Moderator
+	reminders *remind.Scheduler
+	lists     lists
+
+	// mu serializes the changes of the lists.
+	mu sync.Mutex
+}
+
+// New creates a planner. Items with a due date are reminded of through the
+// scheduler. Group admins verified by the moderator can change any item.
+func New(b *bot.Bot, mod *moderation.Moderator, reminders *remind.Scheduler) *Planner {
+	return &Planner{bot: b, mod: mod, reminders: reminders, lists: lists{store: b.Store}}
+}
+
+// Register adds the /todo command and the handler of the list buttons to the router.
+func (p *Planner) Register(r *bot.Router) {
+	r.Command("todo", p.command)
+	r.Handle(bot.IsCallback(callbackPrefix), p.press)
+}
+
+// command handles /todo [add <text> [due <when>]|done <n>|del <n>|clear|export].
+func (p *Planner) command(c *bot.Context) error {
+	sub, rest := splitCommand(c.Update.Message.CommandArguments())
+	switch sub {
+	case "":
+		return p.show(c)
+	case "add":
+		return p.add(c, rest)
+	case "done", "del":
+		id, err := strconv.Atoi(strings.TrimPrefix(rest, "#"))
+		if err != nil {
+			return c.Reply(c.T("todo.usage"))
+		}
+		if sub == "done" {
+			return p.change(c, id, p.toggle)
+		}
+		return p.change(c, id, p.remove)
+	case "clear":
+		return p.clear(c)
+	case "export":
+		return p.export(c)
+	}
+
+	return c.Reply(c.T("todo.usage"))
+}
+
+func (p *Planner) show(c *bot.Context) error {
+	m := c.Update.Message
+
+	l, err := p.lists.get(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, p.text(c, l))
+	if len(l.Items) > 0 {
+		msg.ReplyMarkup = keyboard(c, l)
+	}
+	_, err = c.Send(msg)
+
+	return err
+}
+
+// add handles /todo add <text> [due <when>]. The first member mentioned in
+// the text is the assignee.
+func (p *Planner) add(c *bot.Context, args string) error {
+	m := c.Update.Message
+
+	text, when := splitDue(args)
+	if text == "" {
+		return c.Reply(c.T("todo.usage"))
+	}
+	if utf8.RuneCountInString(text) > maxTextLength {
+		return c.Reply(c.T("todo.too_long", "max", maxTextLength))
+	}
+
+	it := &Item{Text: text, Assignee: assignee(m), CreatedBy: m.From.ID, Created: time.Now()}
+
+	if when != "" {
+		zone, loc, err := p.reminders.Zone(m.From.ID)
+		if err != nil {
+			return err
+		}
+		due, err := remind.ParseTime(when, time.Now(), loc)
+		if err == nil && due.Every != "" {
+			err = errRepeated
+		}
+		if err != nil {
+			return c.Reply(c.T("todo.invalid_due", "error", err))
+		}
+		it.Due, it.Zone = due.At, zone
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, err := p.lists.get(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if len(l.Items) >= maxItems {
+		return c.Reply(c.T("todo.full", "max", maxItems))
+	}
+
+	it.ID = l.NextID
+	l.NextID++
+	if err := p.remind(c, l, it); err != nil {
+		return err
+	}
+	l.Items = append(l.Items, it)
+	if err := p.lists.put(l); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("todo.added", "id", it.ID))
+}
+
+// remind schedules the reminder of the item if it is due in the future.
+func (p *Planner) remind(c *bot.Context, l *List, it *Item) error {
+	if it.Due.IsZero() || it.Done || !it.Due.After(time.Now()) {
+		return nil
+	}
+
+	r := &remind.Reminder{
+		ChatID: l.ChatID,
+		UserID: it.CreatedBy,
+		Name:   c.From().FirstName,
+		Text:   c.T("todo.reminder", "id", it.ID, "text", it.Text),
+		Lang:   c.Lang(),
+		Zone:   it.Zone,
+	}
+	if a := it.Assignee; a != nil && a.ID != 0 {
+		r.UserID, r.Name = a.ID, a.Name
+	}
+	r.At = it.Due
+
+	if err := p.reminders.Add(r); err != nil {
+		return err
+	}
+	it.ReminderID = r.ID
+
+	return nil
+}
+
+// cancel deletes the reminder of the item.
+func (p *Planner) cancel(it *Item) error {
+	if it.ReminderID == "" {
+		return nil
+	}
+	if err := p.reminders.Cancel(it.ReminderID); err != nil {
+		return err
+	}
+	it.ReminderID = ""
+	return nil
+}
+
+// change applies fn to the item after checking the sender may change it.
+func (p *Planner) change(c *bot.Context, id int, fn func(c *bot.Context, l *List, it *Item) error) error {
+	chat := c.Chat()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, err := p.lists.get(chat.ID)
+	if err != nil {
+		return err
+	}
+	it := l.Item(id)
+	if it == nil {
+		return p.reply(c, c.T("todo.not_found", "id", id), false)
+	}
+
+	ok, err := p.allowed(c, it)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return p.reply(c, c.T("todo.forbidden"), true)
+	}
+
+	if err := fn(c, l, it); err != nil {
+		return err
+	}
+	if err := p.lists.put(l); err != nil {
+		return err
+	}
+
+	return p.refresh(c, l)
+}
+
+func (p *Planner) toggle(c *bot.Context, l *List, it *Item) error {
+	it.Done = !it.Done
+	if !it.Done {
+		it.DoneBy = 0
+		return p.remind(c, l, it)
+	}
+	it.DoneBy = c.From().ID
+	return p.cancel(it)
+}
+
+func (p *Planner) remove(c *bot.Context, l *List, it *Item) error {
+	l.Remove(func(other *Item) bool { return other == it })
+	return p.cancel(it)
+}
+
+// clear handles /todo clear, removing the done items.
+func (p *Planner) clear(c *bot.Context) error {
+	chat := c.Chat()
+
+	ok, err := p.isAdmin(c)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return p.reply(c, c.T("todo.forbidden"), true)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	l, err := p.lists.get(chat.ID)
+	if err != nil {
+		return err
+	}
+	l.Remove(func(it *Item) bool { return it.Done })
+	if err := p.lists.put(l); err != nil {
+		return err
+	}
+
+	return p.refresh(c, l)
+}
+
+// export sends the list as a Markdown document.
+func (p *Planner) export(c *bot.Context) error {
+	chat := c.Chat()
+
+	l, err := p.lists.get(chat.ID)
+	if err != nil {
+		return err
+	}
+	if len(l.Items) == 0 {
+		return p.reply(c, c.T("todo.empty"), false)
+	}
+
+	title := chat.Title
+	if title == "" {
+		title = c.From().FirstName
+	}
+	doc := tgbotapi.NewDocumentUpload(chat.ID, tgbotapi.FileBytes{
+		Name:  "todo.md",
+		Bytes: []byte(l.Markdown(c.T("todo.export_title", "chat", title))),
+	})
+	if _, err := c.Send(doc); err != nil {
+		return err
+	}
+
+	if c.Update.CallbackQuery != nil {
+		return c.Answer("", false)
+	}
+	return nil
+}
+
+// press handles the list buttons.
+func (p *Planner) press(c *bot.Context) error {
+	q := c.Update.CallbackQuery
+	if q.Message == nil {
+		return c.Answer("", false)
+	}
+
+	action := strings.TrimPrefix(q.Data, callbackPrefix)
+	switch {
+	case strings.HasPrefix(action, "toggle:"):
+		id, err := strconv.Atoi(strings.TrimPrefix(action, "toggle:"))
+		if err != nil {
+			return c.Answer("", false)
+		}
+		return p.change(c, id, p.toggle)
+	case action == "clear":
+		return p.clear(c)
+	case action == "export":
+		return p.export(c)
+	}
+
+	return c.Answer("", false)
+}
+
+// refresh shows the changed list: the list message is edited when a button
+// was pressed, and sent again after a command.
+func (p *Planner) refresh(c *bot.Context, l *List) error {
+	q := c.Update.CallbackQuery
+	if q == nil {
+		msg := tgbotapi.NewMessage(l.ChatID, p.text(c, l))
+		if len(l.Items) > 0 {
+			msg.ReplyMarkup = keyboard(c, l)
+		}
+		_, err := c.Send(msg)
+		return err
+	}
+
+	edit := tgbotapi.NewEditMessageText(l.ChatID, q.Message.MessageID, p.text(c, l))
+	if len(l.Items) > 0 {
+		markup := keyboard(c, l)
+		edit.ReplyMarkup = &markup
+	}
+	if _, err := c.Send(edit); err != nil {
+		return err
+	}
+
+	return c.Answer("", false)
+}
+
+// reply answers the button press or the command.
+func (p *Planner) reply(c *bot.Context, text string, alert bool) error {
+	if c.Update.CallbackQuery != nil {
+		return c.Answer(text, alert)
+	}
+	return c.Reply(text)
+}
+
+// allowed reports whether the sender can change the item: its author, its
+// assignee and the admins can, and everyone if it is not assigned.
+func (p *Planner) allowed(c *bot.Context, it *Item) (bool, error) {
+	u := c.From()
+	if it.Assignee == nil || it.CreatedBy == u.ID || it.Assignee.Is(u.ID, u.UserName) {
+		return true, nil
+	}
+	return p.isAdmin(c)
+}
+
+// isAdmin reports whether the sender manages the list: everyone in private
+// chats, the admins in groups.
+func (p *Planner) isAdmin(c *bot.Context) (bool, error) {
+	chat := c.Chat()
+	if chat.IsPrivate() {
+		return true, nil
+	}
+	return p.mod.IsAdmin(chat.ID, c.From().ID)
+}
+
+// text renders the list. The item texts are shortened until the list fits
+// in a message, which the list is edited in.
+func (p *Planner) text(c *bot.Context, l *List) string {
+	if len(l.Items) == 0 {
+		return c.T("todo.empty")
+	}
+
+	for limit := maxTextLength; ; limit /= 2 {
+		lines := []string{c.T("todo.title", "open", l.Open(), "done", len(l.Items)-l.Open()), ""}
+		for _, it := range l.Items {
+			line := strconv.Itoa(it.ID) + ". " + check(it.Done) + " " + shorten(it.Text, limit)
+			if details := it.details(); details != "" {
+				line += " — " + details
+			}
+			lines = append(lines, line)
+		}
+
+		text := strings.Join(lines, "\n")
+		if render.Length(text) <= render.MaxMessageLength || limit <= minShownLength {
+			return text
+		}
+	}
+}
+
+func keyboard(c *bot.Context, l *List) tgbotapi.InlineKeyboardMarkup {
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(l.Items)+1)
+	for _, it := range l.Items {
+		label := shorten(strconv.Itoa(it.ID)+". "+it.Text, maxLabelLength)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			check(it.Done)+" "+label, callbackPrefix+"toggle:"+strconv.Itoa(it.ID))))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(c.T("todo.clear_button"), callbackPrefix+"clear"),
+		tgbotapi.NewInlineKeyboardButtonData(c.T("todo.export_button"), callbackPrefix+"export"),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// shorten cuts s to n characters, ending it with an ellipsis if it is cut.
+func shorten(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(append(r[:n-1], '…'))
+}
+
+func check(done bool) string {
+	if done {
+		return "✅"
+	}
+	return "⬜"
+}
+
+// splitCommand splits the arguments of /todo into the lowercase subcommand and
+// its arguments.
+func splitCommand(args string) (sub, rest string) {
+	args = strings.TrimSpace(args)
+	sub = args
+	if i := strings.IndexAny(args, " \n"); i >= 0 {
+		sub, rest = args[:i], strings.TrimSpace(args[i+1:])
+	}
+	return strings.ToLower(sub), rest
+}
+
+// splitDue splits the arguments of /todo add into the item text and the due
+// date after the last "due" between spaces, in any case, which is empty if
+// there is none.
+func splitDue(args string) (text, when string) {
+	// The word is matched in place, since changing the case of the text can
+	// change its length.
+	for i := len(args) - len(dueWord) - 2; i >= 0; i-- {
+		end := i + 1 + len(dueWord)
+		if args[i] == ' ' && args[end] == ' ' && strings.EqualFold(args[i+1:end], dueWord) {
+			return strings.TrimSpace(args[:i]), strings.TrimSpace(args[end:])
+		}
+	}
+	return strings.TrimSpace(args), ""
+}
+
+// assignee returns the first member mentioned in the message, or nil.
+func assignee(m *tgbotapi.Message) *Assignee {
+	if m.Entities == nil {
+		return nil
+	}
+
+	for _, e := range *m.Entities {
+		switch {
+		case e.Type == "text_mention" && e.User != nil:
+			return &Assignee{ID: e.User.ID, Username: e.User.UserName, Name: e.User.FirstName}
+		case e.Type == "mention":
+			username := strings.TrimPrefix(bot.EntityText(m.Text, e), "@")
+			return &Assignee{Username: username, Name: username}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package todo keeps a todo list per chat: a personal one in private chats
+// and a shared one in groups.
+package todo
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/remind"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	// listsBucket stores the lists by chat ID.
+	listsBucket = "todo"
+	// maxItems limits the list, so its buttons fit one inline keyboard.
+	maxItems = 50
+	// maxTextLength limits the item texts in characters.
+	maxTextLength = 256
+	// dueLayout formats the due dates.
+	dueLayout = "Mon, 02 Jan 15:04 MST"
+)
+
+var errRepeated = errors.New("repeated due dates are not supported")
+
+// List is the todo list of a chat.
+type List struct {
+	ChatID int64   `json:"chat_id"`
+	NextID int     `json:"next_id"`
+	Items  []*Item `json:"items"`
+}
+
+// Item is a task of a todo list.
+type Item struct {
+	// ID is the number of the item in the list.
+	ID   int    `json:"id"`
+	Text string `json:"text"`
+
+	Done   bool `json:"done,omitempty"`
+	DoneBy int  `json:"done_by,omitempty"`
+
+	Assignee *Assignee `json:"assignee,omitempty"`
+
+	Due time.Time `json:"due,omitempty"`
+	// Zone is the time zone the due date is shown in.
+	Zone string `json:"zone,omitempty"`
+	// ReminderID is the reminder sent when the item is due.
+	ReminderID string `json:"reminder_id,omitempty"`
+
+	CreatedBy int       `json:"created_by"`
+	Created   time.Time `json:"created"`
+}
+
+// Assignee is the group member an item is assigned to. Members mentioned by
+// username have no known ID.
+type Assignee struct {
+	ID       int    `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// String returns the mention of the assignee.
+func (a *Assignee) String() string {
+	if a.Username != "" {
+		return "@" + a.Username
+	}
+	return a.Name
+}
+
+// Is reports whether the user is the assignee.
+func (a *Assignee) Is(userID int, username string) bool {
+	if a.ID != 0 {
+		return a.ID == userID
+	}
+	return username != "" && strings.EqualFold(a.Username, username)
+}
+
+// Item returns the item with the ID or nil.
+func (l *List) Item(id int) *Item {
+	for _, it := range l.Items {
+		if it.ID == id {
+			return it
+		}
+	}
+	return nil
+}
+
+// Remove removes the items matching the filter and returns them.
+func (l *List) Remove(match func(it *Item) bool) []*Item {
+	var removed []*Item
+	kept := l.Items[:0]
+	for _, it := range l.Items {
+		if match(it) {
+			removed = append(removed, it)
+		} else {
+			kept = append(kept, it)
+		}
+	}
+	l.Items = kept
+	return removed
+}
+
+// Open returns the number of items not done.
+func (l *List) Open() int {
+	n := 0
+	for _, it := range l.Items {
+		if !it.Done {
+			n++
+		}
+	}
+	return n
+}
+
+// Markdown formats the list as a Markdown document with task list items.
+func (l *List) Markdown(title string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", title)
+	for _, it := range l.Items {
+		check := " "
+		if it.Done {
+			check = "x"
+		}
+		fmt.Fprintf(&b, "- [%s] %s", check, it.Text)
+		if details := it.details(); details != "" {
+			fmt.Fprintf(&b, " (%s)", details)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// details describes the assignee and the due date of the item.
+func (it *Item) details() string {
+	var parts []string
+	if it.Assignee != nil && !strings.Contains(it.Text, it.Assignee.String()) {
+		parts = append(parts, it.Assignee.String())
+	}
+	if !it.Due.IsZero() {
+		loc, err := remind.LoadZone(it.Zone)
+		if err != nil {
+			loc = time.UTC
+		}
+		parts = append(parts, "⏰ "+it.Due.In(loc).Format(dueLayout))
+	}
+	return strings.Join(parts, ", ")
+}
+
+type lists struct {
+	store storage.Store
+}
+
+// get returns the list of the chat, which is empty if it was never stored.
+func (s lists) get(chatID int64) (*List, error) {
+	l := &List{ChatID: chatID, NextID: 1}
+	err := s.store.Get(listsBucket, strconv.FormatInt(chatID, 10), l)
+	if err == storage.ErrNotFound {
+		err = nil
+	}
+	return l, err
+}
+
+func (s lists) put(l *List) error {
+	return s.store.Put(listsBucket, strconv.FormatInt(l.ChatID, 10), l)
+}
@@ -0,0 +1,199 @@
+package todo
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		args      string
+		sub, rest string
+	}{
+		{"", "", ""},
+		{"  ", "", ""},
+		{"add buy milk", "add", "buy milk"},
+		{"Done 3", "done", "3"},
+		{"DEL  #4 ", "del", "#4"},
+		{"add\nfirst line\nsecond", "add", "first line\nsecond"},
+		{"export", "export", ""},
+	}
+	for _, tt := range tests {
+		sub, rest := splitCommand(tt.args)
+		if sub != tt.sub || rest != tt.rest {
+			t.Errorf("splitCommand(%q) = %q, %q, want %q, %q", tt.args, sub, rest, tt.sub, tt.rest)
+		}
+	}
+}
+
+func TestSplitDue(t *testing.T) {
+	tests := []struct {
+		args       string
+		text, when string
+	}{
+		{"order pizza", "order pizza", ""},
+		{"order pizza due friday 18:00", "order pizza", "friday 18:00"},
+		{"order pizza DUE tomorrow", "order pizza", "tomorrow"},
+		{"pay dues due in 2 days", "pay dues", "in 2 days"},
+		{"check the due dates due monday", "check the due dates", "monday"},
+		{"due tomorrow", "due tomorrow", ""},
+		{"pay Due Friday", "pay", "Friday"},
+		// Changing the case of these characters changes their length.
+		{"ȺȺȺȺȺȺȺȺ due x", "ȺȺȺȺȺȺȺȺ", "x"},
+		{"Ⱥ DUE x", "Ⱥ", "x"},
+		{"İİİİİİİİİİ due 5m", "İİİİİİİİİİ", "5m"},
+		{"ſhip it due 5m", "ſhip it", "5m"},
+		{"x due", "x due", ""},
+		{"", "", ""},
+	}
+	for _, tt := range tests {
+		text, when := splitDue(tt.args)
+		if text != tt.text || when != tt.when || !utf8.ValidString(text) || !utf8.ValidString(when) {
+			t.Errorf("splitDue(%q) = %q, %q, want %q, %q", tt.args, text, when, tt.text, tt.when)
+		}
+	}
+}
+
+func TestTextFits(t *testing.T) {
+	bundle, err := i18n.Load(filepath.Join("..", "..", "locales"), "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &bot.Context{Bot: bot.New(&tgbotapi.BotAPI{}, storage.NewMemory(), bundle)}
+	p := &Planner{}
+
+	l := &List{}
+	for i := 1; i <= maxItems; i++ {
+		l.Items = append(l.Items, &Item{ID: i, Text: strings.Repeat("я", maxTextLength), Assignee: &Assignee{Username: "someone"}})
+	}
+	text := p.text(c, l)
+	if n := render.Length(text); n > render.MaxMessageLength {
+		t.Errorf("a full list is %d characters long", n)
+	}
+	if !strings.Contains(text, "…") || !strings.Contains(text, "@someone") {
+		t.Errorf("text() = %q", text)
+	}
+
+	l.Items = l.Items[:2]
+	l.Items[0].Text = "order pizza"
+	if text := p.text(c, l); !strings.Contains(text, "order pizza —") || strings.Count(text, "я") != maxTextLength {
+		t.Errorf("short items are cut: %q", text)
+	}
+}
+
+func TestShorten(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"pizza", 5, "pizza"},
+		{"pizzas", 5, "pizz…"},
+		{"пицца!", 5, "пицц…"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := shorten(tt.s, tt.n); got != tt.want {
+			t.Errorf("shorten(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAssignee(t *testing.T) {
+	alice := &tgbotapi.User{ID: 7, UserName: "alice", FirstName: "Alice"}
+	tests := []struct {
+		name     string
+		text     string
+		entities []tgbotapi.MessageEntity
+		want     *Assignee
+	}{
+		{"no entities", "order pizza", nil, nil},
+		{"mention", "ask @bob today", []tgbotapi.MessageEntity{{Type: "mention", Offset: 4, Length: 4}}, &Assignee{Username: "bob", Name: "bob"}},
+		{"text mention", "ask Alice", []tgbotapi.MessageEntity{{Type: "text_mention", Offset: 4, Length: 5, User: alice}}, &Assignee{ID: 7, Username: "alice", Name: "Alice"}},
+		{"first one wins", "@bob or @carol", []tgbotapi.MessageEntity{{Type: "mention", Offset: 0, Length: 4}, {Type: "mention", Offset: 8, Length: 6}}, &Assignee{Username: "bob", Name: "bob"}},
+		{"after emoji", "🍕 @bob", []tgbotapi.MessageEntity{{Type: "mention", Offset: 3, Length: 4}}, &Assignee{Username: "bob", Name: "bob"}},
+		{"other entities", "see https://example.com", []tgbotapi.MessageEntity{{Type: "url", Offset: 4, Length: 19}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &tgbotapi.Message{Text: tt.text}
+			if tt.entities != nil {
+				m.Entities = &tt.entities
+			}
+			got := assignee(m)
+			switch {
+			case got == nil || tt.want == nil:
+				if got != tt.want {
+					t.Errorf("assignee() = %+v, want %+v", got, tt.want)
+				}
+			case *got != *tt.want:
+				t.Errorf("assignee() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestAssigneeIs(t *testing.T) {
+	tests := []struct {
+		a        Assignee
+		userID   int
+		username string
+		want     bool
+	}{
+		{Assignee{ID: 7}, 7, "", true},
+		{Assignee{ID: 7, Username: "alice"}, 8, "alice", false},
+		{Assignee{Username: "Alice"}, 8, "alice", true},
+		{Assignee{Username: "alice"}, 8, "", false},
+		{Assignee{}, 8, "", false},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Is(tt.userID, tt.username); got != tt.want {
+			t.Errorf("%+v.Is(%d, %q) = %v, want %v", tt.a, tt.userID, tt.username, got, tt.want)
+		}
+	}
+}
+
+func TestList(t *testing.T) {
+	due := time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)
+	l := &List{Items: []*Item{
+		{ID: 1, Text: "order pizza", Done: true},
+		{ID: 2, Text: "call @bob", Assignee: &Assignee{Username: "bob"}},
+		{ID: 3, Text: "book a room", Assignee: &Assignee{Username: "carol"}, Due: due, Zone: "UTC"},
+	}}
+
+	if got := l.Open(); got != 2 {
+		t.Errorf("Open() = %d, want 2", got)
+	}
+	if it := l.Item(2); it == nil || it.Text != "call @bob" {
+		t.Errorf("Item(2) = %+v", it)
+	}
+	if it := l.Item(4); it != nil {
+		t.Errorf("Item(4) = %+v, want nil", it)
+	}
+
+	want := "# Todo\n\n" +
+		"- [x] order pizza\n" +
+		"- [ ] call @bob\n" +
+		"- [ ] book a room (@carol, ⏰ Fri, 01 Mar 17:00 UTC)\n"
+	if got := l.Markdown("Todo"); got != want {
+		t.Errorf("Markdown() = %q, want %q", got, want)
+	}
+
+	removed := l.Remove(func(it *Item) bool { return it.Done })
+	if len(removed) != 1 || removed[0].ID != 1 {
+		t.Errorf("Remove() returned %+v, want item 1", removed)
+	}
+	if len(l.Items) != 2 || l.Items[0].ID != 2 || l.Items[1].ID != 3 {
+		t.Errorf("Items after Remove() = %+v, want items 2 and 3", l.Items)
+	}
+}
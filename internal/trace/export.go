@@ -0,0 +1,238 @@
+package trace
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Open creates the exporter of the kind: "otlp" sends spans of the service to
+// the OTLP/HTTP endpoint at target, "stdout" writes them to the standard
+// output and "file" appends them to the file at target, both as JSON lines.
+func Open(kind, target, service string) (Exporter, error) {
+	switch kind {
+	case "otlp":
+		if target == "" {
+			target = "http://localhost:4318"
+		}
+		return NewOTLPExporter(target, service, nil), nil
+	case "stdout":
+		return NewWriterExporter(nopCloser{os.Stdout}), nil
+	case "file":
+		if target == "" {
+			return nil, fmt.Errorf("trace: the file exporter needs a path")
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(target, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return NewWriterExporter(f), nil
+	}
+	return nil, fmt.Errorf("trace: unknown exporter %q", kind)
+}
+
+// WriterExporter writes spans as JSON lines.
+type WriterExporter struct {
+	mu sync.Mutex
+	w  io.WriteCloser
+}
+
+// NewWriterExporter creates an exporter writing to w. Close closes w.
+func NewWriterExporter(w io.WriteCloser) *WriterExporter {
+	return &WriterExporter{w: w}
+}
+
+// Export implements Exporter.
+func (e *WriterExporter) Export(spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	bw := bufio.NewWriter(e.w)
+	enc := json.NewEncoder(bw)
+	for _, s := range spans {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Close implements Exporter.
+func (e *WriterExporter) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.w.Close()
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// OTLPExporter sends spans to an OpenTelemetry collector with the OTLP/HTTP
+// protocol in its JSON encoding.
+type OTLPExporter struct {
+	url     string
+	service string
+	client  *http.Client
+}
+
+// NewOTLPExporter creates an exporter posting to the /v1/traces path of the
+// endpoint. A nil client means a client with a 10 second timeout.
+//
+// The client must not be the one of the Bot API, or exporting would record
+// spans of its own.
+func NewOTLPExporter(endpoint, service string, client *http.Client) *OTLPExporter {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OTLPExporter{
+		url:     strings.TrimSuffix(endpoint, "/") + "/v1/traces",
+		service: service,
+		client:  client,
+	}
+}
+
+// Export implements Exporter.
+func (e *OTLPExporter) Export(spans []*Span) error {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		return err
+	}
+
+	resp, err := e.client.Post(e.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("trace: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Close implements Exporter.
+func (e *OTLPExporter) Close() error {
+	return nil
+}
+
+// The OTLP/JSON request, see opentelemetry/proto/collector/trace/v1.
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   otlpResource     `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope otlpScope  `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpSpan struct {
+		TraceID           string         `json:"traceId"`
+		SpanID            string         `json:"spanId"`
+		ParentSpanID      string         `json:"parentSpanId,omitempty"`
+		Name              string         `json:"name"`
+		Kind              Kind           `json:"kind"`
+		StartTimeUnixNano string         `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+		Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+		Status            *otlpStatus    `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string                 `json:"key"`
+		Value map[string]interface{} `json:"value"`
+	}
+)
+
+// otlpStatusError is STATUS_CODE_ERROR.
+const otlpStatusError = 2
+
+func (e *OTLPExporter) request(spans []*Span) otlpRequest {
+	out := make([]otlpSpan, len(spans))
+	for i, s := range spans {
+		out[i] = otlpSpan{
+			TraceID:           s.TraceID,
+			SpanID:            s.SpanID,
+			ParentSpanID:      s.ParentID,
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        otlpAttributes(s.Attributes),
+		}
+		if s.Error != "" {
+			out[i].Status = &otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: otlpAttributes(map[string]interface{}{
+			"service.name": e.service,
+		})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/nskondratev/go-telegram-bot-example/internal/trace"},
+			Spans: out,
+		}},
+	}}}
+}
+
+// otlpAttributes converts the attributes sorted by key. 64-bit integers are
+// strings in OTLP/JSON.
+func otlpAttributes(attrs map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]otlpKeyValue, 0, len(keys))
+	for _, k := range keys {
+		var v map[string]interface{}
+		switch a := attrs[k].(type) {
+		case string:
+			v = map[string]interface{}{"stringValue": a}
+		case bool:
+			v = map[string]interface{}{"boolValue": a}
+		case int:
+			v = map[string]interface{}{"intValue": strconv.Itoa(a)}
+		case int64:
+			v = map[string]interface{}{"intValue": strconv.FormatInt(a, 10)}
+		case float64:
+			v = map[string]interface{}{"doubleValue": a}
+		default:
+			v = map[string]interface{}{"stringValue": fmt.Sprint(a)}
+		}
+		kvs = append(kvs, otlpKeyValue{Key: k, Value: v})
+	}
+	return kvs
+}
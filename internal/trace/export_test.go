@@ -0,0 +1,116 @@
+package trace
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSpans() []*Span {
+	start := time.Unix(1700000000, 123)
+	return []*Span{
+		{
+			TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Name: "update",
+			Kind: KindInternal, Start: start, End: start.Add(time.Second),
+			Attributes: map[string]interface{}{"update.id": 42, "chat.id": int64(-100), "ok": true, "ratio": 0.5, "text": "hi", "other": time.Second},
+		},
+		{
+			TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "00f067aa0ba902b7", ParentID: "b7ad6b7169203331", Name: "telegram send",
+			Kind: KindClient, Start: start, End: start.Add(time.Millisecond), Error: "Forbidden: bot was blocked by the user",
+		},
+	}
+}
+
+func TestOTLPExport(t *testing.T) {
+	var path, contentType string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, contentType = r.URL.Path, r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	e := NewOTLPExporter(srv.URL+"/", "bot", srv.Client())
+	if err := e.Export(testSpans()); err != nil {
+		t.Fatal(err)
+	}
+
+	if path != "/v1/traces" || contentType != "application/json" {
+		t.Errorf("posted %s to %s, want application/json to /v1/traces", contentType, path)
+	}
+	want := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"bot"}}]},` +
+		`"scopeSpans":[{"scope":{"name":"github.com/nskondratev/go-telegram-bot-example/internal/trace"},"spans":[` +
+		`{"traceId":"0af7651916cd43dd8448eb211c80319c","spanId":"b7ad6b7169203331","name":"update","kind":1,` +
+		`"startTimeUnixNano":"1700000000000000123","endTimeUnixNano":"1700000001000000123","attributes":[` +
+		`{"key":"chat.id","value":{"intValue":"-100"}},{"key":"ok","value":{"boolValue":true}},` +
+		`{"key":"other","value":{"stringValue":"1s"}},{"key":"ratio","value":{"doubleValue":0.5}},` +
+		`{"key":"text","value":{"stringValue":"hi"}},{"key":"update.id","value":{"intValue":"42"}}]},` +
+		`{"traceId":"0af7651916cd43dd8448eb211c80319c","spanId":"00f067aa0ba902b7","parentSpanId":"b7ad6b7169203331",` +
+		`"name":"telegram send","kind":3,"startTimeUnixNano":"1700000000000000123","endTimeUnixNano":"1700000000001000123",` +
+		`"status":{"code":2,"message":"Forbidden: bot was blocked by the user"}}]}]}]}`
+	if string(body) != want {
+		t.Errorf("body:\n%s\nwant:\n%s", body, want)
+	}
+}
+
+func TestOTLPExportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad spans", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := NewOTLPExporter(srv.URL, "bot", srv.Client()).Export(testSpans())
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "bad spans") {
+		t.Errorf("Export() = %v, want the status and the message", err)
+	}
+}
+
+type closer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestWriterExport(t *testing.T) {
+	w := &closer{}
+	e := NewWriterExporter(w)
+	if err := e.Export(testSpans()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil || !w.closed {
+		t.Errorf("Close() = %v, closed = %v", err, w.closed)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("wrote %d lines, want a line per span:\n%s", len(lines), w.String())
+	}
+	if !strings.HasPrefix(lines[1], `{"trace_id":"0af7651916cd43dd8448eb211c80319c","span_id":"00f067aa0ba902b7","parent_id":"b7ad6b7169203331","name":"telegram send","kind":3,`) ||
+		!strings.HasSuffix(lines[1], `"error":"Forbidden: bot was blocked by the user"}`) {
+		t.Errorf("second line = %s", lines[1])
+	}
+}
+
+func TestOpen(t *testing.T) {
+	if _, err := Open("zipkin", "", "bot"); err == nil {
+		t.Error("Open(zipkin) succeeded")
+	}
+	if _, err := Open("file", "", "bot"); err == nil {
+		t.Error("Open(file) without a path succeeded")
+	}
+	e, err := Open("otlp", "", "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if url := e.(*OTLPExporter).url; url != "http://localhost:4318/v1/traces" {
+		t.Errorf("default OTLP URL = %s", url)
+	}
+}
@@ -0,0 +1,197 @@
+// Package trace records spans of the update handling and the Bot API calls
+// and exports them in batches, to an OpenTelemetry collector or to a file.
+//
+// A nil *Tracer and a nil *Span are valid and record nothing, so the
+// instrumented code doesn't check whether tracing is enabled.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sync"
+	"time"
+)
+
+// batchSize is the number of spans exported together.
+const batchSize = 256
+
+// flushInterval is how often the finished spans are exported.
+const flushInterval = 5 * time.Second
+
+// Kind tells whether a span is internal work or a call to another service.
+type Kind int
+
+// Span kinds, the values match OpenTelemetry.
+const (
+	KindInternal Kind = 1
+	KindClient   Kind = 3
+)
+
+// Span is a timed operation within a trace.
+type Span struct {
+	TraceID    string                 `json:"trace_id"`
+	SpanID     string                 `json:"span_id"`
+	ParentID   string                 `json:"parent_id,omitempty"`
+	Name       string                 `json:"name"`
+	Kind       Kind                   `json:"kind"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+	Error      string                 `json:"error,omitempty"`
+
+	tracer *Tracer
+}
+
+// SetAttribute sets an attribute of the span. Values should be strings,
+// booleans or numbers.
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	if s.Attributes == nil {
+		s.Attributes = make(map[string]interface{})
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed. A nil error does nothing.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Error = err.Error()
+}
+
+// Child starts a span of the same trace with s as the parent.
+func (s *Span) Child(name string, kind Kind) *Span {
+	if s == nil {
+		return nil
+	}
+	c := s.tracer.newSpan(name, kind)
+	c.TraceID = s.TraceID
+	c.ParentID = s.SpanID
+	return c
+}
+
+// Finish ends the span and queues it for the export. The span must not be
+// changed afterwards.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	s.tracer.queue(s)
+}
+
+// Exporter sends finished spans to their destination.
+type Exporter interface {
+	Export(spans []*Span) error
+	Close() error
+}
+
+// Tracer creates spans and exports them when they are finished.
+type Tracer struct {
+	exporter Exporter
+	spans    chan *Span
+
+	mu      sync.Mutex
+	dropped int
+}
+
+// New creates a tracer exporting spans with the exporter. Spans are exported
+// while Run is running.
+func New(exporter Exporter) *Tracer {
+	return &Tracer{
+		exporter: exporter,
+		spans:    make(chan *Span, 4*batchSize),
+	}
+}
+
+// Start starts a span of a new trace.
+func (t *Tracer) Start(name string, kind Kind) *Span {
+	if t == nil {
+		return nil
+	}
+	s := t.newSpan(name, kind)
+	s.TraceID = newID(16)
+	return s
+}
+
+func (t *Tracer) newSpan(name string, kind Kind) *Span {
+	return &Span{SpanID: newID(8), Name: name, Kind: kind, Start: time.Now(), tracer: t}
+}
+
+// queue adds a finished span to the next batch. Spans are dropped rather
+// than slowing the bot down when the exporter falls behind.
+func (t *Tracer) queue(s *Span) {
+	select {
+	case t.spans <- s:
+	default:
+		t.mu.Lock()
+		t.dropped++
+		t.mu.Unlock()
+	}
+}
+
+// Run exports the finished spans until the context is done, then exports the
+// remaining ones and closes the exporter.
+func (t *Tracer) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); err != nil {
+			log.Printf("Failed to export %d spans: %s", len(batch), err)
+		}
+		batch = batch[:0]
+
+		t.mu.Lock()
+		dropped := t.dropped
+		t.dropped = 0
+		t.mu.Unlock()
+		if dropped > 0 {
+			log.Printf("Dropped %d spans, the exporter is too slow", dropped)
+		}
+	}
+
+	for {
+		select {
+		case s := <-t.spans:
+			if batch = append(batch, s); len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			for {
+				select {
+				case s := <-t.spans:
+					if batch = append(batch, s); len(batch) == batchSize {
+						flush()
+					}
+				default:
+					flush()
+					if err := t.exporter.Close(); err != nil {
+						log.Printf("Failed to close the span exporter: %s", err)
+					}
+					return
+				}
+			}
+		}
+	}
+}
+
+// newID returns a random hex encoded ID of n bytes.
+func newID(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,96 @@
+package welcome
+
+import (
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+)
+
+// off disables the welcome or goodbye message.
+const off = "off"
+
+// setWelcome handles /setwelcome [template|off]. Without a template the
+// default welcome message is used.
+func (g *Greeter) setWelcome(c *bot.Context) error {
+	return g.setMessage(c, "welcome", "welcome.default", func(w *settings.Welcome, on bool, tmpl string) {
+		w.WelcomeOn, w.Welcome = on, tmpl
+	})
+}
+
+// setGoodbye handles /setgoodbye [template|off]. Without a template the
+// default goodbye message is used.
+func (g *Greeter) setGoodbye(c *bot.Context) error {
+	return g.setMessage(c, "goodbye", "welcome.goodbye_default", func(w *settings.Welcome, on bool, tmpl string) {
+		w.GoodbyeOn, w.Goodbye = on, tmpl
+	})
+}
+
+func (g *Greeter) setMessage(c *bot.Context, name, key string, set func(w *settings.Welcome, on bool, tmpl string)) error {
+	m := c.Update.Message
+
+	tmpl := strings.TrimSpace(m.CommandArguments())
+	if strings.ToLower(tmpl) == off {
+		if err := g.update(c, name+"="+off, func(w *settings.Welcome) { set(w, false, "") }); err != nil {
+			return err
+		}
+		return c.Reply(c.T("welcome.disabled"))
+	}
+
+	// The preview greets the admin, which also validates the template.
+	preview, err := g.render(m.Chat, m.From, tmpl, key)
+	if err == nil {
+		err = c.ReplyText(tgbotapi.ModeHTML, preview)
+	}
+	if err != nil {
+		return c.Reply(c.T("welcome.invalid", "error", err))
+	}
+
+	if err := g.update(c, name+"="+tmpl, func(w *settings.Welcome) { set(w, true, tmpl) }); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("welcome.saved"))
+}
+
+// setRules handles /setrules [text|URL|off].
+func (g *Greeter) setRules(c *bot.Context) error {
+	rules := strings.TrimSpace(c.Update.Message.CommandArguments())
+	switch {
+	case rules == "":
+		return c.Reply(c.T("welcome.rules_usage"))
+	case strings.ToLower(rules) == off:
+		rules = ""
+	}
+
+	if err := g.update(c, "rules="+rules, func(w *settings.Welcome) { w.Rules = rules }); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("welcome.saved"))
+}
+
+// cleanWelcome handles /cleanwelcome on|off.
+func (g *Greeter) cleanWelcome(c *bot.Context) error {
+	arg := strings.ToLower(strings.TrimSpace(c.Update.Message.CommandArguments()))
+	if arg != "on" && arg != off {
+		return c.Reply(c.T("welcome.clean_usage"))
+	}
+
+	if err := g.update(c, "clean_welcome="+arg, func(w *settings.Welcome) { w.DeletePrevious = arg == "on" }); err != nil {
+		return err
+	}
+
+	return c.Reply(c.T("welcome.saved"))
+}
+
+// update changes the welcome settings of the chat on behalf of the sender.
+func (g *Greeter) update(c *bot.Context, change string, fn func(w *settings.Welcome)) error {
+	m := c.Update.Message
+	return g.bot.Settings.Update(m.Chat.ID, m.From.ID, change, func(chat *settings.Chat) error {
+		fn(&chat.Welcome)
+		return nil
+	})
+}
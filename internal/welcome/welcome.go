@@ -0,0 +1,229 @@
+// Package welcome greets new group members and says goodbye to leaving ones
+// with messages configured by the group admins.
+package welcome
+
+import (
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/render"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+const (
+	// lastBucket stores the ID of the last welcome message per chat.
+	lastBucket = "welcome_last"
+	// rulesCallback is the data of the rules button.
+	rulesCallback = "welcome:rules"
+	// maxAlertLength is the length limit of callback query alerts.
+	maxAlertLength = 200
+)
+
+// data is available to the welcome and goodbye templates.
+type data struct {
+	User  *tgbotapi.User
+	Title string
+	Count int
+}
+
+// Greeter sends the welcome and goodbye messages.
+type Greeter struct {
+	bot *bot.Bot
+	mod *moderation.Moderator
+}
+
+// New creates a greeter. Its commands are available to the group admins
+// verified by the moderator.
+func New(b *bot.Bot, mod *moderation.Moderator) *Greeter {
+	return &Greeter{bot: b, mod: mod}
+}
+
+// Register adds the welcome and goodbye handlers and commands to the router.
+func (g *Greeter) Register(r *bot.Router) {
+	r.Command("setwelcome", g.mod.OnlyAdmins(g.setWelcome))
+	r.Command("setgoodbye", g.mod.OnlyAdmins(g.setGoodbye))
+	r.Command("setrules", g.mod.OnlyAdmins(g.setRules))
+	r.Command("cleanwelcome", g.mod.OnlyAdmins(g.cleanWelcome))
+	r.Command("rules", g.rules)
+
+	r.Handle(joined, g.welcome)
+	r.Handle(left, g.goodbye)
+	r.Handle(bot.IsCallback(rulesCallback), g.rulesButton)
+}
+
+func joined(c *bot.Context) bool {
+	m := c.Update.Message
+	return m != nil && m.NewChatMembers != nil && (m.Chat.IsGroup() || m.Chat.IsSuperGroup())
+}
+
+func left(c *bot.Context) bool {
+	m := c.Update.Message
+	return m != nil && m.LeftChatMember != nil && (m.Chat.IsGroup() || m.Chat.IsSuperGroup())
+}
+
+func (g *Greeter) welcome(c *bot.Context) error {
+	m := c.Update.Message
+
+	chat, err := g.bot.Settings.Get(m.Chat.ID)
+	if err != nil || !chat.Welcome.WelcomeOn {
+		return err
+	}
+	cfg := chat.Welcome
+
+	for i := range *m.NewChatMembers {
+		u := &(*m.NewChatMembers)[i]
+		if u.IsBot {
+			continue
+		}
+
+		text, err := g.render(m.Chat, u, cfg.Welcome, "welcome.default")
+		if err != nil {
+			return err
+		}
+
+		msg := tgbotapi.NewMessage(m.Chat.ID, text)
+		msg.ParseMode = tgbotapi.ModeHTML
+		msg.ReplyToMessageID = m.MessageID
+		if cfg.Rules != "" {
+			msg.ReplyMarkup = rulesKeyboard(g.bot.I18n.Translate(g.lang(m.Chat.ID, u), "welcome.rules_button"), cfg.Rules)
+		}
+
+		sent, err := g.bot.Sender.Send(msg)
+		if err != nil {
+			return err
+		}
+
+		last := g.lastWelcome(m.Chat.ID)
+		if cfg.DeletePrevious && last != 0 {
+			if _, err := g.bot.API.DeleteMessage(tgbotapi.NewDeleteMessage(m.Chat.ID, last)); err != nil {
+				log.Printf("Failed to delete welcome message %d in chat %d: %s", last, m.Chat.ID, err)
+			}
+		}
+		if err := g.bot.Store.Put(lastBucket, strconv.FormatInt(m.Chat.ID, 10), sent.MessageID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// lastWelcome returns the ID of the last welcome message in the chat, or 0 if it is unknown.
+func (g *Greeter) lastWelcome(chatID int64) int {
+	var id int
+	err := g.bot.Store.Get(lastBucket, strconv.FormatInt(chatID, 10), &id)
+	if err != nil && err != storage.ErrNotFound {
+		log.Printf("Failed to load the last welcome message of chat %d: %s", chatID, err)
+	}
+	return id
+}
+
+func (g *Greeter) goodbye(c *bot.Context) error {
+	m := c.Update.Message
+	u := m.LeftChatMember
+	if u.IsBot {
+		return nil
+	}
+
+	chat, err := g.bot.Settings.Get(m.Chat.ID)
+	if err != nil || !chat.Welcome.GoodbyeOn {
+		return err
+	}
+
+	text, err := g.render(m.Chat, u, chat.Welcome.Goodbye, "welcome.goodbye_default")
+	if err != nil {
+		return err
+	}
+
+	msg := tgbotapi.NewMessage(m.Chat.ID, text)
+	msg.ParseMode = tgbotapi.ModeHTML
+	_, err = g.bot.Sender.Send(msg)
+
+	return err
+}
+
+// render renders the template of the chat, or the default template with the
+// key in the language of the user if it is empty.
+func (g *Greeter) render(chat *tgbotapi.Chat, u *tgbotapi.User, tmpl, key string) (string, error) {
+	if tmpl == "" {
+		tmpl = g.bot.I18n.Translate(g.lang(chat.ID, u), key)
+	}
+
+	count, err := g.bot.API.GetChatMembersCount(tgbotapi.ChatConfig{ChatID: chat.ID})
+	if err != nil {
+		log.Printf("Failed to get the member count of chat %d: %s", chat.ID, err)
+	}
+
+	// The templates are written by the group admins.
+	t, err := render.ParseUntrusted(tgbotapi.ModeHTML, key, tmpl)
+	if err != nil {
+		return "", err
+	}
+	return t.Execute(data{User: u, Title: chat.Title, Count: count})
+}
+
+// lang returns the language messages about the user are written in: the
+// language of the chat if its admins chose one, or the language of the user.
+func (g *Greeter) lang(chatID int64, u *tgbotapi.User) string {
+	var candidates []string
+	if chat, err := g.bot.Settings.Get(chatID); err == nil && chat.Language != "" {
+		candidates = append(candidates, chat.Language)
+	}
+	if lang, ok := g.bot.UserLanguage(u.ID); ok {
+		candidates = append(candidates, lang)
+	}
+	return g.bot.I18n.Match(append(candidates, u.LanguageCode)...)
+}
+
+func (g *Greeter) rules(c *bot.Context) error {
+	m := c.Update.Message
+
+	chat, err := g.bot.Settings.Get(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if chat.Welcome.Rules == "" {
+		return c.Reply(c.T("welcome.rules_none"))
+	}
+
+	return c.Reply(chat.Welcome.Rules)
+}
+
+func (g *Greeter) rulesButton(c *bot.Context) error {
+	m := c.Message()
+	if m == nil {
+		return c.Answer("", false)
+	}
+
+	chat, err := g.bot.Settings.Get(m.Chat.ID)
+	if err != nil {
+		return err
+	}
+	if chat.Welcome.Rules == "" {
+		return c.Answer(c.T("welcome.rules_none"), false)
+	}
+
+	rules := []rune(chat.Welcome.Rules)
+	if len(rules) > maxAlertLength {
+		// The full rules are available with /rules.
+		rules = append(rules[:maxAlertLength-1], '…')
+	}
+
+	return c.Answer(string(rules), true)
+}
+
+func rulesKeyboard(label, rules string) tgbotapi.InlineKeyboardMarkup {
+	button := tgbotapi.NewInlineKeyboardButtonData(label, rulesCallback)
+	if isURL(rules) {
+		button = tgbotapi.NewInlineKeyboardButtonURL(label, rules)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(button))
+}
+
+func isURL(s string) bool {
+	return !strings.ContainsAny(s, " \n") && (strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://"))
+}
@@ -1,64 +1,62 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
-
-	"github.com/go-telegram-bot-api/telegram-bot-api"
+	"strings"
 )
 
-func main() {
-	log.Printf("Provided API token: %s", os.Getenv("TELEGRAM_APITOKEN"))
-	bot, err := tgbotapi.NewBotAPI(os.Getenv("TELEGRAM_APITOKEN"))
-	if err != nil {
-		log.Fatal(err) // You should add better error handling than this!
-	}
-
-	bot.Debug = true // Has the library display every request and response.
-
-	// Create a new UpdateConfig struct with an offset of 0.
-	// Future requests can pass a higher offset to ensure there aren't duplicates.
-	updateConfig := tgbotapi.NewUpdate(0)
-
-	// Tell Telegram we want to keep the connection open longer and wait for incoming updates.
-	// This reduces the number of requests that are made while improving response time.
-	updateConfig.Timeout = 60
+// command is a subcommand of the bot binary.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
 
-	// Now we can pass our UpdateConfig struct to the library to start getting updates.
-	// The GetUpdatesChan method is opinionated and as such, it is reasonable to implement
-	// your own version of it. It is easier to use if you have no special requirements though.
-	updates, err := bot.GetUpdatesChan(updateConfig)
+// commands are the subcommands, run is the default one.
+var commands = []command{
+	{"run", "run [-bot NAME] [-webhook URL | -poll]", run},
+	{"webhook", "webhook [-bot NAME] set URL | delete | info", webhookCommand},
+	{"whoami", "whoami [-bot NAME]", whoami},
+	{"send", "send [-bot NAME] -chat ID -text TEXT [-parse-mode MODE]", send},
+	{"replay", "replay [-bot NAME] [-state file] recording.jsonl", replay},
+	{"migrate", "migrate [-status]", migrate},
+	{"config", "config validate", configCommand},
+}
 
-	if err != nil {
-		log.Fatalf("Error while trying to get updates chan: %s", err.Error())
+func main() {
+	// Flags without a command are the flags of run, so that starting the
+	// binary without arguments keeps running the bot.
+	name, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
 
-	// Now we're ready to start going through the updates we're given.
-	// Because we have a channel, we can range over it.
-	for update := range updates {
-		// There are many types of updates. We only care about messages right now,
-		// so we should ignore any other kinds.
-		if update.Message == nil {
-			continue
+	for _, c := range commands {
+		if c.name == name {
+			if err := c.run(args); err != nil {
+				log.Fatal(err)
+			}
+			return
 		}
+	}
 
-		// Because we have to create structs for every kind of request,
-		// there's a number of helper functions to make creating common
-		// types easier. Here, we're using the NewMessage helper which
-		// returns a MessageConfig struct.
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, update.Message.Text)
-
-		// As there's too many fields for each Config to specify in a single
-		// function call, we need to modify the result the helper gave us.
-		msg.ReplyToMessageID = update.Message.MessageID
+	if name != "help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	}
+	usage()
+	os.Exit(2)
+}
 
-		// We're ready to send our message!
-		// The Send method is for Configs that return a Message struct.
-		// Sending Messages (among many other types) return a Message.
-		// In this case, we don't care about the returned Message.
-		// We only need to make sure our message went through successfully.
-		if _, err := bot.Send(msg); err != nil {
-			panic(err) // Again, this is a bad way to handle errors.
-		}
+// usage prints the list of commands.
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: bot <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  bot %s\n", c.usage)
 	}
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Every command reads the configuration from the environment, see README.md.")
 }
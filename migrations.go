@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// migrations change the stored data to the schema of this version of the
+// bot. Append new ones with the next version, never change released ones.
+var migrations = []storage.Migration{
+	{Version: 1, Name: "merge the legacy welcome and antispam buckets into the chat settings", Up: settings.MigrateLegacy},
+}
+
+// migrate applies the pending migrations or lists them. The bot applies them
+// on start as well, the command lets them be applied ahead of an upgrade. The
+// bot must not be running, it keeps the state in memory.
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	status := fs.Bool("status", false, "list the pending migrations without applying them")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bot migrate [-status]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	names, err := config.Bots()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Offline(names[0])
+	if err != nil {
+		return err
+	}
+	if cfg.StoragePath == "" {
+		return fmt.Errorf("STORAGE_PATH is empty, the state in memory needs no migrations")
+	}
+	shared, err := storage.OpenFile(cfg.StoragePath)
+	if err != nil {
+		return err
+	}
+	defer shared.Close()
+
+	// Every bot has its own namespace with its own schema version, and every
+	// module of the bot its own schema version in the namespace.
+	for _, name := range names {
+		cfg, err := config.Offline(name)
+		if err != nil {
+			return err
+		}
+		host, err := newHost(nil, cfg)
+		if err != nil {
+			return err
+		}
+		store := namespace(shared, name)
+
+		of := ""
+		if name != "" {
+			of = " of bot " + name
+		}
+		if err := migrateSchema(store, "Schema version"+of, "", migrations, *status); err != nil {
+			return err
+		}
+		for _, m := range host.Modules() {
+			if migs := m.Migrations(); len(migs) > 0 {
+				if err := migrateSchema(store, "Schema version of module "+m.Name()+of, m.Name(), migs, *status); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// migrateSchema prints the version of the schema and its pending migrations,
+// and applies them unless status is set.
+func migrateSchema(store storage.Store, label, schema string, migrations []storage.Migration, status bool) error {
+	version, err := storage.SchemaVersion(store, schema)
+	if err != nil {
+		return err
+	}
+	pending, err := storage.Pending(store, schema, migrations)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %d, %d pending migrations\n", label, version, len(pending))
+
+	if status {
+		for _, m := range pending {
+			fmt.Printf("  %d  %s\n", m.Version, m.Name)
+		}
+		return nil
+	}
+
+	applied, err := storage.Migrate(store, schema, migrations)
+	for _, m := range applied {
+		fmt.Printf("Applied %d  %s\n", m.Version, m.Name)
+	}
+	return err
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/admin"
+	"github.com/nskondratev/go-telegram-bot-example/internal/antispam"
+	"github.com/nskondratev/go-telegram-bot-example/internal/audit"
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/broadcast"
+	"github.com/nskondratev/go-telegram-bot-example/internal/captcha"
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/cron"
+	"github.com/nskondratev/go-telegram-bot-example/internal/echo"
+	"github.com/nskondratev/go-telegram-bot-example/internal/language"
+	"github.com/nskondratev/go-telegram-bot-example/internal/moderation"
+	"github.com/nskondratev/go-telegram-bot-example/internal/module"
+	"github.com/nskondratev/go-telegram-bot-example/internal/notes"
+	"github.com/nskondratev/go-telegram-bot-example/internal/poll"
+	"github.com/nskondratev/go-telegram-bot-example/internal/remind"
+	"github.com/nskondratev/go-telegram-bot-example/internal/settings/editor"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+	"github.com/nskondratev/go-telegram-bot-example/internal/todo"
+	"github.com/nskondratev/go-telegram-bot-example/internal/welcome"
+)
+
+// modules returns the modules of a bot in the order they are registered in.
+// Commands are matched before other handlers, while the echo handler catches
+// every remaining message and therefore goes last. The admin middleware
+// ignoring banned users must run first, so admin comes first.
+func modules() []module.Module {
+	return []module.Module{
+		&adminModule{},
+		&broadcastModule{},
+		&captchaModule{},
+		&moderationModule{},
+		moderated("antispam", func(b *bot.Bot, mod *moderation.Moderator) registrar { return antispam.New(b, mod) }),
+		moderated("welcome", func(b *bot.Bot, mod *moderation.Moderator) registrar { return welcome.New(b, mod) }),
+		moderated("settings", func(b *bot.Bot, mod *moderation.Moderator) registrar { return editor.New(b, mod) }),
+		moderated("poll", func(b *bot.Bot, mod *moderation.Moderator) registrar { return poll.New(b, mod) }),
+		moderated("notes", func(b *bot.Bot, mod *moderation.Moderator) registrar { return notes.New(b, mod) }),
+		&remindModule{},
+		&todoModule{},
+		&jobsModule{},
+		handlers("language", language.Register),
+		handlers("echo", echo.Register),
+	}
+}
+
+// newHost creates the modules of the bot enabled by MODULES. The bot is nil
+// when the modules are only listed, never initialized.
+func newHost(b *bot.Bot, cfg config.Config) (*module.Host, error) {
+	return module.New(b, modules(), cfg.Modules)
+}
+
+// register initializes the modules, adding their handlers to the router of
+// the bot and their jobs to the scheduler shared by the bots of the process,
+// and adds the /modules command for the bot owners.
+func register(host *module.Host, b *bot.Bot, cfg config.Config, jobs *cron.Scheduler) error {
+	prefix := ""
+	if cfg.Name != "" {
+		prefix = cfg.Name + "/"
+	}
+	if err := host.Init(cfg, &module.Jobs{Scheduler: jobs, Prefix: prefix}); err != nil {
+		return err
+	}
+
+	// The bot owners are known to the admin module only, so without it
+	// nobody may toggle the modules.
+	if m, ok := host.Module("admin").(*adminModule); ok {
+		host.Register(b.Router, m.adm.Only)
+	}
+	return nil
+}
+
+// registrar is a feature adding its handlers to a router.
+type registrar interface {
+	Register(r *bot.Router)
+}
+
+// adminModule provides the commands of the bot owners.
+type adminModule struct {
+	module.Base
+	adm *admin.Admin
+}
+
+func (m *adminModule) Name() string { return "admin" }
+func (m *adminModule) Global() bool { return true }
+
+func (m *adminModule) Init(d *module.Deps) error {
+	m.adm = admin.New(d.Bot, d.Config.AdminIDs)
+	return nil
+}
+
+func (m *adminModule) Register(r *bot.Router, _ *module.Jobs) error {
+	m.adm.Register(r)
+	return nil
+}
+
+// broadcastModule sends the broadcasts of the bot owners to every chat.
+type broadcastModule struct {
+	module.Base
+	br *broadcast.Broadcaster
+}
+
+func (m *broadcastModule) Name() string       { return "broadcast" }
+func (m *broadcastModule) Global() bool       { return true }
+func (m *broadcastModule) Requires() []string { return []string{"admin"} }
+
+func (m *broadcastModule) Init(d *module.Deps) error {
+	adm := d.Module("admin").(*adminModule).adm
+	m.br = broadcast.New(d.Bot, adm.Only)
+	adm.AddStats(m.br.Stats)
+	adm.AddWhois(m.br.Whois)
+	return nil
+}
+
+func (m *broadcastModule) Register(r *bot.Router, _ *module.Jobs) error {
+	m.br.Register(r)
+	return nil
+}
+
+// Run sends the broadcasts in the background. A broadcast interrupted by the
+// shutdown is resumed on the next start.
+func (m *broadcastModule) Run(ctx context.Context) {
+	m.br.Run(ctx)
+}
+
+// captchaModule challenges new members, unless CAPTCHA_TIMEOUT is 0.
+type captchaModule struct {
+	module.Base
+	guard *captcha.Guard
+}
+
+func (m *captchaModule) Name() string { return "captcha" }
+
+func (m *captchaModule) Init(d *module.Deps) error {
+	if d.Config.CaptchaTimeout > 0 {
+		m.guard = captcha.New(d.Bot, d.Config.CaptchaTimeout)
+	}
+	return nil
+}
+
+func (m *captchaModule) Register(r *bot.Router, _ *module.Jobs) error {
+	if m.guard != nil {
+		m.guard.Register(r)
+	}
+	return nil
+}
+
+// Run kicks the members who didn't solve the challenge in time. Pending
+// challenges are stored, so new members who joined while the bot was down
+// are still kicked after the timeout.
+func (m *captchaModule) Run(ctx context.Context) {
+	if m.guard != nil {
+		m.guard.Run(ctx)
+	}
+}
+
+// moderationModule provides the moderation commands and the admin checks of
+// the chat features.
+type moderationModule struct {
+	module.Base
+	mod *moderation.Moderator
+}
+
+func (m *moderationModule) Name() string { return "moderation" }
+
+func (m *moderationModule) Init(d *module.Deps) error {
+	policy, err := moderation.ParsePolicy(d.Config.WarnActions)
+	if err != nil {
+		return fmt.Errorf("error while parsing WARN_ACTIONS: %s", err)
+	}
+	m.mod = moderation.New(d.Bot, policy)
+	return nil
+}
+
+func (m *moderationModule) Register(r *bot.Router, _ *module.Jobs) error {
+	m.mod.Register(r)
+	return nil
+}
+
+// moderatedModule is a chat feature managed by the chat admins.
+type moderatedModule struct {
+	module.Base
+	name    string
+	new     func(b *bot.Bot, mod *moderation.Moderator) registrar
+	feature registrar
+}
+
+// moderated returns the module of the name creating the feature with new.
+func moderated(name string, new func(b *bot.Bot, mod *moderation.Moderator) registrar) module.Module {
+	return &moderatedModule{name: name, new: new}
+}
+
+func (m *moderatedModule) Name() string       { return m.name }
+func (m *moderatedModule) Requires() []string { return []string{"moderation"} }
+
+func (m *moderatedModule) Init(d *module.Deps) error {
+	m.feature = m.new(d.Bot, d.Module("moderation").(*moderationModule).mod)
+	return nil
+}
+
+func (m *moderatedModule) Register(r *bot.Router, _ *module.Jobs) error {
+	m.feature.Register(r)
+	return nil
+}
+
+// remindModule sends the reminders set by users.
+type remindModule struct {
+	module.Base
+	scheduler *remind.Scheduler
+}
+
+func (m *remindModule) Name() string { return "remind" }
+
+func (m *remindModule) Init(d *module.Deps) error {
+	m.scheduler = remind.New(d.Bot)
+	return nil
+}
+
+func (m *remindModule) Register(r *bot.Router, _ *module.Jobs) error {
+	m.scheduler.Register(r)
+	return nil
+}
+
+// Run sends the due reminders. Reminders due while the bot was down are sent
+// on start, marked late.
+func (m *remindModule) Run(ctx context.Context) {
+	m.scheduler.Run(ctx)
+}
+
+// todoModule keeps the task lists of the chats, reminding of the due tasks.
+type todoModule struct {
+	module.Base
+	planner *todo.Planner
+}
+
+func (m *todoModule) Name() string       { return "todo" }
+func (m *todoModule) Requires() []string { return []string{"moderation", "remind"} }
+
+func (m *todoModule) Init(d *module.Deps) error {
+	mod := d.Module("moderation").(*moderationModule).mod
+	m.planner = todo.New(d.Bot, mod, d.Module("remind").(*remindModule).scheduler)
+	return nil
+}
+
+func (m *todoModule) Register(r *bot.Router, _ *module.Jobs) error {
+	m.planner.Register(r)
+	return nil
+}
+
+// jobsModule adds the periodic maintenance and report jobs of the bot and the
+// /jobs command of the bot owners.
+type jobsModule struct {
+	module.Base
+	adm   *admin.Admin
+	store storage.Store
+	cfg   config.Config
+}
+
+func (m *jobsModule) Name() string       { return "jobs" }
+func (m *jobsModule) Global() bool       { return true }
+func (m *jobsModule) Requires() []string { return []string{"admin"} }
+
+func (m *jobsModule) Init(d *module.Deps) error {
+	m.adm = d.Module("admin").(*adminModule).adm
+	m.store = d.Bot.Store
+	m.cfg = d.Config
+	return nil
+}
+
+func (m *jobsModule) Register(r *bot.Router, jobs *module.Jobs) error {
+	jobs.Scheduler.Register(r, m.adm.Only, jobs.Prefix)
+
+	if m.cfg.AuditRetention > 0 {
+		if err := jobs.Add("audit_prune", "@daily", time.Hour, func(context.Context) error {
+			n, err := audit.Log{Store: m.store}.Prune(time.Now().Add(-m.cfg.AuditRetention))
+			if n > 0 {
+				log.Printf("Pruned %d audit log entries", n)
+			}
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	if m.cfg.StatsReport != "" {
+		return jobs.Add("stats_report", m.cfg.StatsReport, time.Minute, m.adm.Report)
+	}
+	return nil
+}
+
+// handlersModule is a feature without state, made of handlers only.
+type handlersModule struct {
+	module.Base
+	name     string
+	register func(r *bot.Router)
+}
+
+// handlers returns the module of the name adding its handlers with register.
+func handlers(name string, register func(r *bot.Router)) module.Module {
+	return &handlersModule{name: name, register: register}
+}
+
+func (m *handlersModule) Name() string            { return m.name }
+func (m *handlersModule) Init(*module.Deps) error { return nil }
+
+func (m *handlersModule) Register(r *bot.Router, _ *module.Jobs) error {
+	m.register(r)
+	return nil
+}
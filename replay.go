@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/go-telegram-bot-api/telegram-bot-api"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/bot"
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/cron"
+	"github.com/nskondratev/go-telegram-bot-example/internal/i18n"
+	"github.com/nskondratev/go-telegram-bot-example/internal/record"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+)
+
+// errDiffer is returned by replay when the replayed calls differ from the
+// recorded ones.
+var errDiffer = errors.New("replay: the API calls differ from the recording")
+
+// replay handles the updates of a recording against the offline stand-in of
+// the API and prints the differences between the recorded and replayed calls.
+func replay(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	name := fs.String("bot", "", "name of the recorded bot, if BOTS lists several")
+	state := fs.String("state", "", "state journal to start from, copied so it stays unchanged")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bot replay [-bot NAME] [-state file] recording.jsonl")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Offline(*name)
+	if err != nil {
+		return err
+	}
+	entries, err := record.Read(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	standIn := record.NewStandIn(entries)
+	api, err := tgbotapi.NewBotAPIWithClient("offline", &http.Client{Transport: standIn})
+	if err != nil {
+		return err
+	}
+
+	bundle, err := i18n.Load(cfg.LocalesDir, cfg.DefaultLanguage)
+	if err != nil {
+		return err
+	}
+	shared, cleanup, err := copyStore(*state)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	store := namespace(shared, cfg.Name)
+	if _, err := storage.Migrate(store, "", migrations); err != nil {
+		return err
+	}
+
+	// Nothing is throttled, the stand-in has no flood limits.
+	b := bot.New(api, store, bundle)
+	b.Name = cfg.Name
+	b.Sender = bot.NewSplitSender(api, cfg.DocumentThreshold)
+	host, err := newHost(b, cfg)
+	if err != nil {
+		return err
+	}
+	if err := host.Migrate(store); err != nil {
+		return err
+	}
+	// The jobs and the background work of the modules don't run, the
+	// scheduler only takes the jobs.
+	if err := register(host, b, cfg, cron.New()); err != nil {
+		return err
+	}
+	defer host.Shutdown()
+
+	updates := 0
+	for _, e := range entries {
+		if e.Type != record.TypeUpdate {
+			continue
+		}
+		var u tgbotapi.Update
+		if err := json.Unmarshal(e.Update, &u); err != nil {
+			return err
+		}
+		b.HandleUpdate(u)
+		updates++
+	}
+
+	diff := record.Diff(record.Calls(entries), standIn.Calls())
+	fmt.Printf("Replayed %d updates, %d API calls\n", updates, len(standIn.Calls()))
+	for _, line := range diff {
+		fmt.Println(line)
+	}
+	if len(diff) > 0 {
+		return errDiffer
+	}
+	return nil
+}
+
+// copyStore opens a temporary copy of the state journal at path, or an empty
+// in-memory store if path is empty. cleanup closes the store and removes the
+// copy.
+func copyStore(path string) (store storage.Store, cleanup func(), err error) {
+	if path == "" {
+		store = storage.NewMemory()
+		return store, func() { store.Close() }, nil
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer src.Close()
+
+	dir, err := ioutil.TempDir("", "replay")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	dst, err := os.Create(filepath.Join(dir, "state.jsonl"))
+	if err == nil {
+		_, err = io.Copy(dst, src)
+		if closeErr := dst.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err == nil {
+		store, err = storage.OpenFile(dst.Name())
+	}
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+
+	return store, func() {
+		store.Close()
+		cleanup()
+	}, nil
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/nskondratev/go-telegram-bot-example/internal/config"
+	"github.com/nskondratev/go-telegram-bot-example/internal/cron"
+	"github.com/nskondratev/go-telegram-bot-example/internal/health"
+	"github.com/nskondratev/go-telegram-bot-example/internal/leader"
+	"github.com/nskondratev/go-telegram-bot-example/internal/metrics"
+	"github.com/nskondratev/go-telegram-bot-example/internal/storage"
+	"github.com/nskondratev/go-telegram-bot-example/internal/trace"
+)
+
+// run runs the bots until they are stopped by a signal or one of them fails.
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	name := fs.String("bot", "", "run only this bot of the ones listed in BOTS")
+	webhook := fs.String("webhook", "", "public https URL receiving updates, overrides WEBHOOK_URL")
+	polling := fs.Bool("poll", false, "poll for updates even if WEBHOOK_URL is set")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: bot run [-bot NAME] [-webhook URL | -poll]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var cfgs []config.Config
+	if *name != "" {
+		cfg, err := config.FromEnv(*name)
+		if err != nil {
+			return err
+		}
+		cfgs = []config.Config{cfg}
+	} else {
+		var err error
+		if cfgs, err = config.All(); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case *webhook != "" && *polling:
+		fs.Usage()
+		os.Exit(2)
+	case *webhook != "" && len(cfgs) > 1:
+		return fmt.Errorf("-webhook sets the webhook of a single bot, choose it with -bot")
+	}
+	for i := range cfgs {
+		switch {
+		case *webhook != "":
+			cfgs[i].WebhookURL = *webhook
+		case *polling:
+			cfgs[i].WebhookURL = ""
+		}
+		if err := cfgs[i].Validate(); err != nil {
+			return err
+		}
+	}
+	// The storage, the HTTP server, the leader lock and tracing are shared.
+	shared := cfgs[0]
+
+	// Tracing stays disabled with a nil tracer.
+	var tracer *trace.Tracer
+	if shared.TraceExporter != "" {
+		exporter, err := trace.Open(shared.TraceExporter, shared.TraceEndpoint, shared.TraceService)
+		if err != nil {
+			return fmt.Errorf("error while opening the span exporter: %s", err)
+		}
+		tracer = trace.New(exporter)
+	}
+
+	// With a leader lock one instance of the process runs all of the bots.
+	var elector *leader.Elector
+	if shared.LeaderLock != "" {
+		elector = leader.New(leader.NewFile(shared.LeaderLock))
+	}
+
+	insts := make([]*instance, len(cfgs))
+	monitors := make(health.Monitors)
+	for i, cfg := range cfgs {
+		inst, err := newInstance(cfg, tracer, elector)
+		if err != nil {
+			return err
+		}
+		insts[i] = inst
+		monitors[cfg.Name] = inst.monitor
+		inst.logf("Started as @%s", inst.api.Self.UserName)
+	}
+
+	if elector != nil {
+		elector.OnChange = func(leading bool, token int64) {
+			c := health.Check{Detail: "standby"}
+			if leading {
+				c = health.Check{OK: true, Detail: fmt.Sprintf("leader with fencing token %d", token)}
+			}
+			for _, m := range monitors {
+				m.Set("leader", c)
+			}
+		}
+	}
+
+	// Stop gracefully on Ctrl+C or when the process manager asks to.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		s := <-signals
+		log.Printf("Received %s, shutting down", s)
+		cancel()
+	}()
+
+	// The HTTP server starts first, so the probes answer while standing by.
+	// The webhooks of the bots are added to it as they start.
+	var wg sync.WaitGroup
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/healthz", health.Healthz)
+	if len(insts) == 1 && insts[0].cfg.Name == "" {
+		mux.HandleFunc("/readyz", insts[0].monitor.Readyz)
+	} else {
+		mux.HandleFunc("/readyz", monitors.Readyz)
+	}
+	if shared.HTTPAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serve(ctx, shared.HTTPAddr, mux)
+		}()
+	}
+
+	// Errors stopping the bots, the first ones are reported once they have
+	// shut down.
+	fatal := make(chan error, len(insts)+1)
+	fail := func(err error) {
+		select {
+		case fatal <- err:
+		default:
+		}
+		cancel()
+	}
+
+	// The state is loaded once leading, so a standby doesn't start with the
+	// state it saw at its start.
+	if elector != nil {
+		log.Printf("Waiting to become the leader")
+		if err := elector.Campaign(ctx); err != nil {
+			cancel()
+			wg.Wait()
+			return nil
+		}
+		log.Printf("Became the leader with fencing token %d", elector.Token())
+
+		go func() {
+			if err := elector.Hold(ctx); err != nil {
+				fail(err)
+			}
+		}()
+	}
+
+	// The bots share the journal, each in its own namespace.
+	store, err := openStore(shared.StoragePath)
+	if err != nil {
+		return fmt.Errorf("error while opening storage: %s", err)
+	}
+
+	// The jobs of all bots run on one scheduler, along with the compaction of
+	// the shared journal.
+	jobs := cron.New()
+	if c, ok := store.(storage.Compactor); ok {
+		if err := jobs.Add("compact", "@daily", time.Hour, func(context.Context) error {
+			return c.Compact()
+		}); err != nil {
+			return err
+		}
+	}
+
+	for _, inst := range insts {
+		if err := inst.start(ctx, store, jobs, mux, fail); err != nil {
+			return err
+		}
+	}
+
+	// Running jobs are waited for on shutdown.
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		jobs.Run(ctx)
+	}()
+
+	// Spans finished until the shutdown are exported.
+	if tracer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			tracer.Run(ctx)
+		}()
+	}
+
+	// The process stops when any of the bots stops handling updates.
+	var handling sync.WaitGroup
+	for _, inst := range insts {
+		handling.Add(1)
+		go func(inst *instance) {
+			defer handling.Done()
+			defer cancel()
+			inst.run(ctx, &wg, fail)
+		}(inst)
+	}
+	handling.Wait()
+
+	cancel()
+	wg.Wait()
+
+	for _, inst := range insts {
+		inst.close()
+	}
+
+	// The state is saved before another instance may take over.
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close the storage: %s", err)
+	}
+	if elector != nil {
+		if err := elector.Resign(); err != nil {
+			log.Printf("Failed to release the leader lock: %s", err)
+		}
+	}
+
+	select {
+	case err := <-fatal:
+		return err
+	default:
+		return nil
+	}
+}
+
+// serve serves HTTP requests on the address until the context is cancelled.
+func serve(ctx context.Context, addr string, h http.Handler) {
+	srv := &http.Server{Addr: addr, Handler: h}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Failed to stop the HTTP server: %s", err)
+		}
+	}()
+
+	log.Printf("Serving HTTP on %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("HTTP server failed: %s", err)
+	}
+}
+
+// openStore opens the state journal at path or an in-memory store if path is empty.
+func openStore(path string) (storage.Store, error) {
+	if path == "" {
+		return storage.NewMemory(), nil
+	}
+	return storage.OpenFile(path)
+}